@@ -0,0 +1,153 @@
+package biblescholar
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Recordings of whole chapters, so a passage can be played instead of read by a speech synthesizer
+// The manifest is a json file listing one recording per version and chapter, e.g.
+//
+//	{"recordings": [
+//		{"version": "ESV", "book": "John", "chapter": 3, "file": "esv/john-3.mp3", "verses": {"1": 0, "16": 95300}}
+//	]}
+//
+// Files are relative to the manifest's directory; verse start times are optional
+
+type AudioManifest struct {
+	Recordings []*AudioRecording `json:"recordings"`
+	byChapter  map[string]*AudioRecording
+}
+
+// One chapter of one version
+type AudioRecording struct {
+	Version string `json:"version"`
+	Book    string `json:"book"`
+	Chapter int    `json:"chapter"`
+	// Path of the audio file; absolute once the manifest is loaded
+	File string `json:"file"`
+	// Start of each verse from the beginning of the file, in milliseconds, keyed by verse number
+	Verses map[int]int64 `json:"verses,omitempty"`
+}
+
+func audioKey(version string, book string, chapter int) string {
+	return fmt.Sprintf("%s|%s|%d", version, book, chapter)
+}
+
+// Read a manifest, checking that every book is known and every file exists
+func LoadAudioManifest(path string) (*AudioManifest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	m := &AudioManifest{}
+	if err := json.NewDecoder(f).Decode(m); err != nil {
+		return nil, fmt.Errorf("Invalid audio manifest '%s': %v", path, err)
+	}
+
+	dir := filepath.Dir(path)
+	m.byChapter = make(map[string]*AudioRecording)
+	for i, rec := range m.Recordings {
+		book, err := LookupBook(rec.Book)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid recording %d in '%s': %v", i, path, err)
+		}
+		if rec.Chapter < 1 || rec.Chapter > book.Chapters {
+			return nil, fmt.Errorf("Invalid recording %d in '%s': %s has no chapter %d", i, path, book.Name, rec.Chapter)
+		}
+		rec.Book = book.Name
+
+		if !filepath.IsAbs(rec.File) {
+			rec.File = filepath.Join(dir, rec.File)
+		}
+		if _, err := os.Stat(rec.File); err != nil {
+			return nil, fmt.Errorf("Invalid recording %d in '%s': %v", i, path, err)
+		}
+
+		key := audioKey(rec.Version, rec.Book, rec.Chapter)
+		if _, exists := m.byChapter[key]; exists {
+			return nil, fmt.Errorf("Duplicate recording of %s %d (%s) in '%s'", rec.Book, rec.Chapter, rec.Version, path)
+		}
+		m.byChapter[key] = rec
+	}
+	return m, nil
+}
+
+// Recording of a chapter in a version, or nil if there is none
+func (m *AudioManifest) Lookup(version string, book string, chapter int) *AudioRecording {
+	if b, err := LookupBook(book); err == nil {
+		book = b.Name
+	}
+	return m.byChapter[audioKey(version, book, chapter)]
+}
+
+// Recordings of a chapter in every version, ordered by version
+func (m *AudioManifest) ChapterRecordings(book string, chapter int) []*AudioRecording {
+	b, err := LookupBook(book)
+	if err != nil {
+		return nil
+	}
+	var recs []*AudioRecording
+	for _, rec := range m.Recordings {
+		if rec.Book == b.Name && rec.Chapter == chapter {
+			recs = append(recs, rec)
+		}
+	}
+	sort.Slice(recs, func(i, j int) bool {
+		return recs[i].Version < recs[j].Version
+	})
+	return recs
+}
+
+// Recording of the following chapter in the same version, continuing into the next book
+// Nil if that chapter was not recorded
+func (m *AudioManifest) Next(rec *AudioRecording) *AudioRecording {
+	book, err := LookupBook(rec.Book)
+	if err != nil {
+		return nil
+	}
+	if rec.Chapter < book.Chapters {
+		return m.Lookup(rec.Version, book.Name, rec.Chapter+1)
+	}
+	for i, b := range Books {
+		if b == book && i+1 < len(Books) {
+			return m.Lookup(rec.Version, Books[i+1].Name, 1)
+		}
+	}
+	return nil
+}
+
+// Recording of the chapter before, going back into the previous book
+// Nil if that chapter was not recorded
+func (m *AudioManifest) Previous(rec *AudioRecording) *AudioRecording {
+	book, err := LookupBook(rec.Book)
+	if err != nil {
+		return nil
+	}
+	if rec.Chapter > 1 {
+		return m.Lookup(rec.Version, book.Name, rec.Chapter-1)
+	}
+	for i, b := range Books {
+		if b == book && i > 0 {
+			return m.Lookup(rec.Version, Books[i-1].Name, Books[i-1].Chapters)
+		}
+	}
+	return nil
+}
+
+// Where a verse starts in the recording, in milliseconds
+// Verse 1 starts at the beginning unless a time is given; other verses are not found without one
+func (r *AudioRecording) VerseOffset(verse int) (int64, bool) {
+	if offset, ok := r.Verses[verse]; ok {
+		return offset, true
+	}
+	if verse <= 1 {
+		return 0, true
+	}
+	return 0, false
+}
@@ -0,0 +1,238 @@
+package biblescholar
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Testament int
+
+const (
+	OldTestament Testament = iota
+	NewTestament
+)
+
+// A book of the Bible, with the names people use to refer to it
+type Book struct {
+	// Canonical name, matching the names used in the scraped data
+	Name string
+	// Number of chapters in the book
+	Chapters  int
+	Testament Testament
+	// Alternate spellings and common abbreviations
+	// Numbered variants ("I Cor", "First Corinthians", "1Cor") are handled when normalizing, so only the arabic form is listed
+	Aliases []string
+}
+
+// Single chapter books are referenced by verse only, e.g. "Jude 3"
+func (b *Book) IsSingleChapter() bool {
+	return b.Chapters == 1
+}
+
+// All the names this book could be stored under in the index
+func (b *Book) Names() []string {
+	return append([]string{b.Name}, b.Aliases...)
+}
+
+// The 66 books of the protestant canon, in canonical order
+var Books = []*Book{
+	{"Genesis", 50, OldTestament, []string{"Gen", "Ge", "Gn"}},
+	{"Exodus", 40, OldTestament, []string{"Exod", "Exo", "Ex"}},
+	{"Leviticus", 27, OldTestament, []string{"Lev", "Le", "Lv"}},
+	{"Numbers", 36, OldTestament, []string{"Num", "Nu", "Nm", "Nb"}},
+	{"Deuteronomy", 34, OldTestament, []string{"Deut", "De", "Dt"}},
+	{"Joshua", 24, OldTestament, []string{"Josh", "Jos", "Jsh"}},
+	{"Judges", 21, OldTestament, []string{"Judg", "Jdg", "Jg", "Jdgs"}},
+	{"Ruth", 4, OldTestament, []string{"Rth", "Ru"}},
+	{"1 Samuel", 31, OldTestament, []string{"1 Sam", "1 Sa", "1 Sm", "1 S"}},
+	{"2 Samuel", 24, OldTestament, []string{"2 Sam", "2 Sa", "2 Sm", "2 S"}},
+	{"1 Kings", 22, OldTestament, []string{"1 Kgs", "1 Ki", "1 Kin"}},
+	{"2 Kings", 25, OldTestament, []string{"2 Kgs", "2 Ki", "2 Kin"}},
+	{"1 Chronicles", 29, OldTestament, []string{"1 Chron", "1 Chr", "1 Ch"}},
+	{"2 Chronicles", 36, OldTestament, []string{"2 Chron", "2 Chr", "2 Ch"}},
+	{"Ezra", 10, OldTestament, []string{"Ezr", "Ez"}},
+	{"Nehemiah", 13, OldTestament, []string{"Neh", "Ne"}},
+	{"Esther", 10, OldTestament, []string{"Esth", "Est", "Es"}},
+	{"Job", 42, OldTestament, []string{"Jb"}},
+	{"Psalm", 150, OldTestament, []string{"Psalms", "Ps", "Psa", "Pss", "Psm", "Pslm"}},
+	{"Proverbs", 31, OldTestament, []string{"Prov", "Pro", "Prv", "Pr"}},
+	{"Ecclesiastes", 12, OldTestament, []string{"Eccles", "Eccl", "Ecc", "Ec", "Qoh"}},
+	{"Song of Songs", 8, OldTestament, []string{"Song of Solomon", "Song", "Canticles", "Cant", "Sng", "SS", "SOS"}},
+	{"Isaiah", 66, OldTestament, []string{"Isa", "Is"}},
+	{"Jeremiah", 52, OldTestament, []string{"Jer", "Je", "Jr"}},
+	{"Lamentations", 5, OldTestament, []string{"Lam", "La"}},
+	{"Ezekiel", 48, OldTestament, []string{"Ezek", "Eze", "Ezk"}},
+	{"Daniel", 12, OldTestament, []string{"Dan", "Da", "Dn"}},
+	{"Hosea", 14, OldTestament, []string{"Hos", "Ho"}},
+	{"Joel", 3, OldTestament, []string{"Jl"}},
+	{"Amos", 9, OldTestament, []string{"Am"}},
+	{"Obadiah", 1, OldTestament, []string{"Obad", "Ob"}},
+	{"Jonah", 4, OldTestament, []string{"Jon", "Jnh"}},
+	{"Micah", 7, OldTestament, []string{"Mic", "Mc"}},
+	{"Nahum", 3, OldTestament, []string{"Nah", "Na"}},
+	{"Habakkuk", 3, OldTestament, []string{"Hab", "Hb"}},
+	{"Zephaniah", 3, OldTestament, []string{"Zeph", "Zep", "Zp"}},
+	{"Haggai", 2, OldTestament, []string{"Hag", "Hg"}},
+	{"Zechariah", 14, OldTestament, []string{"Zech", "Zec", "Zc"}},
+	{"Malachi", 4, OldTestament, []string{"Mal", "Ml"}},
+	{"Matthew", 28, NewTestament, []string{"Matt", "Mat", "Mt"}},
+	{"Mark", 16, NewTestament, []string{"Mrk", "Mar", "Mk", "Mr"}},
+	{"Luke", 24, NewTestament, []string{"Luk", "Lk"}},
+	{"John", 21, NewTestament, []string{"Joh", "Jhn", "Jn"}},
+	{"Acts", 28, NewTestament, []string{"Act", "Ac", "Acts of the Apostles"}},
+	{"Romans", 16, NewTestament, []string{"Rom", "Ro", "Rm"}},
+	{"1 Corinthians", 16, NewTestament, []string{"1 Cor", "1 Co"}},
+	{"2 Corinthians", 13, NewTestament, []string{"2 Cor", "2 Co"}},
+	{"Galatians", 6, NewTestament, []string{"Gal", "Ga"}},
+	{"Ephesians", 6, NewTestament, []string{"Eph", "Ephes"}},
+	{"Philippians", 4, NewTestament, []string{"Phil", "Php", "Pp"}},
+	{"Colossians", 4, NewTestament, []string{"Col", "Co"}},
+	{"1 Thessalonians", 5, NewTestament, []string{"1 Thess", "1 Thes", "1 Th"}},
+	{"2 Thessalonians", 3, NewTestament, []string{"2 Thess", "2 Thes", "2 Th"}},
+	{"1 Timothy", 6, NewTestament, []string{"1 Tim", "1 Ti"}},
+	{"2 Timothy", 4, NewTestament, []string{"2 Tim", "2 Ti"}},
+	{"Titus", 3, NewTestament, []string{"Tit", "Ti"}},
+	{"Philemon", 1, NewTestament, []string{"Philem", "Phm", "Pm"}},
+	{"Hebrews", 13, NewTestament, []string{"Heb"}},
+	{"James", 5, NewTestament, []string{"Jas", "Jm"}},
+	{"1 Peter", 5, NewTestament, []string{"1 Pet", "1 Pe", "1 Pt", "1 P"}},
+	{"2 Peter", 3, NewTestament, []string{"2 Pet", "2 Pe", "2 Pt", "2 P"}},
+	{"1 John", 5, NewTestament, []string{"1 Jn", "1 Jhn", "1 Jo", "1 J"}},
+	{"2 John", 1, NewTestament, []string{"2 Jn", "2 Jhn", "2 Jo", "2 J"}},
+	{"3 John", 1, NewTestament, []string{"3 Jn", "3 Jhn", "3 Jo", "3 J"}},
+	{"Jude", 1, NewTestament, []string{"Jud", "Jd"}},
+	{"Revelation", 22, NewTestament, []string{"Rev", "Re", "Revelations", "The Revelation", "Apocalypse"}},
+}
+
+// Verses in each book, as numbered in the KJV; other translations differ by a few verses, e.g. ones modern translations leave out
+var bookVerseCounts = map[string]int{
+	"Genesis": 1533, "Exodus": 1213, "Leviticus": 859, "Numbers": 1288, "Deuteronomy": 959,
+	"Joshua": 658, "Judges": 618, "Ruth": 85, "1 Samuel": 810, "2 Samuel": 695,
+	"1 Kings": 816, "2 Kings": 719, "1 Chronicles": 942, "2 Chronicles": 822, "Ezra": 280,
+	"Nehemiah": 406, "Esther": 167, "Job": 1070, "Psalm": 2461, "Proverbs": 915,
+	"Ecclesiastes": 222, "Song of Songs": 117, "Isaiah": 1292, "Jeremiah": 1364, "Lamentations": 154,
+	"Ezekiel": 1273, "Daniel": 357, "Hosea": 197, "Joel": 73, "Amos": 146,
+	"Obadiah": 21, "Jonah": 48, "Micah": 105, "Nahum": 47, "Habakkuk": 56,
+	"Zephaniah": 53, "Haggai": 38, "Zechariah": 211, "Malachi": 55,
+	"Matthew": 1071, "Mark": 678, "Luke": 1151, "John": 879, "Acts": 1007,
+	"Romans": 433, "1 Corinthians": 437, "2 Corinthians": 257, "Galatians": 149, "Ephesians": 155,
+	"Philippians": 104, "Colossians": 95, "1 Thessalonians": 89, "2 Thessalonians": 47, "1 Timothy": 113,
+	"2 Timothy": 83, "Titus": 46, "Philemon": 25, "Hebrews": 303, "James": 108,
+	"1 Peter": 105, "2 Peter": 61, "1 John": 105, "2 John": 13, "3 John": 14,
+	"Jude": 25, "Revelation": 404,
+}
+
+// Names of the books in other languages, by language and canonical name
+// Looked up like aliases, but never stored in the index
+var localBookNames = map[string]map[string][]string{
+	"de": {
+		"Genesis": {"1 Mose"}, "Exodus": {"2 Mose"}, "Leviticus": {"3 Mose", "Levitikus"},
+		"Numbers": {"4 Mose", "Numeri"}, "Deuteronomy": {"5 Mose", "Deuteronomium"},
+		"Joshua": {"Josua"}, "Judges": {"Richter"}, "Ruth": {"Rut"}, "1 Samuel": {"1 Samuel"}, "2 Samuel": {"2 Samuel"},
+		"1 Kings": {"1 Könige"}, "2 Kings": {"2 Könige"}, "1 Chronicles": {"1 Chronik"}, "2 Chronicles": {"2 Chronik"},
+		"Ezra": {"Esra"}, "Nehemiah": {"Nehemia"}, "Esther": {"Ester"}, "Job": {"Hiob", "Ijob"},
+		"Psalm": {"Psalm", "Psalmen"}, "Proverbs": {"Sprüche", "Sprichwörter"}, "Ecclesiastes": {"Prediger", "Kohelet"},
+		"Song of Songs": {"Hohelied", "Hoheslied"}, "Isaiah": {"Jesaja"}, "Jeremiah": {"Jeremia"},
+		"Lamentations": {"Klagelieder"}, "Ezekiel": {"Hesekiel", "Ezechiel"}, "Daniel": {"Daniel"},
+		"Hosea": {"Hosea"}, "Joel": {"Joel"}, "Amos": {"Amos"}, "Obadiah": {"Obadja"}, "Jonah": {"Jona"},
+		"Micah": {"Micha"}, "Nahum": {"Nahum"}, "Habakkuk": {"Habakuk"}, "Zephaniah": {"Zefanja", "Zephanja"},
+		"Haggai": {"Haggai"}, "Zechariah": {"Sacharja"}, "Malachi": {"Maleachi"},
+		"Matthew": {"Matthäus"}, "Mark": {"Markus"}, "Luke": {"Lukas"}, "John": {"Johannes"},
+		"Acts": {"Apostelgeschichte"}, "Romans": {"Römer"}, "1 Corinthians": {"1 Korinther"}, "2 Corinthians": {"2 Korinther"},
+		"Galatians": {"Galater"}, "Ephesians": {"Epheser"}, "Philippians": {"Philipper"}, "Colossians": {"Kolosser"},
+		"1 Thessalonians": {"1 Thessalonicher"}, "2 Thessalonians": {"2 Thessalonicher"},
+		"1 Timothy": {"1 Timotheus"}, "2 Timothy": {"2 Timotheus"}, "Titus": {"Titus"}, "Philemon": {"Philemon"},
+		"Hebrews": {"Hebräer"}, "James": {"Jakobus"}, "1 Peter": {"1 Petrus"}, "2 Peter": {"2 Petrus"},
+		"1 John": {"1 Johannes"}, "2 John": {"2 Johannes"}, "3 John": {"3 Johannes"}, "Jude": {"Judas"},
+		"Revelation": {"Offenbarung"},
+	},
+}
+
+// Names of this book in a language, e.g. "de"; empty for English or a language without names
+func (b *Book) LocalNames(language string) []string {
+	return localBookNames[language][b.Name]
+}
+
+// Normalized name -> book, built from the canonical names and aliases
+var bookLookup map[string]*Book
+
+func init() {
+	bookLookup = make(map[string]*Book)
+	for _, b := range Books {
+		for _, name := range b.Names() {
+			key := bookKey(name)
+			if other, exists := bookLookup[key]; exists && other != b {
+				panic(fmt.Sprintf("Book alias '%s' is used by both %s and %s", name, other.Name, b.Name))
+			}
+			bookLookup[key] = b
+		}
+	}
+	for _, names := range localBookNames {
+		for _, b := range Books {
+			for _, name := range names[b.Name] {
+				key := bookKey(name)
+				if other, exists := bookLookup[key]; exists && other != b {
+					panic(fmt.Sprintf("Book name '%s' is used by both %s and %s", name, other.Name, b.Name))
+				}
+				bookLookup[key] = b
+			}
+		}
+	}
+}
+
+// Spoken and written forms of the numbers that prefix books like "1 Samuel", in English and German
+var bookNumberPrefixes = map[string]string{
+	"1": "1", "i": "1", "first": "1", "1st": "1", "erste": "1", "erster": "1", "ersten": "1",
+	"2": "2", "ii": "2", "second": "2", "2nd": "2", "zweite": "2", "zweiter": "2", "zweiten": "2",
+	"3": "3", "iii": "3", "third": "3", "3rd": "3", "dritte": "3", "dritter": "3", "dritten": "3",
+}
+
+// Reduce a book name to a form that can be compared against the lookup table
+// e.g. "I Cor." -> "1cor", "Song of Solomon" -> "songofsolomon"
+func bookKey(name string) string {
+	fields := strings.Fields(strings.ToLower(strings.Replace(name, ".", " ", -1)))
+	if len(fields) == 0 {
+		return ""
+	}
+
+	// Split forms like "1cor" and "1st" from the rest of the name
+	prefix := fields[0]
+	if len(prefix) > 1 && prefix[0] >= '1' && prefix[0] <= '3' {
+		if _, ok := bookNumberPrefixes[prefix]; !ok {
+			fields = append([]string{prefix[:1], prefix[1:]}, fields[1:]...)
+		}
+	}
+
+	if len(fields) > 1 {
+		if n, ok := bookNumberPrefixes[fields[0]]; ok {
+			fields[0] = n
+		}
+	}
+	return strings.Join(fields, "")
+}
+
+// Find a book by its name or one of its abbreviations
+// Falls back to an unambiguous prefix of the canonical name, e.g. "Deuter"
+func LookupBook(name string) (*Book, error) {
+	key := bookKey(name)
+	if key == "" {
+		return nil, fmt.Errorf("Missing book name")
+	}
+	if b, ok := bookLookup[key]; ok {
+		return b, nil
+	}
+
+	var found *Book
+	for _, b := range Books {
+		if strings.HasPrefix(bookKey(b.Name), key) {
+			if found != nil {
+				return nil, fmt.Errorf("Ambiguous book name: '%s' could be %s or %s", name, found.Name, b.Name)
+			}
+			found = b
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("Unknown book name: '%s'", name)
+	}
+	return found, nil
+}
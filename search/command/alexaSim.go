@@ -0,0 +1,473 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/blevesearch/bleve"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	biblescholar "github.com/turtlemonvh/biblescholar/search"
+	"github.com/turtlemonvh/biblescholar/search/conversation"
+	"github.com/turtlemonvh/biblescholar/search/server"
+)
+
+var alexaSimLongDesc = `Simulates a conversation with the alexa skill.
+
+Each utterance is turned into a LaunchRequest, IntentRequest or SessionEndedRequest
+and sent through the alexa handler, either in-process against the local index or
+to a running server with --url. Session attributes are carried between turns.
+
+Utterances are read from the arguments, from a script file with --file (one per
+line, '#' for comments), or interactively from stdin. Understood forms:
+
+  open | launch                        LaunchRequest
+  search for <phrase> | <phrase>       SearchBible
+  read <reference> [in <translation>]  ReadPassage, e.g. "read John 3:16-18 in KJV"
+  use [the] <translation>              SetTranslation, e.g. "use the king james"
+  which translation                    GetTranslation
+  the first one | the <book> one       SelectChoice, after being offered close matches
+  play <reference> [in <translation>]  PlayPassage, e.g. "play Psalm 23"
+  next | previous | repeat             AMAZON.NextIntent, ...
+  yes | no | help | stop | home        AMAZON.YesIntent, ...
+  pause | resume                       AMAZON.PauseIntent, AMAZON.ResumeIntent
+  end | exit | quit                    SessionEndedRequest
+  intent <Name> [Slot=value ...]       any intent, e.g. "intent ReadPassage Book=John Chapter=3"
+  event <Type>                         an AudioPlayer or PlaybackController request, outside the session,
+                                       e.g. "event AudioPlayer.PlaybackNearlyFinished"
+  can fulfill <utterance>              CanFulfillIntentRequest for the intent the utterance maps to,
+                                       e.g. "can fulfill read John 3 in KJV"
+
+The simulated device supports the AudioPlayer interface unless --audio-player=false
+is given, and remembers the last recording it was told to play. With --apl it also
+has a screen, and the skill sends APL documents; use --raw to see them.
+`
+
+var alexaSimCmd = &cobra.Command{
+	Use:   "alexa-sim [utterance...]",
+	Short: "Send a scripted conversation through the alexa handler and print the responses",
+	Long:  alexaSimLongDesc,
+	Run: func(cmd *cobra.Command, args []string) {
+		viper.BindPFlag("index-path", cmd.Flags().Lookup("index-path"))
+		viper.BindPFlag("debug-logging", cmd.Flags().Lookup("debug-logging"))
+
+		HandleLogLevel()
+		if !viper.GetBool("debug-logging") {
+			// Keep request logs out of the transcript
+			log.SetLevel(log.WarnLevel)
+		}
+
+		sim := &alexaSimulator{
+			appID:  mustGetString(cmd, "app-id"),
+			userID: mustGetString(cmd, "user-id"),
+			locale: mustGetString(cmd, "locale"),
+			raw:    mustGetBool(cmd, "raw"),
+			audio:  mustGetBool(cmd, "audio-player"),
+			apl:    mustGetBool(cmd, "apl"),
+			out:    os.Stdout,
+		}
+
+		if url := mustGetString(cmd, "url"); url != "" {
+			sim.send = httpSender(url)
+		} else {
+			idx, err := bleve.Open(viper.GetString("index-path"))
+			if err != nil {
+				log.Fatal(err)
+			}
+			svr := &server.ServerConfig{
+				BuildCommit: buildCommit,
+				BuildBranch: buildBranch,
+				Index:       idx,
+			}
+			if path := mustGetString(cmd, "audio-manifest"); path != "" {
+				if svr.Audio, err = biblescholar.LoadAudioManifest(path); err != nil {
+					log.Fatal(err)
+				}
+			}
+			sim.send = handlerSender(svr.Router())
+		}
+
+		var err error
+		switch {
+		case len(args) != 0:
+			err = sim.RunAll(args)
+		case mustGetString(cmd, "file") != "":
+			var f *os.File
+			if f, err = os.Open(mustGetString(cmd, "file")); err == nil {
+				defer f.Close()
+				err = sim.RunScript(f, false)
+			}
+		default:
+			err = sim.RunScript(os.Stdin, true)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(alexaSimCmd)
+	alexaSimCmd.Flags().String("url", "", "alexa endpoint of a running server, e.g. http://localhost:8000/alexa/search. Default is to run in-process against the index")
+	alexaSimCmd.Flags().StringP("file", "f", "", "script of utterances, one per line")
+	alexaSimCmd.Flags().String("app-id", server.BibleScholarAppId, "application id to send")
+	alexaSimCmd.Flags().String("user-id", "amzn1.ask.account.SIMULATOR", "user id to send")
+	alexaSimCmd.Flags().String("locale", "en-US", "locale to send")
+	alexaSimCmd.Flags().Bool("raw", false, "print the full JSON response instead of the spoken text and card")
+	alexaSimCmd.Flags().Bool("audio-player", true, "whether the simulated device supports the AudioPlayer interface")
+	alexaSimCmd.Flags().Bool("apl", false, "whether the simulated device has a screen that supports APL")
+	alexaSimCmd.Flags().String("audio-manifest", "", "json file listing recorded chapters, when running in-process")
+}
+
+func mustGetString(cmd *cobra.Command, name string) string {
+	value, err := cmd.Flags().GetString(name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return value
+}
+
+func mustGetBool(cmd *cobra.Command, name string) bool {
+	value, err := cmd.Flags().GetBool(name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return value
+}
+
+// Send an encoded request envelope and return the status and body of the response
+type alexaSender func(body []byte) (int, []byte, error)
+
+// Run requests through the handlers without starting a server
+func handlerSender(h http.Handler) alexaSender {
+	return func(body []byte) (int, []byte, error) {
+		req := httptest.NewRequest(http.MethodPost, "/alexa/search", bytes.NewReader(body))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code, rec.Body.Bytes(), nil
+	}
+}
+
+func httpSender(url string) alexaSender {
+	client := &http.Client{Timeout: 30 * time.Second}
+	return func(body []byte) (int, []byte, error) {
+		resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+		if err != nil {
+			return 0, nil, err
+		}
+		defer resp.Body.Close()
+		respBody, err := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, respBody, err
+	}
+}
+
+type alexaSimulator struct {
+	appID  string
+	userID string
+	locale string
+	raw    bool
+	audio  bool
+	apl    bool
+	out    io.Writer
+	send   alexaSender
+
+	// Utterances typed at the prompt are already on screen
+	interactive bool
+
+	// Current session; nil when the previous response ended it
+	session *server.AlexaSession
+	nturns  int
+
+	// Last recording the skill asked the device to play, if any
+	player *server.AlexaPlayerState
+}
+
+// Run each utterance in order, as one conversation
+func (sim *alexaSimulator) RunAll(utterances []string) error {
+	for _, utterance := range utterances {
+		if err := sim.Say(utterance); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run utterances read one per line, skipping blank lines and comments
+func (sim *alexaSimulator) RunScript(r io.Reader, interactive bool) error {
+	sim.interactive = interactive
+	scanner := bufio.NewScanner(r)
+	for {
+		if interactive {
+			fmt.Fprint(sim.out, "> ")
+		}
+		if !scanner.Scan() {
+			break
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := sim.Say(line); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// Send one utterance and print the response
+func (sim *alexaSimulator) Say(utterance string) error {
+	sim.nturns++
+	if sim.session == nil {
+		sim.session = &server.AlexaSession{
+			New:         true,
+			SessionID:   fmt.Sprintf("SessionId.simulator-%d-%d", time.Now().UnixNano(), sim.nturns),
+			Application: server.AlexaApplication{ApplicationID: sim.appID},
+			Attributes:  map[string]interface{}{},
+			User:        server.AlexaUser{UserID: sim.userID},
+		}
+	}
+
+	body, err := parseUtterance(utterance)
+	if err != nil {
+		return err
+	}
+	body.RequestID = fmt.Sprintf("EdwRequestId.simulator-%d", sim.nturns)
+	body.Timestamp = time.Now().UTC().Format(time.RFC3339)
+	body.Locale = sim.locale
+
+	req := &server.AlexaRequest{
+		Version: "1.0",
+		Session: sim.session,
+		Context: &server.AlexaContext{
+			System: server.AlexaSystem{
+				Application: server.AlexaApplication{ApplicationID: sim.appID},
+				User:        server.AlexaUser{UserID: sim.userID},
+			},
+			AudioPlayer: sim.player,
+		},
+		Request: *body,
+	}
+	req.Context.System.Device.SupportedInterfaces = map[string]json.RawMessage{}
+	if sim.audio {
+		req.Context.System.Device.SupportedInterfaces["AudioPlayer"] = json.RawMessage("{}")
+	}
+	if sim.apl {
+		req.Context.System.Device.SupportedInterfaces["Alexa.Presentation.APL"] = json.RawMessage(`{"runtime": {"maxVersion": "1.4"}}`)
+	}
+
+	// Player events are sent outside of the session, about the current recording
+	isPlayerEvent := strings.HasPrefix(body.Type, "AudioPlayer.") || strings.HasPrefix(body.Type, "PlaybackController.")
+	if isPlayerEvent {
+		req.Session = nil
+		if sim.player != nil && strings.HasPrefix(body.Type, "AudioPlayer.") {
+			req.Request.Token = sim.player.Token
+			req.Request.OffsetInMilliseconds = sim.player.OffsetInMilliseconds
+		}
+	}
+	encoded, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	status, respBody, err := sim.send(encoded)
+	if err != nil {
+		return err
+	}
+
+	if !sim.interactive {
+		fmt.Fprintf(sim.out, "> %s\n", utterance)
+	}
+	fmt.Fprintf(sim.out, "  [%s %s] HTTP %d\n", body.Type, intentName(body), status)
+
+	if body.Type == "CanFulfillIntentRequest" {
+		// Not part of the session, and answered with a different kind of response
+		sim.printCanFulfill(respBody)
+		return nil
+	}
+
+	resp := &server.AlexaResponse{}
+	if err := json.Unmarshal(respBody, resp); err != nil {
+		fmt.Fprintf(sim.out, "  %s\n", strings.TrimSpace(string(respBody)))
+		sim.session = nil
+		return nil
+	}
+	if sim.raw {
+		pretty, _ := json.MarshalIndent(resp, "  ", "  ")
+		fmt.Fprintf(sim.out, "  %s\n", pretty)
+	} else {
+		sim.printResponse(resp)
+	}
+	sim.updatePlayer(resp)
+
+	// Carry the session forward unless the skill or the user ended it
+	switch {
+	case isPlayerEvent:
+		// Leaves the session as it was
+	case resp.Response.ShouldEndSession || body.Type == "SessionEndedRequest":
+		sim.session = nil
+		fmt.Fprintln(sim.out, "  (session ended)")
+	default:
+		sim.session.New = false
+		sim.session.Attributes = resp.SessionAttributes
+	}
+	fmt.Fprintln(sim.out)
+	return nil
+}
+
+func (sim *alexaSimulator) printResponse(resp *server.AlexaResponse) {
+	if speech := resp.Response.OutputSpeech; speech != nil {
+		fmt.Fprintf(sim.out, "  speech: %s\n", speechText(speech))
+	}
+	if card := resp.Response.Card; card != nil {
+		fmt.Fprintf(sim.out, "  card: %s\n        %s\n", card.Title, card.Content)
+	}
+	if reprompt := resp.Response.Reprompt; reprompt != nil && reprompt.OutputSpeech != nil {
+		fmt.Fprintf(sim.out, "  reprompt: %s\n", speechText(reprompt.OutputSpeech))
+	}
+	for _, directive := range resp.Response.Directives {
+		if item := directive.AudioItem; item != nil {
+			fmt.Fprintf(sim.out, "  directive: %s %s %s (token %s, offset %dms)\n", directive.Type, directive.PlayBehavior, item.Stream.URL, item.Stream.Token, item.Stream.OffsetInMilliseconds)
+		} else if directive.Token != "" {
+			fmt.Fprintf(sim.out, "  directive: %s (token %s)\n", directive.Type, directive.Token)
+		} else {
+			fmt.Fprintf(sim.out, "  directive: %s\n", directive.Type)
+		}
+	}
+	if len(resp.SessionAttributes) != 0 {
+		attrs, _ := json.Marshal(resp.SessionAttributes)
+		fmt.Fprintf(sim.out, "  attributes: %s\n", attrs)
+	}
+}
+
+func (sim *alexaSimulator) printCanFulfill(respBody []byte) {
+	resp := &server.AlexaCanFulfillResponse{}
+	if err := json.Unmarshal(respBody, resp); err != nil || sim.raw {
+		fmt.Fprintf(sim.out, "  %s\n\n", strings.TrimSpace(string(respBody)))
+		return
+	}
+	answer := resp.Response.CanFulfillIntent
+	fmt.Fprintf(sim.out, "  canFulfill: %s\n", answer.CanFulfill)
+	var names []string
+	for name := range answer.Slots {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		slot := answer.Slots[name]
+		fmt.Fprintf(sim.out, "  slot %s: canUnderstand %s, canFulfill %s\n", name, slot.CanUnderstand, slot.CanFulfill)
+	}
+	fmt.Fprintln(sim.out)
+}
+
+// Follow the skill's AudioPlayer directives, as the device would
+// Playback is not simulated, so the offset stays where the skill started it
+func (sim *alexaSimulator) updatePlayer(resp *server.AlexaResponse) {
+	for _, directive := range resp.Response.Directives {
+		switch {
+		case directive.Type == "AudioPlayer.Play" && directive.PlayBehavior != "ENQUEUE":
+			sim.player = &server.AlexaPlayerState{
+				Token:                directive.AudioItem.Stream.Token,
+				OffsetInMilliseconds: directive.AudioItem.Stream.OffsetInMilliseconds,
+				PlayerActivity:       "PLAYING",
+			}
+		case directive.Type == "AudioPlayer.Stop" && sim.player != nil:
+			sim.player.PlayerActivity = "STOPPED"
+		}
+	}
+}
+
+var ssmlTagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// What the user would hear, without markup
+func speechText(speech *server.AlexaOutputSpeech) string {
+	if speech.SSML == "" {
+		return speech.Text
+	}
+	txt := html.UnescapeString(ssmlTagPattern.ReplaceAllString(speech.SSML, ""))
+	return strings.Join(strings.Fields(txt), " ")
+}
+
+func intentName(body *server.AlexaRequestBody) string {
+	if body.Intent == nil {
+		return ""
+	}
+	return body.Intent.Name
+}
+
+var canFulfillUtterancePattern = regexp.MustCompile(`(?i)^can\s*fulfill\s+(.+)$`)
+
+// Build the request body for a typed utterance
+// Everything but the simulator's own forms is matched to an intent the same way as in the chat command
+func parseUtterance(utterance string) (*server.AlexaRequestBody, error) {
+	lower := strings.ToLower(strings.TrimSpace(utterance))
+
+	if m := canFulfillUtterancePattern.FindStringSubmatch(utterance); m != nil {
+		body, err := parseUtterance(m[1])
+		if err != nil {
+			return nil, err
+		}
+		if body.Type != "IntentRequest" {
+			return nil, fmt.Errorf("Only intents can be checked with 'can fulfill': '%s'", m[1])
+		}
+		body.Type = "CanFulfillIntentRequest"
+		return body, nil
+	}
+
+	switch lower {
+	case "end", "exit", "quit":
+		return &server.AlexaRequestBody{Type: "SessionEndedRequest", Reason: "USER_INITIATED"}, nil
+	}
+
+	if fields := strings.Fields(utterance); len(fields) > 1 && strings.ToLower(fields[0]) == "intent" {
+		slots := map[string]string{}
+		for _, f := range fields[2:] {
+			kv := strings.SplitN(f, "=", 2)
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("Invalid slot '%s', expected Name=value", f)
+			}
+			slots[kv[0]] = strings.Replace(kv[1], "_", " ", -1)
+		}
+		return intentRequestBody(fields[1], slots), nil
+	}
+	if fields := strings.Fields(utterance); len(fields) == 2 && strings.ToLower(fields[0]) == "event" {
+		return &server.AlexaRequestBody{Type: fields[1]}, nil
+	}
+
+	intent, slots := conversation.ParseUtterance(utterance)
+	if intent == conversation.LaunchIntent {
+		return &server.AlexaRequestBody{Type: "LaunchRequest"}, nil
+	}
+	name := server.AlexaIntentName(intent)
+	if name == "" {
+		return nil, fmt.Errorf("The skill has no intent for '%s'", utterance)
+	}
+	return intentRequestBody(name, slots), nil
+}
+
+func intentRequestBody(name string, slotValues map[string]string) *server.AlexaRequestBody {
+	slots := make(map[string]server.AlexaSlot)
+	for slot, value := range slotValues {
+		slots[slot] = server.AlexaSlot{Name: slot, Value: value}
+	}
+	return &server.AlexaRequestBody{
+		Type: "IntentRequest",
+		Intent: &server.AlexaIntent{
+			Name:               name,
+			ConfirmationStatus: "NONE",
+			Slots:              slots,
+		},
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/blevesearch/bleve"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/turtlemonvh/biblescholar/search/conversation"
+)
+
+var chatLongDesc = `Talk to the conversation engine in plain text, without alexa.
+
+Understands the same utterances as alexa-sim, e.g. "love your neighbor", "next",
+"read John 3 in KJV", "use the king james" or "which translation". Anything that
+is not a command is searched for.
+
+Utterances are read from the arguments, or interactively from stdin. The conversation
+carries on between utterances until the engine ends it, e.g. after "stop".
+`
+
+var chatCmd = &cobra.Command{
+	Use:   "chat [utterance...]",
+	Short: "Search and read the Bible through a text conversation",
+	Long:  chatLongDesc,
+	Run: func(cmd *cobra.Command, args []string) {
+		viper.BindPFlag("index-path", cmd.Flags().Lookup("index-path"))
+		viper.BindPFlag("debug-logging", cmd.Flags().Lookup("debug-logging"))
+		viper.BindPFlag("preferences-db", cmd.Flags().Lookup("preferences-db"))
+
+		HandleLogLevel()
+		if !viper.GetBool("debug-logging") {
+			// Keep request logs out of the conversation
+			log.SetLevel(log.WarnLevel)
+		}
+
+		idx, err := bleve.Open(viper.GetString("index-path"))
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		engine := &conversation.Engine{Index: idx}
+		if path := viper.GetString("preferences-db"); path != "" {
+			store, err := conversation.OpenBoltPreferenceStore(path)
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer store.Close()
+			engine.Preferences = store
+		}
+
+		chat := &chatSession{
+			engine: engine,
+			userID: mustGetString(cmd, "user-id"),
+			locale: mustGetString(cmd, "locale"),
+			out:    os.Stdout,
+		}
+		if len(args) != 0 {
+			for _, utterance := range args {
+				chat.Say(utterance, true)
+			}
+			return
+		}
+		if err := chat.Run(os.Stdin); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(chatCmd)
+	chatCmd.Flags().String("user-id", "local", "user to remember preferences for")
+	chatCmd.Flags().String("locale", "en-US", "language to answer in, e.g. de-DE")
+	chatCmd.Flags().String("preferences-db", "", "bolt database file for per user settings such as the preferred translation. Default is to keep them in memory")
+}
+
+// A text channel for the conversation engine
+type chatSession struct {
+	engine *conversation.Engine
+	userID string
+	locale string
+	out    io.Writer
+
+	// State from the previous response; nil when the engine ended the conversation
+	session map[string]interface{}
+}
+
+// Answer utterances typed at the prompt until stdin is closed
+func (chat *chatSession) Run(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for {
+		fmt.Fprint(chat.out, "> ")
+		if !scanner.Scan() {
+			break
+		}
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			chat.Say(line, false)
+		}
+	}
+	return scanner.Err()
+}
+
+// Send one utterance to the engine and print what it says
+func (chat *chatSession) Say(utterance string, echo bool) {
+	if echo {
+		fmt.Fprintf(chat.out, "> %s\n", utterance)
+	}
+
+	intent, slots := conversation.ParseUtterance(utterance)
+	resp := chat.engine.Handle(&conversation.Request{
+		Channel: "chat",
+		Intent:  intent,
+		Slots:   slots,
+		Session: chat.session,
+		UserID:  chat.userID,
+		Locale:  chat.locale,
+	})
+
+	if resp.Speech != nil {
+		fmt.Fprintf(chat.out, "%s\n", resp.Speech.Text)
+	}
+	if resp.EndSession {
+		chat.session = nil
+		fmt.Fprintln(chat.out, "(conversation ended)")
+	} else {
+		chat.session = resp.Session
+	}
+	fmt.Fprintln(chat.out)
+}
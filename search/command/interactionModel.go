@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+
+	"github.com/blevesearch/bleve"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	biblescholar "github.com/turtlemonvh/biblescholar/search"
+	"github.com/turtlemonvh/biblescholar/search/server"
+)
+
+var interactionModelLongDesc = `Prints the alexa interaction model for the skill as JSON.
+
+Intents and sample utterances come from the intents the server handles.
+The BOOK_NAME and TRANSLATION slot types list the distinct Book and Version
+values in the index, with spoken names and abbreviations as synonyms.
+
+Use --locale to generate the model for another language, e.g. de-DE.
+Book synonyms are then the names of the books in that language.
+`
+
+var interactionModelCmd = &cobra.Command{
+	Use:   "interaction-model",
+	Short: "Generate the alexa interaction model from the books and versions in the index",
+	Long:  interactionModelLongDesc,
+	Run: func(cmd *cobra.Command, args []string) {
+		viper.BindPFlag("index-path", cmd.Flags().Lookup("index-path"))
+		viper.BindPFlag("debug-logging", cmd.Flags().Lookup("debug-logging"))
+
+		HandleLogLevel()
+
+		idx, err := bleve.Open(viper.GetString("index-path"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer idx.Close()
+
+		books, err := biblescholar.IndexedTerms(idx, "Book")
+		if err != nil {
+			log.Fatal(err)
+		}
+		versions, err := biblescholar.IndexedTerms(idx, "Version")
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		model, err := server.BuildInteractionModel(mustGetString(cmd, "invocation-name"), mustGetString(cmd, "locale"), books, versions)
+		if err != nil {
+			log.Fatal(err)
+		}
+		contents, err := json.MarshalIndent(model, "", "  ")
+		if err != nil {
+			log.Fatal(err)
+		}
+		contents = append(contents, '\n')
+
+		if output := mustGetString(cmd, "output"); output != "" {
+			err = ioutil.WriteFile(output, contents, 0644)
+		} else {
+			_, err = os.Stdout.Write(contents)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(interactionModelCmd)
+	interactionModelCmd.Flags().String("invocation-name", "bible scholar", "what users say to open the skill")
+	interactionModelCmd.Flags().String("locale", "en-US", "locale of the model. Supported languages are en and de")
+	interactionModelCmd.Flags().StringP("output", "o", "", "file to write the model to. Default is stdout")
+}
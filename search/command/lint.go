@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	biblescholar "github.com/turtlemonvh/biblescholar/search"
+)
+
+var lintLongDesc = `Checks data files without indexing them.
+
+Reads data-dir the same way as the index command and prints every problem as
+<file>:<line>: <reason>, for rows that cannot be read, unknown book names, chapters past
+the end of a book, empty text, duplicate verses and verses out of order. Then
+summarizes how many verses, books and chapters each version has against the canon.
+
+Exits with status 1 if any problem is found. The index command checks the same way
+before writing anything when given --strict.
+`
+
+var lintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "Check data files for problems before indexing them",
+	Long:  lintLongDesc,
+	Run: func(cmd *cobra.Command, args []string) {
+		viper.BindPFlag("data-dir", cmd.Flags().Lookup("data-dir"))
+		viper.BindPFlag("format", cmd.Flags().Lookup("format"))
+		viper.BindPFlag("version", cmd.Flags().Lookup("version"))
+		viper.BindPFlag("debug-logging", cmd.Flags().Lookup("debug-logging"))
+
+		HandleLogLevel()
+
+		sources, err := biblescholar.OpenVerseSources(viper.GetString("data-dir"), viper.GetString("format"), viper.GetString("version"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		linter := biblescholar.NewLinter()
+		for _, source := range sources {
+			linter.Check(source, nil)
+			source.Close()
+		}
+		linter.WriteReport(os.Stdout)
+		if len(linter.Issues) != 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(lintCmd)
+	addDataFlags(lintCmd)
+}
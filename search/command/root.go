@@ -3,12 +3,14 @@ package main
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/blevesearch/bleve"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	biblescholar "github.com/turtlemonvh/biblescholar/search"
+	"github.com/turtlemonvh/biblescholar/search/conversation"
 	"github.com/turtlemonvh/biblescholar/search/server"
 )
 
@@ -30,11 +32,20 @@ func InitializeConfig() {
 	viper.AddConfigPath("$HOME/.bblsearch")
 	viper.AddConfigPath(".")
 
-	viper.SetEnvPrefix("BBLSEARCH_")
+	// e.g. BBLSEARCH_ALEXA_APP_ID for --alexa-app-id
+	viper.SetEnvPrefix("BBLSEARCH")
+	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
 	viper.AutomaticEnv()
+
+	if err := viper.ReadInConfig(); err != nil {
+		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
+			log.Fatal(err)
+		}
+	}
 }
 
 func init() {
+	cobra.OnInitialize(InitializeConfig)
 	RootCmd.AddCommand(indexCmd)
 	RootCmd.AddCommand(serverCmd)
 	searchCmdV = RootCmd
@@ -48,9 +59,54 @@ func init() {
 		fmt.Sprintf("path to bleve index. Default is: %s", biblescholar.DefaultIndexName),
 	)
 	RootCmd.PersistentFlags().Bool("debug-logging", false, "turn on debug level logging")
-	indexCmd.Flags().StringP("data-dir", "d", "downloads", "directory containing tsv data files to use in indexing")
+	addDataFlags(indexCmd)
+	indexCmd.Flags().Bool("strict", false, "check every data file as the lint command does, and index nothing if a problem is found")
+	indexCmd.Flags().Int("read-workers", biblescholar.DefaultIndexOptions.ReadWorkers, "data files to read at once")
+	indexCmd.Flags().Int("parse-workers", biblescholar.DefaultIndexOptions.ParseWorkers, "goroutines turning verses into index batches. Default is the number of CPUs")
+	indexCmd.Flags().Int("write-workers", biblescholar.DefaultIndexOptions.WriteWorkers, "batches to write to the index at once")
+	indexCmd.Flags().Int("batch-bytes", biblescholar.DefaultIndexOptions.BatchBytes, "approximate bytes of verse data in each batch written to the index")
+	indexCmd.Flags().Int("queue-size", biblescholar.DefaultIndexOptions.QueueSize, "verses read ahead of the batches being built; readers wait when it is full")
+	indexCmd.Flags().Duration("progress-interval", biblescholar.DefaultIndexOptions.ProgressInterval, "how often to log indexing progress")
 	serverCmd.Flags().IntP("port", "p", 8000, "port to run server on")
 	serverCmd.Flags().Bool("validate-alexa", false, "should the application validate that requests are from the alexa service?")
+	serverCmd.Flags().StringSlice("alexa-app-id", nil, fmt.Sprintf("alexa skill ids allowed to call the server; may be repeated or comma separated. Default is: %s", server.BibleScholarAppId))
+	serverCmd.Flags().String("alexa-default-version", "", "version to search and read for skills given with --alexa-app-id. Default is all versions")
+	serverCmd.Flags().String("alexa-ca-file", "", "PEM bundle of trusted roots for alexa signing certificates. Default is the system roots")
+	serverCmd.Flags().Duration("alexa-timestamp-tolerance", server.DefaultTimestampTolerance, "reject alexa requests with a timestamp further than this from now")
+	serverCmd.Flags().Duration("alexa-cert-cache-ttl", server.DefaultCertCacheTTL, "how long to cache alexa signing certificates")
+	serverCmd.Flags().String("preferences-db", "", "bolt database file for per user settings such as the preferred translation. Default is to keep them in memory")
+	serverCmd.Flags().String("audio-manifest", "", "json file listing recorded chapters to play with the alexa AudioPlayer. Default is to always read passages")
+	serverCmd.Flags().String("audio-base-url", "", "public https address of this server, used in links to recordings. Default is the host alexa requests are sent to")
+}
+
+// Skills from the 'alexa-skills' list in the config file, plus any given by id on the command line or env
+// e.g. in bblsearch.yaml:
+//
+//	alexa-skills:
+//	  - app-id: amzn1.ask.skill.xxxx
+//	    name: BibleScholar Staging
+//	    default-version: KJV
+func AlexaSkills() ([]*server.SkillProfile, error) {
+	var skills []*server.SkillProfile
+	if err := viper.UnmarshalKey("alexa-skills", &skills); err != nil {
+		return nil, fmt.Errorf("Invalid 'alexa-skills' config: %v", err)
+	}
+	for _, param := range viper.GetStringSlice("alexa-app-id") {
+		for _, appID := range strings.Split(param, ",") {
+			if appID = strings.TrimSpace(appID); appID != "" {
+				skills = append(skills, &server.SkillProfile{
+					AppID:          appID,
+					DefaultVersion: viper.GetString("alexa-default-version"),
+				})
+			}
+		}
+	}
+	for _, skill := range skills {
+		if skill.AppID == "" {
+			return nil, fmt.Errorf("Invalid 'alexa-skills' config: every skill needs an 'app-id'")
+		}
+	}
+	return skills, nil
 }
 
 func HandleLogLevel() {
@@ -65,36 +121,105 @@ var RootCmd = &cobra.Command{
 	Use:   os.Args[0],
 	Short: fmt.Sprintf("%s is a search interface for the Bible", os.Args[0]),
 	Run: func(cmd *cobra.Command, args []string) {
-		InitializeConfig()
 		fmt.Printf("bblsearch %s (%s)\n", buildBranch, buildCommit)
 	},
 }
 
-var indexLongDesc = `Searches data-dir for tsv files to add to index.  Files should look like:
+var indexLongDesc = `Searches data-dir for data files to add to index, picking the format by extension.
+data-dir can also be a single file, a zip archive of data files, or - to read from stdin.
+Files compressed with gzip, e.g. KJV.tsv.gz, are read as the format of the name inside.
+
+Tsv (.tsv) and csv (.csv) files, as written by the scraper, have rows like:
 
 <version>  <Book>  <Chapter #>  <Verse #>  <Verse Text>
+
+A first row naming the columns, starting with "version", is skipped.
+
+JSON Lines files (.jsonl, .ndjson) have one verse per line, e.g.
+
+{"version": "KJV", "book": "John", "chapter": 3, "verse": 16, "text": "For God so loved..."}
+
+USFM files (.usfm, .sfm) hold one or more books, each starting with an \id line.
+USX files (.usx) are the XML form of USFM. Neither names the translation, so give it
+with --version, e.g.
+
+bblsearch index -d downloads/WEB --version WEB
+
+OSIS files (.osis) name their translation in osisIDWork, and Zefania XML files in the
+identifier of their INFORMATION element; --version is only used if they do not. Files
+ending in .xml are read as OSIS, USX or Zefania depending on their root element.
+
+The format of stdin is detected from its content; give it with --format if that fails, e.g.
+
+zcat KJV.tsv.gz | bblsearch index -d - --format tsv
+
+Rows that cannot be read are skipped with a warning. With --strict, every file is checked
+as the lint command does first, and nothing is indexed if a problem is found.
+
+Files are read, turned into batches and written to the index by separate groups of
+workers, sized with --read-workers, --parse-workers and --write-workers. Progress, with
+docs/sec and an ETA when the size of the data is known, is logged every --progress-interval.
 `
 var indexCmd = &cobra.Command{
 	Use:   "index",
-	Short: "Index from a collection of tsv files",
+	Short: "Index from a collection of tsv, csv, JSON Lines, USFM, USX, OSIS or Zefania files",
 	Long:  indexLongDesc,
 	Run: func(cmd *cobra.Command, args []string) {
 		viper.BindPFlag("data-dir", cmd.Flags().Lookup("data-dir"))
+		viper.BindPFlag("format", cmd.Flags().Lookup("format"))
+		viper.BindPFlag("version", cmd.Flags().Lookup("version"))
 		viper.BindPFlag("index-path", cmd.Flags().Lookup("index-path"))
 		viper.BindPFlag("debug-logging", cmd.Flags().Lookup("debug-logging"))
+		for _, name := range []string{"read-workers", "parse-workers", "write-workers", "batch-bytes", "queue-size", "progress-interval"} {
+			viper.BindPFlag(name, cmd.Flags().Lookup(name))
+		}
 
 		HandleLogLevel()
 
+		sources, err := biblescholar.OpenVerseSources(viper.GetString("data-dir"), viper.GetString("format"), viper.GetString("version"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		if mustGetBool(cmd, "strict") {
+			// Check everything before the index is created or changed
+			linter := biblescholar.NewLinter()
+			sources = linter.CheckAll(sources)
+			linter.WriteReport(os.Stdout)
+			if len(linter.Issues) != 0 {
+				log.Fatalf("Found %d problems in the data files; nothing was indexed", len(linter.Issues))
+			}
+		}
+
 		index := biblescholar.CreateOrOpenIndex(viper.GetString("index-path"))
-		fmt.Println("Adding content to: ", viper.GetString("index-path"))
+		log.WithFields(log.Fields{
+			"index":   viper.GetString("index-path"),
+			"sources": len(sources),
+		}).Info("Adding content to index.")
 
-		_, err := biblescholar.IndexFromTSVs(index, viper.GetString("data-dir"))
+		_, err = biblescholar.IndexFromSources(index, sources, &biblescholar.IndexOptions{
+			ReadWorkers:      viper.GetInt("read-workers"),
+			ParseWorkers:     viper.GetInt("parse-workers"),
+			WriteWorkers:     viper.GetInt("write-workers"),
+			BatchBytes:       viper.GetInt("batch-bytes"),
+			QueueSize:        viper.GetInt("queue-size"),
+			ProgressInterval: viper.GetDuration("progress-interval"),
+		})
 		if err != nil {
 			log.Fatal(err)
 		}
+		if err := index.Close(); err != nil {
+			log.Fatal(err)
+		}
 	},
 }
 
+// Flags for reading data files, shared by the index and lint commands
+func addDataFlags(cmd *cobra.Command) {
+	cmd.Flags().StringP("data-dir", "d", "downloads", "directory of data files to read. Can also be a single file, a zip archive, or - for stdin")
+	cmd.Flags().String("format", "", fmt.Sprintf("format of every data file, one of: %s. Default is to pick by extension, or from the content for stdin and .xml files", strings.Join(biblescholar.VerseFormatNames(), ", ")))
+	cmd.Flags().String("version", "", "version abbreviation for files that do not name their translation, e.g. WEB for USFM and USX files")
+}
+
 var serverCmd = &cobra.Command{
 	Use:   "server",
 	Short: "Start a server that fields responses to queries from alexa",
@@ -104,6 +229,14 @@ var serverCmd = &cobra.Command{
 		viper.BindPFlag("index-path", cmd.Flags().Lookup("index-path"))
 		viper.BindPFlag("debug-logging", cmd.Flags().Lookup("debug-logging"))
 		viper.BindPFlag("should-validate-alexa-requests", cmd.Flags().Lookup("validate-alexa"))
+		viper.BindPFlag("alexa-app-id", cmd.Flags().Lookup("alexa-app-id"))
+		viper.BindPFlag("alexa-default-version", cmd.Flags().Lookup("alexa-default-version"))
+		viper.BindPFlag("alexa-ca-file", cmd.Flags().Lookup("alexa-ca-file"))
+		viper.BindPFlag("alexa-timestamp-tolerance", cmd.Flags().Lookup("alexa-timestamp-tolerance"))
+		viper.BindPFlag("alexa-cert-cache-ttl", cmd.Flags().Lookup("alexa-cert-cache-ttl"))
+		viper.BindPFlag("preferences-db", cmd.Flags().Lookup("preferences-db"))
+		viper.BindPFlag("audio-manifest", cmd.Flags().Lookup("audio-manifest"))
+		viper.BindPFlag("audio-base-url", cmd.Flags().Lookup("audio-base-url"))
 
 		HandleLogLevel()
 
@@ -119,12 +252,54 @@ var serverCmd = &cobra.Command{
 			log.Fatal(err)
 		}
 
+		verifier := server.NewAlexaVerifier()
+		verifier.TimestampTolerance = viper.GetDuration("alexa-timestamp-tolerance")
+		verifier.CacheTTL = viper.GetDuration("alexa-cert-cache-ttl")
+		if caFile := viper.GetString("alexa-ca-file"); caFile != "" {
+			verifier.Roots, err = server.LoadCertPool(caFile)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		skills, err := AlexaSkills()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		var preferences conversation.PreferenceStore
+		if path := viper.GetString("preferences-db"); path != "" {
+			store, err := conversation.OpenBoltPreferenceStore(path)
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer store.Close()
+			preferences = store
+		}
+
+		var audio *biblescholar.AudioManifest
+		if path := viper.GetString("audio-manifest"); path != "" {
+			audio, err = biblescholar.LoadAudioManifest(path)
+			if err != nil {
+				log.Fatal(err)
+			}
+			log.WithFields(log.Fields{
+				"manifest":   path,
+				"recordings": len(audio.Recordings),
+			}).Info("Loaded audio manifest")
+		}
+
 		svr := server.ServerConfig{
 			Port:                viper.GetInt("port"),
 			BuildCommit:         buildCommit,
 			BuildBranch:         buildBranch,
 			Index:               idx,
 			ShouldValidateAlexa: viper.GetBool("should-validate-alexa-requests"),
+			AlexaVerifier:       verifier,
+			Skills:              skills,
+			Preferences:         preferences,
+			Audio:               audio,
+			AudioBaseURL:        viper.GetString("audio-base-url"),
 		}
 		svr.StartServer()
 	},
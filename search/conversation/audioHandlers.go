@@ -0,0 +1,121 @@
+package conversation
+
+import (
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+	biblescholar "github.com/turtlemonvh/biblescholar/search"
+)
+
+// Playing recorded chapters on channels that can stream audio
+
+// Recording of a chapter in the version asked for
+// With no version, prefers the user's translation, then the default reading version, then any recorded version
+func (e *Engine) findRecording(t *turn, version string, book string, chapter int) *biblescholar.AudioRecording {
+	if e.Audio == nil {
+		return nil
+	}
+	if version != "" {
+		return e.Audio.Lookup(version, book, chapter)
+	}
+	for _, v := range []string{t.preferredVersion(), defaultReadingVersion} {
+		if rec := e.Audio.Lookup(v, book, chapter); v != "" && rec != nil {
+			return rec
+		}
+	}
+	if recs := e.Audio.ChapterRecordings(book, chapter); len(recs) != 0 {
+		return recs[0]
+	}
+	return nil
+}
+
+// "Play John chapter 3", starting at a verse if one is given and its time is known
+// Passages are read instead when there is no recording or the channel cannot play audio
+func (e *Engine) handlePlayPassageIntent(t *turn) {
+	if t.req.Slot(BookSlot) == "" {
+		t.ask(t.message(msgWhichChapter), t.message(msgWhichChapterShort), t.message(msgHelpTitle, t.name()))
+		return
+	}
+
+	if unclearPassageSlot(t.req) {
+		t.ask(t.message(msgUnclearPassage), t.message(msgWhichChapterShort), t.message(msgHelpTitle, t.name()))
+		return
+	}
+
+	passage, err := passageFromSlots(t.req)
+	if err != nil {
+		t.resp.SetText(t.message(msgBadPassage, err.Error()), t.message(msgPassageNotFoundTitle))
+		return
+	}
+	version := translationFromSlot(t.req)
+
+	start, _ := passage.Bounds()
+	var rec *biblescholar.AudioRecording
+	if t.req.Capabilities.Audio {
+		rec = e.findRecording(t, version, start.Book, start.Chapter)
+	}
+	if rec == nil {
+		log.WithFields(log.Fields{
+			"ref":          passage.String(),
+			"version":      version,
+			"supportAudio": t.req.Capabilities.Audio,
+		}).Info("No recording to play, reading passage instead.")
+		e.readPassage(t, biblescholar.ReferenceList{passage}, version, 0)
+		return
+	}
+
+	offset, found := rec.VerseOffset(start.Verse)
+	var playing biblescholar.Passage = biblescholar.ChapterRange{Book: rec.Book, Start: rec.Chapter, End: rec.Chapter}
+	if found && start.Verse > 1 {
+		playing = start
+	}
+
+	log.WithFields(log.Fields{
+		"ref":     passage.String(),
+		"version": rec.Version,
+		"file":    rec.File,
+		"offset":  offset,
+	}).Info("Playing recording.")
+
+	speech := t.speech()
+	speech.Message(msgPlaying, speech.fragment().Passage(playing), speech.fragment().Version(rec.Version))
+	t.resp.Speech = speech.Speech()
+	t.resp.SetCard(fmt.Sprintf("%s (%s)", playing.String(), rec.Version), t.message(msgPlayingTitle))
+	t.resp.Audio = &AudioAction{Recording: rec, OffsetInMilliseconds: offset}
+}
+
+// "Pause" while a recording plays
+func (e *Engine) handlePauseIntent(t *turn) {
+	t.resp.Audio = &AudioAction{}
+}
+
+// "Resume" picks up where the last recording stopped
+func (e *Engine) handleResumeIntent(t *turn) {
+	if t.req.Playing == nil {
+		t.resp.SetText(t.message(msgNothingToResume), t.message(msgNothingToResumeTitle))
+		return
+	}
+	t.resp.Audio = &AudioAction{Recording: t.req.Playing, OffsetInMilliseconds: t.req.PlayingOffset}
+}
+
+// Next, previous and repeat while a recording plays move by chapter
+// Returns false if no recording has been played
+func (e *Engine) handleAudioNavigationIntent(t *turn) bool {
+	rec := t.req.Playing
+	if rec == nil || e.Audio == nil {
+		return false
+	}
+
+	switch t.req.Intent {
+	case NextIntent:
+		rec = e.Audio.Next(rec)
+	case PreviousIntent:
+		rec = e.Audio.Previous(rec)
+	}
+	if rec == nil {
+		t.resp.SetText(t.message(msgNoRecording), t.message(msgNoRecordingTitle))
+		return true
+	}
+	t.resp.Audio = &AudioAction{Recording: rec}
+	return true
+}
@@ -0,0 +1,165 @@
+package conversation
+
+import (
+	biblescholar "github.com/turtlemonvh/biblescholar/search"
+)
+
+// Answering whether a request could be handled, without handling it
+// Used by channels that route utterances between assistants, e.g. alexa's name-free interaction
+// Nothing may be changed while answering, e.g. no preferences are saved
+
+const (
+	FulfillYes   string = "YES"
+	FulfillNo    string = "NO"
+	FulfillMaybe string = "MAYBE"
+)
+
+// Whether an intent can be fulfilled, and whether each of its filled slots can be understood and fulfilled
+type Fulfillment struct {
+	// FulfillYes, FulfillNo or FulfillMaybe
+	CanFulfill string
+	Slots      map[string]SlotFulfillment
+}
+
+type SlotFulfillment struct {
+	// FulfillYes, FulfillNo or FulfillMaybe
+	CanUnderstand string
+	// FulfillYes or FulfillNo
+	CanFulfill string
+}
+
+type fulfillmentCheck func(e *Engine, t *turn) Fulfillment
+
+// Intents that make sense at the start of a conversation
+// Others, such as SelectChoice or the navigation intents, only mean something within a conversation and are answered with "NO"
+var fulfillmentChecks = map[string]fulfillmentCheck{
+	SearchBibleIntent:    (*Engine).canFulfillSearch,
+	ReadPassageIntent:    (*Engine).canFulfillPassage,
+	PlayPassageIntent:    (*Engine).canFulfillPassage,
+	SetTranslationIntent: (*Engine).canFulfillSetTranslation,
+	GetTranslationIntent: (*Engine).canFulfillGetTranslation,
+}
+
+// Whether the engine could handle a request
+func (e *Engine) CanFulfill(req *Request) Fulfillment {
+	check, ok := fulfillmentChecks[req.Intent]
+	if !ok {
+		return Fulfillment{CanFulfill: FulfillNo}
+	}
+	return check(e, e.newTurn(req))
+}
+
+func newSlotFulfillment(understood bool, fulfilled bool) SlotFulfillment {
+	slot := SlotFulfillment{CanUnderstand: FulfillNo, CanFulfill: FulfillNo}
+	if understood {
+		slot.CanUnderstand = FulfillYes
+		if fulfilled {
+			slot.CanFulfill = FulfillYes
+		}
+	}
+	return slot
+}
+
+// The intent can be fulfilled if every slot was understood and the request as a whole can be fulfilled
+func newFulfillment(slots map[string]SlotFulfillment, fulfilled bool) Fulfillment {
+	f := Fulfillment{CanFulfill: FulfillNo, Slots: slots}
+	for _, slot := range slots {
+		if slot.CanUnderstand == FulfillNo {
+			return f
+		}
+	}
+	if fulfilled {
+		f.CanFulfill = FulfillYes
+	}
+	return f
+}
+
+// Whether a translation is one we know of, and whether it is in the index
+func (e *Engine) translationAnswer(t *turn, translation string) (bool, bool) {
+	version := biblescholar.LookupTranslation(translation)
+	known := biblescholar.TranslationName(version) != version
+	versions, _ := e.availableVersions(t)
+	for _, v := range versions {
+		if v == version {
+			return true, true
+		}
+	}
+	return known, false
+}
+
+// A phrase can be fulfilled if something matches it well; a weak match is a "MAYBE"
+// Spoken references can be fulfilled if the passage is in the index
+func (e *Engine) canFulfillSearch(t *turn) Fulfillment {
+	queryText := t.req.Slot(QuerySlot)
+	if queryText == "" {
+		return newFulfillment(nil, false)
+	}
+
+	found, confident := false, false
+	if refs, err := biblescholar.ParseSpokenReference(queryText); err == nil {
+		verses, err := biblescholar.LookupPassage(e.Index, refs, nil)
+		found = err == nil && len(verses) != 0
+		confident = found
+	} else if hit, err := e.findSearchHit(t, queryText, 0, ""); err == nil && hit.Verse != nil {
+		found = true
+		confident = hit.Confidence >= biblescholar.LowConfidence
+	}
+
+	f := newFulfillment(map[string]SlotFulfillment{
+		QuerySlot: newSlotFulfillment(true, found),
+	}, found)
+	if found && !confident {
+		f.CanFulfill = FulfillMaybe
+	}
+	return f
+}
+
+// A passage can be fulfilled if the book is known, the chapter and verses exist, and the index has them in the version asked for
+func (e *Engine) canFulfillPassage(t *turn) Fulfillment {
+	slots := make(map[string]SlotFulfillment)
+	if t.req.Slot(BookSlot) == "" {
+		return newFulfillment(slots, false)
+	}
+
+	var versions []string
+	translationOK := true
+	if translation := t.req.Slot(TranslationSlot); translation != "" {
+		known, indexed := e.translationAnswer(t, translation)
+		slots[TranslationSlot] = newSlotFulfillment(known || indexed, indexed)
+		translationOK = indexed
+		versions = []string{biblescholar.LookupTranslation(translation)}
+	}
+
+	found := false
+	if passage, err := passageFromSlots(t.req); err == nil {
+		verses, err := biblescholar.LookupPassage(e.Index, biblescholar.ReferenceList{passage}, versions)
+		found = err == nil && len(verses) != 0
+	}
+
+	_, bookErr := biblescholar.LookupBook(t.req.Slot(BookSlot))
+	slots[BookSlot] = newSlotFulfillment(bookErr == nil, found)
+	for _, slot := range []string{ChapterSlot, StartVerseSlot, EndVerseSlot} {
+		if t.req.Slot(slot) == "" {
+			continue
+		}
+		_, err := t.req.SlotNumber(slot)
+		slots[slot] = newSlotFulfillment(err == nil, found)
+	}
+	return newFulfillment(slots, found && translationOK)
+}
+
+// Without a translation, the user is asked which one, so that can be fulfilled too
+func (e *Engine) canFulfillSetTranslation(t *turn) Fulfillment {
+	translation := t.req.Slot(TranslationSlot)
+	if translation == "" {
+		return newFulfillment(nil, true)
+	}
+	known, indexed := e.translationAnswer(t, translation)
+	return newFulfillment(map[string]SlotFulfillment{
+		TranslationSlot: newSlotFulfillment(known || indexed, indexed),
+	}, indexed)
+}
+
+func (e *Engine) canFulfillGetTranslation(t *turn) Fulfillment {
+	return newFulfillment(nil, true)
+}
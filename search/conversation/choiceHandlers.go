@@ -0,0 +1,155 @@
+package conversation
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blevesearch/bleve"
+	log "github.com/sirupsen/logrus"
+	biblescholar "github.com/turtlemonvh/biblescholar/search"
+)
+
+// Letting the user pick between search results that match about equally well, e.g. parallel gospel passages
+
+const (
+	// Session attribute holding the offered references, e.g. "Matthew 5:44; Luke 6:27"
+	choicesAttribute string = "choices"
+	// Version of the best hit for each choice, comma separated
+	choiceVersionsAttribute string = "choiceVersions"
+	// Hits scoring within this fraction of the best hit are offered as choices
+	choiceScoreMargin float64 = 0.1
+	// Most choices to read out; more than this is hard to remember
+	maxChoices int = 3
+	// Hits fetched to look for close references, enough to cover one verse across several versions
+	choiceCandidates int = 12
+)
+
+// Best hit for each distinct reference that scores close to the top hit, in score order
+// Returns fewer than two verses when there is a clear best match
+func (e *Engine) closeSearchChoices(t *turn, queryText string) ([]*biblescholar.Verse, error) {
+	q := biblescholar.LimitToVersions(bleve.NewQueryStringQuery(queryText), t.searchVersions())
+	searchRequest := bleve.NewSearchRequestOptions(q, choiceCandidates, 0, false)
+	searchRequest.Fields = []string{
+		"Version",
+		"Book",
+		"Chapter",
+		"Verse",
+		"Text",
+	}
+	searchResult, err := e.Index.Search(searchRequest)
+	if err != nil {
+		return nil, err
+	}
+	if searchResult.Hits.Len() < 2 {
+		return nil, nil
+	}
+
+	// A weak best match is handled by fuzzy matching instead
+	top := biblescholar.NewVerseFromFields(searchResult.Hits[0].Fields)
+	if biblescholar.PhraseConfidence(queryText, top.Text) < biblescholar.LowConfidence {
+		return nil, nil
+	}
+
+	// Hits are in score order, so the first hit for each reference is its best
+	var refs biblescholar.ReferenceList
+	var choices []*biblescholar.Verse
+	minScore := searchResult.Hits[0].Score * (1 - choiceScoreMargin)
+	for _, hit := range searchResult.Hits {
+		if hit.Score < minScore || len(choices) == maxChoices {
+			break
+		}
+		verse := biblescholar.NewVerseFromFields(hit.Fields)
+		if !refs.Contains(verse.Ref()) {
+			refs = append(refs, verse.Ref())
+			choices = append(choices, verse)
+		}
+	}
+	return choices, nil
+}
+
+// List the choices and ask which one to read
+// The search is kept in the session too, so "next" still pages through the results
+func (e *Engine) respondWithChoices(t *turn, queryText string, verses []*biblescholar.Verse) {
+	resp := t.resp
+	speech := t.speech().Message(msgChoicesIntro, len(verses))
+	var choices biblescholar.ReferenceList
+	var versions, lines []string
+	for i, v := range verses {
+		if i == len(verses)-1 {
+			speech.word(msgOr).Passage(v.Ref()).Text(".")
+		} else {
+			speech.Passage(v.Ref()).Text(",")
+		}
+		choices = append(choices, v.Ref())
+		versions = append(versions, v.Version)
+		lines = append(lines, fmt.Sprintf("%d. %s", i+1, v.Ref().String()))
+	}
+	speech.Message(msgChoicesQuestion)
+
+	resp.EndSession = false
+	resp.SetAttribute(choicesAttribute, choices.String())
+	resp.SetAttribute(choiceVersionsAttribute, strings.Join(versions, ","))
+	resp.SetAttribute(searchQueryAttribute, queryText)
+	resp.SetAttribute(searchOffsetAttribute, 0)
+	resp.SetAttribute(searchModeAttribute, exactSearchMode)
+	resp.Speech = speech.Speech()
+	resp.SetCard(t.message(msgChoicesTitle, queryText), strings.Join(lines, "\n"))
+	resp.SetReprompt(t.message(msgChoicesReprompt))
+	if t.req.Capabilities.Display {
+		resp.Display = &Display{
+			Type:     ChoicesDisplay,
+			Title:    t.message(msgChoicesTitle, queryText),
+			Subtitle: t.message(msgChoicesShort),
+			Verses:   verses,
+			Query:    queryText,
+			Current:  -1,
+		}
+	}
+
+	log.WithFields(log.Fields{
+		"query":   queryText,
+		"choices": choices.String(),
+	}).Info("Offered choices for close matches.")
+}
+
+// "The first one" or "Matthew", after being offered choices
+func (e *Engine) handleSelectChoiceIntent(t *turn) {
+	choices, err := biblescholar.ParseReference(t.req.AttributeString(choicesAttribute))
+	if err != nil {
+		// Nothing was offered
+		e.handleLaunchIntent(t)
+		return
+	}
+
+	versions := strings.Split(t.req.AttributeString(choiceVersionsAttribute), ",")
+
+	choice := -1
+	if n, err := t.req.SlotNumber(OrdinalSlot); err == nil && n >= 1 && n <= len(choices) {
+		choice = n - 1
+	} else if book, err := biblescholar.LookupBook(t.req.Slot(BookSlot)); err == nil {
+		for i, ref := range choices {
+			if start, _ := ref.Bounds(); start.Book == book.Name {
+				choice = i
+				break
+			}
+		}
+	}
+
+	if choice == -1 {
+		// Ask again, keeping the choices
+		t.keepSession()
+		t.ask(
+			t.message(msgChoicesRetry, strings.Replace(choices.String(), ";", ",", -1)),
+			t.message(msgChoicesReprompt),
+			t.message(msgChooseTitle),
+		)
+		return
+	}
+
+	// Read the version that matched, which may not be the one the user would otherwise hear
+	version := ""
+	if len(versions) == len(choices) {
+		version = versions[choice]
+	}
+	e.readPassage(t, biblescholar.ReferenceList{choices[choice]}, version, 0)
+}
@@ -0,0 +1,195 @@
+package conversation
+
+import (
+	"sync"
+
+	"github.com/blevesearch/bleve"
+	log "github.com/sirupsen/logrus"
+	biblescholar "github.com/turtlemonvh/biblescholar/search"
+)
+
+// The conversation itself: searching, reading and remembering preferences, whatever the channel
+
+const (
+	// Intents
+	LaunchIntent         string = "Launch"
+	SearchBibleIntent    string = "SearchBible"
+	ReadPassageIntent    string = "ReadPassage"
+	PlayPassageIntent    string = "PlayPassage"
+	SetTranslationIntent string = "SetTranslation"
+	GetTranslationIntent string = "GetTranslation"
+	SelectChoiceIntent   string = "SelectChoice"
+	NextIntent           string = "Next"
+	PreviousIntent       string = "Previous"
+	RepeatIntent         string = "Repeat"
+	YesIntent            string = "Yes"
+	NoIntent             string = "No"
+	HelpIntent           string = "Help"
+	FallbackIntent       string = "Fallback"
+	HomeIntent           string = "Home"
+	StopIntent           string = "Stop"
+	PauseIntent          string = "Pause"
+	ResumeIntent         string = "Resume"
+	// Slots
+	QuerySlot       string = "QueryPhrase"
+	BookSlot        string = "Book"
+	ChapterSlot     string = "Chapter"
+	StartVerseSlot  string = "StartVerse"
+	EndVerseSlot    string = "EndVerse"
+	TranslationSlot string = "Translation"
+	OrdinalSlot     string = "Ordinal"
+	// Used in prompts when the channel does not give a name
+	defaultName string = "BibleScholar"
+)
+
+// Answers requests from every channel
+type Engine struct {
+	Index bleve.Index
+	// Per user settings such as the preferred translation; kept in memory if nil
+	Preferences PreferenceStore
+	// Recorded chapters to play instead of reading; passages are always read if nil
+	Audio *biblescholar.AudioManifest
+
+	preferencesOnce sync.Once
+}
+
+// Responds to one intent; the response starts out ending the conversation
+type intentHandler func(e *Engine, t *turn)
+
+// Handlers by intent name; add new intents here
+var intentHandlers = map[string]intentHandler{
+	LaunchIntent:         (*Engine).handleLaunchIntent,
+	SearchBibleIntent:    (*Engine).handleSearchBibleIntent,
+	ReadPassageIntent:    (*Engine).handleReadPassageIntent,
+	SetTranslationIntent: (*Engine).handleSetTranslationIntent,
+	GetTranslationIntent: (*Engine).handleGetTranslationIntent,
+	SelectChoiceIntent:   (*Engine).handleSelectChoiceIntent,
+	PlayPassageIntent:    (*Engine).handlePlayPassageIntent,
+	PauseIntent:          (*Engine).handlePauseIntent,
+	ResumeIntent:         (*Engine).handleResumeIntent,
+	NextIntent:           (*Engine).handleSearchNavigationIntent,
+	PreviousIntent:       (*Engine).handleSearchNavigationIntent,
+	RepeatIntent:         (*Engine).handleSearchNavigationIntent,
+	// Only asked when there is more of a passage to read
+	YesIntent:      (*Engine).handleContinuePassageIntent,
+	NoIntent:       (*Engine).handleStopIntent,
+	HelpIntent:     (*Engine).handleHelpIntent,
+	FallbackIntent: (*Engine).handleFallbackIntent,
+	HomeIntent:     (*Engine).handleLaunchIntent,
+	StopIntent:     (*Engine).handleStopIntent,
+}
+
+// Whether the engine has a handler for an intent
+func HasIntent(intent string) bool {
+	_, ok := intentHandlers[intent]
+	return ok
+}
+
+// One request being answered, with what was looked up for it
+type turn struct {
+	req  *Request
+	resp *Response
+	// Version the user picked, or ""
+	userVersion string
+	// Version to search for the user's language, or ""
+	localeVersion string
+}
+
+// Answer one request
+func (e *Engine) Handle(req *Request) *Response {
+	t := e.newTurn(req)
+	handler, ok := intentHandlers[req.Intent]
+	if !ok {
+		handler = (*Engine).handleUnknownIntent
+	}
+	handler(e, t)
+	return t.resp
+}
+
+func (e *Engine) newTurn(req *Request) *turn {
+	return &turn{
+		req:           req,
+		resp:          &Response{EndSession: true},
+		userVersion:   e.userTranslation(req.UserID),
+		localeVersion: e.localeVersion(req.Locale),
+	}
+}
+
+func (e *Engine) preferences() PreferenceStore {
+	e.preferencesOnce.Do(func() {
+		if e.Preferences == nil {
+			e.Preferences = NewMemoryPreferenceStore()
+		}
+	})
+	return e.Preferences
+}
+
+// Name used in prompts and card titles
+func (t *turn) name() string {
+	if t.req.Name == "" {
+		return defaultName
+	}
+	return t.req.Name
+}
+
+// Speech in the request's locale
+func (t *turn) speech() *speechBuilder {
+	return newSpeech(t.req.Locale)
+}
+
+// Ask a question and keep the conversation open for the answer
+// The reprompt is said if the user does not answer; the card shows the full text
+func (t *turn) ask(txt string, reprompt string, title string) {
+	t.resp.EndSession = false
+	t.resp.SetText(txt, title)
+	t.resp.SetReprompt(reprompt)
+}
+
+// Explain that something went wrong on our side
+func (t *turn) fail(err error) {
+	t.resp.Err = err
+	t.resp.SetText(t.message(msgInternalError), t.message(msgErrorTitle))
+}
+
+// Carry over anything in progress, e.g. a search to page through
+func (t *turn) keepSession() {
+	for key, value := range t.req.Session {
+		t.resp.SetAttribute(key, value)
+	}
+}
+
+// Handlers
+
+// Prompt for a search, e.g. at the start of a conversation or when there is nothing to continue
+// Keeps the conversation open for the answer
+func (e *Engine) handleLaunchIntent(t *turn) {
+	prompt := t.message(msgPrompt, t.name())
+	t.ask(prompt, prompt, t.message(msgHelpTitle, t.name()))
+}
+
+func (e *Engine) handleStopIntent(t *turn) {
+	t.resp.EndSession = true
+}
+
+// Explain everything the assistant can do
+// Anything in progress is kept, so the user can carry on afterwards
+func (e *Engine) handleHelpIntent(t *turn) {
+	t.keepSession()
+	t.ask(t.message(msgHelp, t.name()), t.message(msgHelpReprompt), t.message(msgHelpTitle, t.name()))
+}
+
+// Sent when the channel could not match what the user said to an intent
+func (e *Engine) handleFallbackIntent(t *turn) {
+	t.keepSession()
+	t.ask(t.message(msgFallback), t.message(msgTryReprompt), t.message(msgHelpTitle, t.name()))
+}
+
+// Intents a channel knows about but this version of the engine does not
+// Answered with help rather than an error, which some channels treat as a failure
+func (e *Engine) handleUnknownIntent(t *turn) {
+	log.WithFields(log.Fields{
+		"channel":        t.req.Channel,
+		"intentReceived": t.req.Intent,
+	}).Warn("No handler for intent")
+	t.ask(t.message(msgUnknownIntent), t.message(msgTryReprompt), t.message(msgHelpTitle, t.name()))
+}
@@ -0,0 +1,352 @@
+package conversation
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	biblescholar "github.com/turtlemonvh/biblescholar/search"
+)
+
+// Everything the assistant says or shows, by locale
+// https://developer.amazon.com/docs/custom-skills/develop-skills-in-multiple-languages.html
+// Templates are fmt format strings; use numbered arguments, e.g. "%[1]s", so a translation can reorder or leave them out
+
+const (
+	// Used for any locale without a catalog, and for ids a catalog is missing
+	defaultLanguage string = "en"
+)
+
+// Message ids
+const (
+	// General
+	msgPrompt        string = "prompt"
+	msgHelpTitle     string = "helpTitle"
+	msgHelp          string = "help"
+	msgHelpReprompt  string = "helpReprompt"
+	msgFallback      string = "fallback"
+	msgTryReprompt   string = "tryReprompt"
+	msgUnknownIntent string = "unknownIntent"
+	msgInternalError string = "internalError"
+	msgErrorTitle    string = "errorTitle"
+	msgAnd           string = "and"
+	msgOr            string = "or"
+	// Spoken passages, e.g. "John chapter 3 verses 16 through 18"
+	msgChapter  string = "chapter"
+	msgChapters string = "chapters"
+	msgVerse    string = "verse"
+	msgVerses   string = "verses"
+	msgThrough  string = "through"
+	msgFirst    string = "first"
+	msgSecond   string = "second"
+	msgThird    string = "third"
+	// Searching
+	msgNoResults       string = "noResults"
+	msgNoResultsTitle  string = "noResultsTitle"
+	msgLastResult      string = "lastResult"
+	msgLastResultTitle string = "lastResultTitle"
+	msgBestMatch       string = "bestMatch"
+	msgClosestMatch    string = "closestMatch"
+	msgCloseMatchN     string = "closeMatchN"
+	msgResultN         string = "resultN"
+	msgNextHint        string = "nextHint"
+	msgNextReprompt    string = "nextReprompt"
+	msgMatchTitle      string = "matchTitle"
+	msgResultsTitle    string = "resultsTitle"
+	msgResultsRange    string = "resultsRange"
+	// Choosing between close matches
+	msgChoicesIntro    string = "choicesIntro"
+	msgChoicesQuestion string = "choicesQuestion"
+	msgChoicesReprompt string = "choicesReprompt"
+	msgChoicesTitle    string = "choicesTitle"
+	msgChoicesShort    string = "choicesShort"
+	msgChoicesRetry    string = "choicesRetry"
+	msgChooseTitle     string = "chooseTitle"
+	// Reading passages
+	msgWhichPassage         string = "whichPassage"
+	msgUnclearPassage       string = "unclearPassage"
+	msgBadPassage           string = "badPassage"
+	msgPassageNotFoundTitle string = "passageNotFoundTitle"
+	msgPassageNotFound      string = "passageNotFound"
+	msgVersionNotFound      string = "versionNotFound"
+	msgReading              string = "reading"
+	msgContinuing           string = "continuing"
+	msgVerseNumber          string = "verseNumber"
+	msgKeepReading          string = "keepReading"
+	msgKeepReadingHint      string = "keepReadingHint"
+	// Translations
+	msgTranslationTitle    string = "translationTitle"
+	msgWhichTranslation    string = "whichTranslation"
+	msgTranslationExample  string = "translationExample"
+	msgTranslationQuestion string = "translationQuestion"
+	msgMissingTranslation  string = "missingTranslation"
+	msgSaveError           string = "saveError"
+	msgTranslationSaved    string = "translationSaved"
+	msgTranslationCard     string = "translationCard"
+	msgWhatNext            string = "whatNext"
+	msgUserTranslation     string = "userTranslation"
+	msgSkillTranslation    string = "skillTranslation"
+	msgNoTranslation       string = "noTranslation"
+	// Recordings
+	msgWhichChapter         string = "whichChapter"
+	msgWhichChapterShort    string = "whichChapterShort"
+	msgPlaying              string = "playing"
+	msgPlayingTitle         string = "playingTitle"
+	msgNothingToResume      string = "nothingToResume"
+	msgNothingToResumeTitle string = "nothingToResumeTitle"
+	msgNoRecording          string = "noRecording"
+	msgNoRecordingTitle     string = "noRecordingTitle"
+)
+
+// Catalogs by language or full locale; a full locale, e.g. "en-GB", only needs the ids that differ from its language
+var messageCatalogs = map[string]map[string]string{
+	"en": {
+		msgPrompt:        "Ask %[1]s to 'search for' or 'lookup' a phrase.",
+		msgHelpTitle:     "%[1]s Help",
+		msgHelp:          "%[1]s can find verses and read passages. To find a verse, say 'search for' and a phrase, like 'search for love your neighbor'. After a search, say 'next' or 'previous' to move through the matches. To hear a passage, say 'read' and a reference, like 'read John chapter 3 verse 16'. What would you like to do?",
+		msgHelpReprompt:  "What would you like to do? You can search for a phrase or read a passage.",
+		msgFallback:      "Sorry, I didn't get that. You can say 'search for' and a phrase, or 'read' and a passage like 'read Psalm 23'.",
+		msgTryReprompt:   "You can say 'search for' and a phrase, or 'read' and a passage.",
+		msgUnknownIntent: "Sorry, I can't do that yet. You can say 'search for' and a phrase, or 'read' and a passage.",
+		msgInternalError: "Bible Scholar is experiencing internal errors. Please try again later.",
+		msgErrorTitle:    "Processing Error",
+		msgAnd:           "and",
+		msgOr:            "or",
+
+		msgChapter:  "chapter",
+		msgChapters: "chapters",
+		msgVerse:    "verse",
+		msgVerses:   "verses",
+		msgThrough:  "through",
+		msgFirst:    "First",
+		msgSecond:   "Second",
+		msgThird:    "Third",
+
+		msgNoResults:       "We didn't find any verses matching that phrase. Try a shorter phrase, or try rewording your search phrase.",
+		msgNoResultsTitle:  "No results found",
+		msgLastResult:      "That was the last result. Say 'previous' to go back, or search for another phrase.",
+		msgLastResultTitle: "No more results",
+		msgBestMatch:       "Best match is from %[1]s from the %[2]s translation.",
+		msgClosestMatch:    "I couldn't find that exact phrase. The closest match is from %[1]s from the %[2]s translation.",
+		msgCloseMatchN:     "Close match %[3]d of %[4]d is from %[1]s from the %[2]s translation.",
+		msgResultN:         "Result %[3]d of %[4]d is from %[1]s from the %[2]s translation.",
+		msgNextHint:        "Say 'next' to hear another match.",
+		msgNextReprompt:    "Say 'next' to hear another match, or 'stop' to finish.",
+		msgMatchTitle:      "%[1]s - %[2].0f%% match",
+		msgResultsTitle:    "Results for \"%[1]s\"",
+		msgResultsRange:    "%[1]d-%[2]d of %[3]d",
+
+		msgChoicesIntro:    "I found %[1]d close matches:",
+		msgChoicesQuestion: "Which one would you like? You can say 'the first one', or the name of the book.",
+		msgChoicesReprompt: "Which one would you like? Say 'the first one', 'the second one', or the name of the book.",
+		msgChoicesTitle:    "Close matches for \"%[1]s\"",
+		msgChoicesShort:    "Which one would you like?",
+		msgChoicesRetry:    "Sorry, which one? The choices are %[1]s.",
+		msgChooseTitle:     "Choose a match",
+
+		msgWhichPassage:         "Which passage would you like me to read? For example, say 'read John chapter 3'.",
+		msgUnclearPassage:       "Sorry, I didn't catch the chapter or verse. Please say the passage again, for example 'John chapter 3 verse 16'.",
+		msgBadPassage:           "I couldn't find that passage. %[1]s.",
+		msgPassageNotFoundTitle: "Passage not found",
+		msgPassageNotFound:      "I couldn't find %[1]s.",
+		msgVersionNotFound:      "I couldn't find %[1]s in the %[2]s translation.",
+		msgReading:              "Reading %[1]s from the %[2]s translation.",
+		msgContinuing:           "Continuing from chapter %[1]s verse %[2]s.",
+		msgVerseNumber:          "Verse %[1]s.",
+		msgKeepReading:          "Would you like me to keep reading?",
+		msgKeepReadingHint:      "say 'yes' to keep reading",
+
+		msgTranslationTitle:    "%[1]s Translation",
+		msgWhichTranslation:    "Which translation would you like? I have the %[1]s.",
+		msgTranslationExample:  "Which translation would you like? For example, say 'use the King James'.",
+		msgTranslationQuestion: "Which translation would you like?",
+		msgMissingTranslation:  "Sorry, I don't have the %[1]s translation. I have the %[2]s. Which would you like?",
+		msgSaveError:           "Sorry, I couldn't save that. Please try again later.",
+		msgTranslationSaved:    "OK, from now on I'll use the %[1]s.",
+		msgTranslationCard:     "Using the %[1]s (%[2]s)",
+		msgWhatNext:            "What would you like to hear?",
+		msgUserTranslation:     "You're using the %[1]s. To change it, say 'use the' and a translation.",
+		msgSkillTranslation:    "I'm using the %[1]s. To change it, say 'use the' and a translation.",
+		msgNoTranslation:       "You haven't picked a translation, so I search the %[1]s. To pick one, say 'use the' and a translation.",
+
+		msgWhichChapter:         "Which chapter would you like me to play? For example, say 'play John chapter 3'.",
+		msgWhichChapterShort:    "Which chapter would you like me to play?",
+		msgPlaying:              "Playing %[1]s from the %[2]s translation.",
+		msgPlayingTitle:         "Playing recording",
+		msgNothingToResume:      "There is nothing to resume. Ask me to play a chapter, for example 'play Psalm 23'.",
+		msgNothingToResumeTitle: "Nothing to resume",
+		msgNoRecording:          "I don't have a recording of that chapter.",
+		msgNoRecordingTitle:     "Recording not found",
+	},
+	"de": {
+		msgPrompt:        "Bitte %[1]s, nach einem Satz zu suchen, zum Beispiel 'suche nach liebe deinen Nächsten'.",
+		msgHelpTitle:     "%[1]s Hilfe",
+		msgHelp:          "%[1]s findet Verse und liest Bibelstellen vor. Um einen Vers zu finden, sag 'suche nach' und einen Satz, zum Beispiel 'suche nach liebe deinen Nächsten'. Nach einer Suche kannst du mit 'weiter' oder 'zurück' durch die Treffer gehen. Um eine Stelle zu hören, sag 'lies' und die Stelle, zum Beispiel 'lies Johannes Kapitel 3 Vers 16'. Was möchtest du tun?",
+		msgHelpReprompt:  "Was möchtest du tun? Du kannst nach einem Satz suchen oder eine Stelle vorlesen lassen.",
+		msgFallback:      "Entschuldigung, das habe ich nicht verstanden. Sag 'suche nach' und einen Satz, oder 'lies' und eine Stelle, zum Beispiel 'lies Psalm 23'.",
+		msgTryReprompt:   "Sag 'suche nach' und einen Satz, oder 'lies' und eine Stelle.",
+		msgUnknownIntent: "Das kann ich leider noch nicht. Sag 'suche nach' und einen Satz, oder 'lies' und eine Stelle.",
+		msgInternalError: "Bible Scholar hat gerade technische Probleme. Bitte versuche es später noch einmal.",
+		msgErrorTitle:    "Verarbeitungsfehler",
+		msgAnd:           "und",
+		msgOr:            "oder",
+
+		msgChapter:  "Kapitel",
+		msgChapters: "Kapitel",
+		msgVerse:    "Vers",
+		msgVerses:   "Verse",
+		msgThrough:  "bis",
+		msgFirst:    "Erster",
+		msgSecond:   "Zweiter",
+		msgThird:    "Dritter",
+
+		msgNoResults:       "Ich habe keine Verse zu diesem Satz gefunden. Versuche es mit einem kürzeren oder anders formulierten Satz.",
+		msgNoResultsTitle:  "Keine Ergebnisse",
+		msgLastResult:      "Das war das letzte Ergebnis. Sag 'zurück', um zurückzugehen, oder suche nach einem anderen Satz.",
+		msgLastResultTitle: "Keine weiteren Ergebnisse",
+		msgBestMatch:       "Der beste Treffer ist %[1]s aus der Übersetzung %[2]s.",
+		msgClosestMatch:    "Genau diesen Satz habe ich nicht gefunden. Am ähnlichsten ist %[1]s aus der Übersetzung %[2]s.",
+		msgCloseMatchN:     "Ähnlicher Treffer %[3]d von %[4]d ist %[1]s aus der Übersetzung %[2]s.",
+		msgResultN:         "Ergebnis %[3]d von %[4]d ist %[1]s aus der Übersetzung %[2]s.",
+		msgNextHint:        "Sag 'weiter', um einen weiteren Treffer zu hören.",
+		msgNextReprompt:    "Sag 'weiter' für einen weiteren Treffer, oder 'stopp' zum Beenden.",
+		msgMatchTitle:      "%[1]s - %[2].0f%% Übereinstimmung",
+		msgResultsTitle:    "Ergebnisse für „%[1]s“",
+		msgResultsRange:    "%[1]d-%[2]d von %[3]d",
+
+		msgChoicesIntro:    "Ich habe %[1]d ähnliche Treffer gefunden:",
+		msgChoicesQuestion: "Welchen möchtest du hören? Sag zum Beispiel 'den ersten', oder den Namen des Buches.",
+		msgChoicesReprompt: "Welchen möchtest du hören? Sag 'den ersten', 'den zweiten', oder den Namen des Buches.",
+		msgChoicesTitle:    "Ähnliche Treffer für „%[1]s“",
+		msgChoicesShort:    "Welchen möchtest du hören?",
+		msgChoicesRetry:    "Entschuldigung, welchen? Zur Auswahl stehen %[1]s.",
+		msgChooseTitle:     "Treffer auswählen",
+
+		msgWhichPassage:   "Welche Stelle soll ich vorlesen? Sag zum Beispiel 'lies Johannes Kapitel 3'.",
+		msgUnclearPassage: "Entschuldigung, das Kapitel oder den Vers habe ich nicht verstanden. Bitte sag die Stelle noch einmal, zum Beispiel 'Johannes Kapitel 3 Vers 16'.",
+		// The reason comes from the book table, which is in English
+		msgBadPassage:           "Diese Stelle habe ich nicht gefunden.",
+		msgPassageNotFoundTitle: "Stelle nicht gefunden",
+		msgPassageNotFound:      "Ich habe %[1]s nicht gefunden.",
+		msgVersionNotFound:      "Ich habe %[1]s in der Übersetzung %[2]s nicht gefunden.",
+		msgReading:              "Ich lese %[1]s aus der Übersetzung %[2]s.",
+		msgContinuing:           "Weiter ab Kapitel %[1]s Vers %[2]s.",
+		msgVerseNumber:          "Vers %[1]s.",
+		msgKeepReading:          "Soll ich weiterlesen?",
+		msgKeepReadingHint:      "sag 'ja', um weiterzulesen",
+
+		msgTranslationTitle:    "%[1]s Übersetzung",
+		msgWhichTranslation:    "Welche Übersetzung möchtest du? Ich habe %[1]s.",
+		msgTranslationExample:  "Welche Übersetzung möchtest du? Sag zum Beispiel 'nimm die Lutherbibel'.",
+		msgTranslationQuestion: "Welche Übersetzung möchtest du?",
+		msgMissingTranslation:  "Die Übersetzung %[1]s habe ich leider nicht. Ich habe %[2]s. Welche möchtest du?",
+		msgSaveError:           "Das konnte ich leider nicht speichern. Bitte versuche es später noch einmal.",
+		msgTranslationSaved:    "OK, ab jetzt verwende ich die Übersetzung %[1]s.",
+		msgTranslationCard:     "Übersetzung: %[1]s (%[2]s)",
+		msgWhatNext:            "Was möchtest du hören?",
+		msgUserTranslation:     "Du verwendest die Übersetzung %[1]s. Um sie zu ändern, sag 'nimm die' und eine Übersetzung.",
+		msgSkillTranslation:    "Ich verwende die Übersetzung %[1]s. Um sie zu ändern, sag 'nimm die' und eine Übersetzung.",
+		msgNoTranslation:       "Du hast keine Übersetzung gewählt, also durchsuche ich %[1]s. Um eine zu wählen, sag 'nimm die' und eine Übersetzung.",
+
+		msgWhichChapter:         "Welches Kapitel soll ich abspielen? Sag zum Beispiel 'spiele Johannes Kapitel 3'.",
+		msgWhichChapterShort:    "Welches Kapitel soll ich abspielen?",
+		msgPlaying:              "Ich spiele %[1]s aus der Übersetzung %[2]s.",
+		msgPlayingTitle:         "Aufnahme wird abgespielt",
+		msgNothingToResume:      "Es gibt nichts fortzusetzen. Bitte mich, ein Kapitel abzuspielen, zum Beispiel 'spiele Psalm 23'.",
+		msgNothingToResumeTitle: "Nichts fortzusetzen",
+		msgNoRecording:          "Von diesem Kapitel habe ich keine Aufnahme.",
+		msgNoRecordingTitle:     "Keine Aufnahme",
+	},
+}
+
+// Versions to search by default for a language or locale, in order of preference
+// The first one in the index is used; languages without an entry search every version
+var localeVersions = map[string][]string{
+	"de": {"LUT", "ELB", "SCH"},
+}
+
+// A missing message would be spoken as its id, so every language must have everything the default does
+func init() {
+	for lang, catalog := range messageCatalogs {
+		if strings.Contains(lang, "-") {
+			continue
+		}
+		var missing []string
+		for id := range messageCatalogs[defaultLanguage] {
+			if _, ok := catalog[id]; !ok {
+				missing = append(missing, id)
+			}
+		}
+		if len(missing) != 0 {
+			sort.Strings(missing)
+			panic(fmt.Sprintf("Messages for '%s' are missing: %s", lang, strings.Join(missing, ", ")))
+		}
+	}
+}
+
+// Language of a locale, e.g. "de-DE" -> "de"
+func localeLanguage(locale string) string {
+	return strings.ToLower(strings.SplitN(locale, "-", 2)[0])
+}
+
+// Template for a message, looked up by full locale, then language, then in the default language
+func messageTemplate(locale string, id string) string {
+	for _, key := range []string{locale, localeLanguage(locale), defaultLanguage} {
+		if tmpl, ok := messageCatalogs[key][id]; ok {
+			return tmpl
+		}
+	}
+	return id
+}
+
+// Fill in a template; templates without verbs are returned as is, since they leave out all their arguments
+func formatMessage(tmpl string, args ...interface{}) string {
+	if len(args) == 0 || !strings.Contains(tmpl, "%") {
+		return tmpl
+	}
+	return fmt.Sprintf(tmpl, args...)
+}
+
+// Plain text of a message in the request's locale, for cards and plain text speech
+func (t *turn) message(id string, args ...interface{}) string {
+	return formatMessage(messageTemplate(t.req.Locale, id), args...)
+}
+
+// Join names into a list the way they would be said, e.g. "ESV, KJV and NIV"
+func (t *turn) joinList(id string, names []string) string {
+	switch len(names) {
+	case 0:
+		return ""
+	case 1:
+		return names[0]
+	default:
+		return strings.Join(names[:len(names)-1], ", ") + " " + t.message(id) + " " + names[len(names)-1]
+	}
+}
+
+// Version to search for the request's locale, the first of localeVersions that is indexed, or ""
+func (e *Engine) localeVersion(locale string) string {
+	candidates, ok := localeVersions[locale]
+	if !ok {
+		candidates = localeVersions[localeLanguage(locale)]
+	}
+	if len(candidates) == 0 {
+		return ""
+	}
+	versions, err := biblescholar.IndexedTerms(e.Index, "Version")
+	if err != nil {
+		log.WithFields(log.Fields{
+			"err": err,
+		}).Error("Error while listing indexed versions.")
+		return ""
+	}
+	for _, candidate := range candidates {
+		for _, version := range versions {
+			if version == candidate {
+				return version
+			}
+		}
+	}
+	return ""
+}
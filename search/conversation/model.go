@@ -0,0 +1,148 @@
+package conversation
+
+import (
+	"strconv"
+	"strings"
+
+	biblescholar "github.com/turtlemonvh/biblescholar/search"
+)
+
+// Channel independent requests and responses
+// Each channel, e.g. alexa or a chat client, translates its wire format to a Request and renders the Response
+
+// What the device or client on the other end can do
+type Capabilities struct {
+	// Can show lists of verses and passages as well as say them
+	Display bool
+	// Can stream recordings
+	Audio bool
+}
+
+// One thing the user said, already matched to an intent
+type Request struct {
+	// Where the request came from, e.g. "alexa" or "chat"; only used for logging
+	Channel string
+	Intent  string
+	// Values by slot name, e.g. "Book" -> "John"; empty slots can be left out
+	Slots map[string]string
+	// State from the previous response in this conversation; nil when it is new
+	Session map[string]interface{}
+	// Identifies the user across conversations, for their preferences; may be empty
+	UserID string
+	// e.g. "en-US" or "de-DE"; empty is English
+	Locale string
+	// Name used in prompts and card titles; defaults to "BibleScholar"
+	Name string
+	// Version to search and read when the user has not picked one; empty searches all versions
+	DefaultVersion string
+
+	Capabilities Capabilities
+	// Recording the device last played and how far it got, if any
+	Playing       *biblescholar.AudioRecording
+	PlayingOffset int64
+}
+
+// Value of a slot, or "" if it was not filled
+func (r *Request) Slot(name string) string {
+	return strings.TrimSpace(r.Slots[name])
+}
+
+// Session value from the previous response, or nil
+func (r *Request) Attribute(key string) interface{} {
+	return r.Session[key]
+}
+
+func (r *Request) AttributeString(key string) string {
+	value, _ := r.Attribute(key).(string)
+	return value
+}
+
+// Numeric session value; numbers sent back as JSON decode as float64
+func (r *Request) AttributeInt(key string) (int, bool) {
+	switch value := r.Attribute(key).(type) {
+	case float64:
+		return int(value), true
+	case int:
+		return value, true
+	case string:
+		n, err := strconv.Atoi(value)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// Something to say, as SSML for voice channels and as plain text for the rest
+// SSML is empty for plain text responses, e.g. help and errors
+type Speech struct {
+	SSML string
+	Text string
+}
+
+type Card struct {
+	Title   string
+	Content string
+}
+
+const (
+	// Kinds of display
+	ResultsDisplay string = "searchResults"
+	ChoicesDisplay string = "choices"
+	PassageDisplay string = "passage"
+)
+
+// Verses to show on channels with a screen
+type Display struct {
+	// ResultsDisplay, ChoicesDisplay or PassageDisplay
+	Type     string
+	Title    string
+	Subtitle string
+	Verses   []*biblescholar.Verse
+	// Words to highlight in the verses, e.g. the search phrase
+	Query string
+	// Index in Verses of the hit being spoken, or -1
+	Current int
+}
+
+// Start playing a recording, or stop playing if Recording is nil
+type AudioAction struct {
+	Recording            *biblescholar.AudioRecording
+	OffsetInMilliseconds int64
+}
+
+type Response struct {
+	Speech *Speech
+	// Said if the user does not answer while the conversation is open
+	Reprompt *Speech
+	Card     *Card
+	Display  *Display
+	Audio    *AudioAction
+	// State to send back with the next request in this conversation
+	Session map[string]interface{}
+	// Otherwise the channel waits for the user to answer
+	EndSession bool
+	// Set when the request failed because of an internal error; the speech explains it to the user
+	Err error
+}
+
+// Use the same plain text for speech and card, as for help and error messages
+func (r *Response) SetText(txt string, title string) {
+	r.Speech = &Speech{Text: txt}
+	r.Card = &Card{Title: title, Content: txt}
+}
+
+// Plain text said if the user does not answer
+func (r *Response) SetReprompt(txt string) {
+	r.Reprompt = &Speech{Text: txt}
+}
+
+func (r *Response) SetCard(title string, content string) {
+	r.Card = &Card{Title: title, Content: content}
+}
+
+// Store a value to be sent back with the next request in this conversation
+func (r *Response) SetAttribute(key string, value interface{}) {
+	if r.Session == nil {
+		r.Session = make(map[string]interface{})
+	}
+	r.Session[key] = value
+}
@@ -0,0 +1,237 @@
+package conversation
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	biblescholar "github.com/turtlemonvh/biblescholar/search"
+)
+
+const (
+	// Session attributes used to keep reading a long passage across responses
+	passageAttribute        string = "passage"
+	passageVersionAttribute string = "passageVersion"
+	passageOffsetAttribute  string = "passageOffset"
+	// Alexa rejects output speech over 8000 characters; leave room for the intro and prompt
+	maxPassageSpeechLength int = 6000
+	// Version to read when the user does not ask for one and it is indexed
+	defaultReadingVersion string = "ESV"
+)
+
+// Numeric value of a slot, or 0 if it was not filled
+// Alexa sends "?" for number slots it could not understand
+func (r *Request) SlotNumber(slot string) (int, error) {
+	value := r.Slot(slot)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid value for slot '%s', expected int, got: %v", slot, value)
+	}
+	return n, nil
+}
+
+// Read back a single verse, a range, or a whole chapter
+func (e *Engine) handleReadPassageIntent(t *turn) {
+	if t.req.Slot(BookSlot) == "" {
+		prompt := t.message(msgWhichPassage)
+		t.ask(prompt, prompt, t.message(msgHelpTitle, t.name()))
+		return
+	}
+
+	if unclearPassageSlot(t.req) {
+		t.ask(t.message(msgUnclearPassage), t.message(msgWhichPassage), t.message(msgHelpTitle, t.name()))
+		return
+	}
+
+	passage, err := passageFromSlots(t.req)
+	if err != nil {
+		t.resp.SetText(t.message(msgBadPassage, err.Error()), t.message(msgPassageNotFoundTitle))
+		return
+	}
+	e.readPassage(t, biblescholar.ReferenceList{passage}, translationFromSlot(t.req), 0)
+}
+
+// Whether a chapter or verse was said but not understood; reading on without it would read the whole chapter or book
+func unclearPassageSlot(req *Request) bool {
+	for _, slot := range []string{ChapterSlot, StartVerseSlot, EndVerseSlot} {
+		if _, err := req.SlotNumber(slot); err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Passage named by the Book, Chapter, StartVerse and EndVerse slots
+func passageFromSlots(req *Request) (biblescholar.Passage, error) {
+	book := req.Slot(BookSlot)
+	var nums []int
+	for _, slot := range []string{ChapterSlot, StartVerseSlot, EndVerseSlot} {
+		n, err := req.SlotNumber(slot)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"err": err,
+			}).Warn("Could not understand passage slot.")
+			return nil, err
+		}
+		nums = append(nums, n)
+	}
+
+	passage, err := biblescholar.NewPassage(book, nums[0], nums[1], nums[2])
+	if err != nil {
+		log.WithFields(log.Fields{
+			"err":     err,
+			"book":    book,
+			"chapter": nums[0],
+			"start":   nums[1],
+			"end":     nums[2],
+		}).Warn("Could not build passage from slots.")
+		return nil, err
+	}
+	return passage, nil
+}
+
+// Version asked for in the Translation slot, or "" if none was
+func translationFromSlot(req *Request) string {
+	if translation := req.Slot(TranslationSlot); translation != "" {
+		return biblescholar.LookupTranslation(translation)
+	}
+	return ""
+}
+
+// Continue reading a passage started in an earlier response in this conversation
+func (e *Engine) handleContinuePassageIntent(t *turn) {
+	ref := t.req.AttributeString(passageAttribute)
+	version := t.req.AttributeString(passageVersionAttribute)
+	offset, _ := t.req.AttributeInt(passageOffsetAttribute)
+
+	refs, err := biblescholar.ParseReference(ref)
+	if err != nil {
+		// Nothing to continue
+		e.handleLaunchIntent(t)
+		return
+	}
+	e.readPassage(t, refs, version, offset)
+}
+
+// Say as many verses of the passage as fit in one response, starting at offset
+// If verses remain, the conversation is kept open and the position is stored so the user can say "yes" to continue
+func (e *Engine) readPassage(t *turn, refs biblescholar.ReferenceList, version string, offset int) {
+	resp := t.resp
+	var versions []string
+	if version != "" {
+		versions = []string{version}
+	}
+
+	verses, err := biblescholar.LookupPassage(e.Index, refs, versions)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"err": err,
+			"ref": refs.String(),
+		}).Error("Error while executing passage query.")
+		t.fail(err)
+		return
+	}
+
+	// Read a single version, preferring the user's or skill's choice when none was asked for
+	if version == "" && len(verses) != 0 {
+		preferred := t.preferredVersion()
+		if preferred == "" {
+			preferred = defaultReadingVersion
+		}
+		version = verses[0].Version
+		for _, v := range verses {
+			if v.Version == preferred {
+				version = preferred
+				break
+			}
+		}
+	}
+	var versionVerses []*biblescholar.Verse
+	for _, v := range verses {
+		if v.Version == version {
+			versionVerses = append(versionVerses, v)
+		}
+	}
+
+	// The offset comes back from the session, so may be anything
+	if len(versionVerses) == 0 || offset < 0 || offset >= len(versionVerses) {
+		log.WithFields(log.Fields{
+			"ref":     refs.String(),
+			"version": version,
+			"offset":  offset,
+		}).Warn("Did not find any verses for passage.")
+		speech := t.speech()
+		content := refs.String()
+		if version != "" {
+			speech.Message(msgVersionNotFound, speech.fragment().Passage(refs[0]), speech.fragment().Version(version))
+			content = fmt.Sprintf("%s (%s)", refs.String(), version)
+		} else {
+			speech.Message(msgPassageNotFound, speech.fragment().Passage(refs[0]))
+		}
+		resp.Speech = speech.Speech()
+		resp.SetCard(t.message(msgPassageNotFoundTitle), content)
+		return
+	}
+
+	speech := t.speech()
+	if offset == 0 {
+		speech.Message(msgReading, speech.fragment().Passage(refs[0]), speech.fragment().Version(version))
+	} else {
+		speech.Message(msgContinuing, speech.fragment().Number(versionVerses[offset].Chapter), speech.fragment().Number(versionVerses[offset].Verse))
+	}
+	speech.Pause(referencePause)
+
+	next := offset
+	for ; next < len(versionVerses); next++ {
+		v := versionVerses[next]
+		verseSpeech := speech.fragment()
+		if len(versionVerses) > 1 {
+			verseSpeech.Message(msgVerseNumber, verseSpeech.fragment().Number(v.Verse))
+		}
+		verseSpeech.Text(v.Text).Pause(versePause)
+		// Always read at least one verse per response
+		if next > offset && speech.Len()+verseSpeech.Len() > maxPassageSpeechLength {
+			break
+		}
+		speech.Append(verseSpeech)
+	}
+
+	title := fmt.Sprintf("%s (%s)", refs.String(), version)
+	remaining := next < len(versionVerses)
+	if remaining {
+		resp.EndSession = false
+		resp.SetAttribute(passageAttribute, refs.String())
+		resp.SetAttribute(passageVersionAttribute, version)
+		resp.SetAttribute(passageOffsetAttribute, next)
+		speech.Message(msgKeepReading)
+		resp.SetReprompt(t.message(msgKeepReading))
+	}
+
+	log.WithFields(log.Fields{
+		"ref":     refs.String(),
+		"version": version,
+		"offset":  offset,
+		"next":    next,
+		"nverses": len(versionVerses),
+	}).Info("Reading passage.")
+
+	resp.Speech = speech.Speech()
+	resp.SetCard(title, passageCardText(versionVerses[offset:next]))
+	if t.req.Capabilities.Display {
+		display := &Display{
+			Type:     PassageDisplay,
+			Title:    refs.String(),
+			Subtitle: biblescholar.TranslationName(version),
+			Verses:   versionVerses[offset:next],
+			Current:  -1,
+		}
+		if remaining {
+			display.Subtitle = strings.TrimSpace(display.Subtitle + " - " + t.message(msgKeepReadingHint))
+		}
+		resp.Display = display
+	}
+}
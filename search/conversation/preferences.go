@@ -0,0 +1,96 @@
+package conversation
+
+import (
+	"sync"
+	"time"
+
+	bolt "github.com/etcd-io/bbolt"
+)
+
+// Per user settings, keyed by the user id the channel sends, e.g. the Alexa userId
+
+// Where user preferences are kept between sessions
+type PreferenceStore interface {
+	// Version abbreviation the user asked for, or "" if they have not chosen one
+	Translation(userID string) (string, error)
+	// Remember a version for the user; "" clears it
+	SetTranslation(userID string, version string) error
+	Close() error
+}
+
+var translationsBucket = []byte("translations")
+
+// Preferences stored in a local bolt database file
+type BoltPreferenceStore struct {
+	db *bolt.DB
+}
+
+// Open or create a preferences database
+func OpenBoltPreferenceStore(path string) (*BoltPreferenceStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(translationsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltPreferenceStore{db: db}, nil
+}
+
+func (p *BoltPreferenceStore) Translation(userID string) (string, error) {
+	var version string
+	err := p.db.View(func(tx *bolt.Tx) error {
+		version = string(tx.Bucket(translationsBucket).Get([]byte(userID)))
+		return nil
+	})
+	return version, err
+}
+
+func (p *BoltPreferenceStore) SetTranslation(userID string, version string) error {
+	return p.db.Update(func(tx *bolt.Tx) error {
+		if version == "" {
+			return tx.Bucket(translationsBucket).Delete([]byte(userID))
+		}
+		return tx.Bucket(translationsBucket).Put([]byte(userID), []byte(version))
+	})
+}
+
+func (p *BoltPreferenceStore) Close() error {
+	return p.db.Close()
+}
+
+// Preferences kept only while the process runs, e.g. for testing
+type MemoryPreferenceStore struct {
+	mu           sync.Mutex
+	translations map[string]string
+}
+
+func NewMemoryPreferenceStore() *MemoryPreferenceStore {
+	return &MemoryPreferenceStore{translations: make(map[string]string)}
+}
+
+func (p *MemoryPreferenceStore) Translation(userID string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.translations[userID], nil
+}
+
+func (p *MemoryPreferenceStore) SetTranslation(userID string, version string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if version == "" {
+		delete(p.translations, userID)
+	} else {
+		p.translations[userID] = version
+	}
+	return nil
+}
+
+func (p *MemoryPreferenceStore) Close() error {
+	return nil
+}
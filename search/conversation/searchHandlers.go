@@ -0,0 +1,305 @@
+package conversation
+
+import (
+	"github.com/blevesearch/bleve"
+	log "github.com/sirupsen/logrus"
+	biblescholar "github.com/turtlemonvh/biblescholar/search"
+)
+
+const (
+	// Session attributes used to page through search results
+	searchQueryAttribute  string = "searchQuery"
+	searchOffsetAttribute string = "searchOffset"
+	searchModeAttribute   string = "searchMode"
+	// How the results being paged through were found
+	exactSearchMode string = "exact"
+	fuzzySearchMode string = "fuzzy"
+	// Search hits shown at once on a screen
+	displayListSize int = 5
+)
+
+// Search for a phrase and say the best match
+func (e *Engine) handleSearchBibleIntent(t *turn) {
+	queryText := t.req.Slot(QuerySlot)
+	if queryText == "" {
+		// This is a search without a query
+		e.handleLaunchIntent(t)
+		return
+	}
+
+	// "search for john three sixteen" is asking for the verse, not for the words
+	if refs, err := biblescholar.ParseSpokenReference(queryText); err == nil {
+		log.WithFields(log.Fields{
+			"query": queryText,
+			"ref":   refs.String(),
+		}).Info("Search phrase is a reference.")
+		e.readPassage(t, refs, "", 0)
+		return
+	}
+
+	// Let the user pick when several verses match about equally well
+	choices, err := e.closeSearchChoices(t, queryText)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"err": err,
+		}).Warn("Could not look for close matches.")
+	}
+	if len(choices) > 1 {
+		e.respondWithChoices(t, queryText, choices)
+		return
+	}
+
+	e.respondWithSearchResult(t, queryText, 0, "")
+}
+
+// Move through the results of the previous search in this conversation
+// "Next" also continues reading a long passage, since that is what users tend to say
+// Without a search, moves between recorded chapters if one has been played
+func (e *Engine) handleSearchNavigationIntent(t *turn) {
+	intent := t.req.Intent
+	if intent == NextIntent && t.req.AttributeString(passageAttribute) != "" {
+		e.handleContinuePassageIntent(t)
+		return
+	}
+
+	queryText := t.req.AttributeString(searchQueryAttribute)
+	if queryText == "" {
+		if e.handleAudioNavigationIntent(t) {
+			return
+		}
+		// Nothing to move through
+		e.handleLaunchIntent(t)
+		return
+	}
+	offset, _ := t.req.AttributeInt(searchOffsetAttribute)
+
+	switch intent {
+	case NextIntent:
+		offset++
+	case PreviousIntent:
+		if offset > 0 {
+			offset--
+		}
+	}
+	e.respondWithSearchResult(t, queryText, offset, t.req.AttributeString(searchModeAttribute))
+}
+
+// One search result to say
+type searchHit struct {
+	// Nil when offset is past the last result
+	Verse      *biblescholar.Verse
+	Total      uint64
+	Confidence float64
+	Mode       string
+}
+
+// Versions to search: the preferred one, or all of them
+func (t *turn) searchVersions() []string {
+	if version := t.preferredVersion(); version != "" {
+		return []string{version}
+	}
+	return nil
+}
+
+// Find the result at offset, searching the same way as for earlier results in the conversation
+// A new search, with no mode, falls back to fuzzy matching when the best exact match is weak
+func (e *Engine) findSearchHit(t *turn, queryText string, offset int, mode string) (*searchHit, error) {
+	versions := t.searchVersions()
+	if mode == fuzzySearchMode {
+		return e.fuzzySearchHit(queryText, offset, versions)
+	}
+	hit, err := e.exactSearchHit(queryText, offset, versions)
+	if err != nil || mode == exactSearchMode || (hit.Verse != nil && hit.Confidence >= biblescholar.LowConfidence) {
+		return hit, err
+	}
+
+	fuzzy, err := e.fuzzySearchHit(queryText, offset, versions)
+	if err != nil {
+		return nil, err
+	}
+	if fuzzy.Verse == nil || (hit.Verse != nil && hit.Confidence >= fuzzy.Confidence) {
+		return hit, nil
+	}
+	log.WithFields(log.Fields{
+		"query":           queryText,
+		"exactConfidence": hit.Confidence,
+		"fuzzyConfidence": fuzzy.Confidence,
+	}).Info("Using fuzzy match for weak search result.")
+	return fuzzy, nil
+}
+
+func newExactSearchRequest(queryText string, versions []string, size int, offset int) *bleve.SearchRequest {
+	q := biblescholar.LimitToVersions(bleve.NewQueryStringQuery(queryText), versions)
+	// query, limit, skip, explain
+	searchRequest := bleve.NewSearchRequestOptions(q, size, offset, false)
+	searchRequest.Fields = []string{
+		"Version",
+		"Book",
+		"Chapter",
+		"Verse",
+		"Text",
+	}
+	return searchRequest
+}
+
+func (e *Engine) exactSearchHit(queryText string, offset int, versions []string) (*searchHit, error) {
+	searchResult, err := e.Index.Search(newExactSearchRequest(queryText, versions, 1, offset))
+	if err != nil {
+		return nil, err
+	}
+
+	// https://godoc.org/github.com/blevesearch/bleve#SearchResult
+	if len(searchResult.Status.Errors) != 0 {
+		log.WithFields(log.Fields{
+			"errors": searchResult.Status.Errors,
+			"index":  e.Index.Name(),
+			"query":  queryText,
+		}).Warn("Encountered non-fatal errors when fetching query result.")
+	}
+
+	hit := &searchHit{Total: searchResult.Total, Mode: exactSearchMode}
+	if searchResult.Hits.Len() != 0 {
+		// https://godoc.org/github.com/blevesearch/bleve/search#DocumentMatch
+		hit.Verse = biblescholar.NewVerseFromFields(searchResult.Hits[0].Fields)
+		hit.Confidence = biblescholar.PhraseConfidence(queryText, hit.Verse.Text)
+	}
+	return hit, nil
+}
+
+func (e *Engine) fuzzySearchHit(queryText string, offset int, versions []string) (*searchHit, error) {
+	matches, total, err := biblescholar.FuzzySearch(e.Index, queryText, versions, offset, 1)
+	if err != nil {
+		return nil, err
+	}
+	hit := &searchHit{Total: uint64(total), Mode: fuzzySearchMode}
+	if len(matches) != 0 {
+		hit.Verse = matches[0].Verse
+		hit.Confidence = matches[0].Confidence
+	}
+	return hit, nil
+}
+
+// Several results starting at offset, found the same way as the hit being said
+func (e *Engine) findSearchPage(t *turn, queryText string, offset int, size int, mode string) ([]*biblescholar.Verse, error) {
+	versions := t.searchVersions()
+
+	var verses []*biblescholar.Verse
+	if mode == fuzzySearchMode {
+		matches, _, err := biblescholar.FuzzySearch(e.Index, queryText, versions, offset, size)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range matches {
+			verses = append(verses, m.Verse)
+		}
+		return verses, nil
+	}
+
+	searchResult, err := e.Index.Search(newExactSearchRequest(queryText, versions, size, offset))
+	if err != nil {
+		return nil, err
+	}
+	for _, hit := range searchResult.Hits {
+		verses = append(verses, biblescholar.NewVerseFromFields(hit.Fields))
+	}
+	return verses, nil
+}
+
+// Say the search hit at offset
+// The query, offset and search mode are stored in the session so the user can ask for the next, previous, or same result again
+func (e *Engine) respondWithSearchResult(t *turn, queryText string, offset int, mode string) {
+	resp := t.resp
+	hit, err := e.findSearchHit(t, queryText, offset, mode)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"err": err,
+		}).Error("Error while executing search query.")
+		t.fail(err)
+		return
+	}
+
+	// Not found
+	if hit.Total == 0 {
+		log.WithFields(log.Fields{
+			"index": e.Index.Name(),
+			"query": queryText,
+		}).Warn("Did not find any matching results.")
+		resp.SetText(t.message(msgNoResults), t.message(msgNoResultsTitle))
+		return
+	}
+
+	// Walked off the end of the results; stay on the last one
+	if hit.Verse == nil {
+		resp.SetAttribute(searchQueryAttribute, queryText)
+		resp.SetAttribute(searchOffsetAttribute, int(hit.Total)-1)
+		resp.SetAttribute(searchModeAttribute, hit.Mode)
+		t.ask(t.message(msgLastResult), t.message(msgLastResult), t.message(msgLastResultTitle))
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"nhits":      hit.Total,
+		"offset":     offset,
+		"mode":       hit.Mode,
+		"confidence": hit.Confidence,
+		"index":      e.Index.Name(),
+		"query":      queryText,
+	}).Info("Found matching results.")
+
+	intro := msgBestMatch
+	switch {
+	case hit.Mode == fuzzySearchMode && offset > 0:
+		intro = msgCloseMatchN
+	case hit.Mode == fuzzySearchMode:
+		intro = msgClosestMatch
+	case offset > 0:
+		intro = msgResultN
+	}
+	speech := verseSpeech(t.req.Locale, intro, hit.Verse, offset+1, hit.Total)
+	title, content := verseCard(hit.Verse)
+	if hit.Mode == fuzzySearchMode {
+		title = t.message(msgMatchTitle, title, hit.Confidence*100)
+	}
+
+	// Keep the conversation open while there is somewhere to go
+	resp.SetAttribute(searchQueryAttribute, queryText)
+	resp.SetAttribute(searchOffsetAttribute, offset)
+	resp.SetAttribute(searchModeAttribute, hit.Mode)
+	if uint64(offset+1) < hit.Total {
+		resp.EndSession = false
+		speech.Pause(referencePause).Message(msgNextHint)
+		resp.SetReprompt(t.message(msgNextReprompt))
+	}
+
+	resp.Speech = speech.Speech()
+	resp.SetCard(title, content)
+	if t.req.Capabilities.Display {
+		e.addSearchResultsDisplay(t, queryText, offset, hit)
+	}
+}
+
+// Show the page of hits around the one being said
+func (e *Engine) addSearchResultsDisplay(t *turn, queryText string, offset int, hit *searchHit) {
+	pageStart := offset - offset%displayListSize
+	verses, err := e.findSearchPage(t, queryText, pageStart, displayListSize, hit.Mode)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"err":   err,
+			"query": queryText,
+		}).Warn("Could not fetch search results to display.")
+		return
+	}
+
+	display := &Display{
+		Type:     ResultsDisplay,
+		Title:    t.message(msgResultsTitle, queryText),
+		Subtitle: t.message(msgResultsRange, pageStart+1, pageStart+len(verses), hit.Total),
+		Verses:   verses,
+		Query:    queryText,
+		Current:  offset - pageStart,
+	}
+	if hit.Mode == fuzzySearchMode {
+		display.Title = t.message(msgChoicesTitle, queryText)
+	}
+	t.resp.Display = display
+}
@@ -0,0 +1,267 @@
+package conversation
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	biblescholar "github.com/turtlemonvh/biblescholar/search"
+)
+
+// Rendering of speech as SSML, with the plain text alongside for channels that cannot speak
+// https://developer.amazon.com/docs/custom-skills/speech-synthesis-markup-language-ssml-reference.html
+
+const (
+	// Pause between a reference and the verse text, and between verses
+	referencePause time.Duration = 600 * time.Millisecond
+	versePause     time.Duration = 300 * time.Millisecond
+)
+
+// IPA pronunciations for names that Alexa tends to get wrong
+var pronunciations = map[string]string{
+	"Abednego":       "əˈbɛdnɪɡoʊ",
+	"Ahasuerus":      "əˌhæzjuˈɪərəs",
+	"Ecclesiastes":   "ɪˌkliːziˈæstiːz",
+	"Habakkuk":       "ˈhæbəkʌk",
+	"Haggai":         "ˈhæɡeɪaɪ",
+	"Melchizedek":    "mɛlˈkɪzədɛk",
+	"Mephibosheth":   "mɪˈfɪboʊʃɛθ",
+	"Meshach":        "ˈmiːʃæk",
+	"Nebuchadnezzar": "ˌnɛbjʊkədˈnɛzər",
+	"Philemon":       "faɪˈliːmən",
+	"Philippians":    "fɪˈlɪpiənz",
+	"Shadrach":       "ˈʃædræk",
+	"Thessalonians":  "ˌθɛsəˈloʊniənz",
+	"Zechariah":      "ˌzɛkəˈraɪə",
+	"Zephaniah":      "ˌzɛfəˈnaɪə",
+	"Zerubbabel":     "zəˈrʌbəbəl",
+}
+
+// Quotes are fine in SSML text; only markup characters need escaping
+var ssmlEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// Matches any word in the pronunciation lexicon
+var pronunciationPattern *regexp.Regexp
+
+func init() {
+	var names []string
+	for name := range pronunciations {
+		names = append(names, regexp.QuoteMeta(name))
+	}
+	// Longest first so that no name shadows a longer one that contains it
+	sort.Slice(names, func(i, j int) bool { return len(names[i]) > len(names[j]) })
+	pronunciationPattern = regexp.MustCompile(`\b(` + strings.Join(names, "|") + `)\b`)
+}
+
+// One piece of speech, as SSML and as plain text
+type speechPart struct {
+	ssml string
+	text string
+}
+
+// Builds up an SSML document one piece at a time, in the request's locale
+// The plain text is kept alongside, for channels that cannot speak
+type speechBuilder struct {
+	parts  []speechPart
+	locale string
+}
+
+func newSpeech(locale string) *speechBuilder {
+	return &speechBuilder{locale: locale}
+}
+
+// Escape text, replacing hard names by their phonemes
+// The lexicon is English, so other languages are left to the voice
+func (s *speechBuilder) escape(txt string) string {
+	escaped := ssmlEscaper.Replace(strings.TrimSpace(txt))
+	if s.locale != "" && localeLanguage(s.locale) != defaultLanguage {
+		return escaped
+	}
+	return pronunciationPattern.ReplaceAllStringFunc(escaped, func(name string) string {
+		return fmt.Sprintf(`<phoneme alphabet="ipa" ph="%s">%s</phoneme>`, pronunciations[name], name)
+	})
+}
+
+// Keep punctuation attached to the previous piece, e.g. after a number
+func (s *speechBuilder) add(part speechPart) *speechBuilder {
+	if part.ssml == "" {
+		return s
+	}
+	if n := len(s.parts); n != 0 && strings.ContainsAny(part.ssml[:1], ".,;:!?") {
+		s.parts[n-1].ssml += part.ssml
+		s.parts[n-1].text += part.text
+		return s
+	}
+	s.parts = append(s.parts, part)
+	return s
+}
+
+// Plain text, escaped, with hard names replaced by their phonemes
+func (s *speechBuilder) Text(txt string) *speechBuilder {
+	return s.add(speechPart{ssml: s.escape(txt), text: strings.TrimSpace(txt)})
+}
+
+// A message from the catalog in the builder's locale
+// Arguments that are speech builders keep their markup, e.g. for a passage or version; strings are escaped like text
+func (s *speechBuilder) Message(id string, args ...interface{}) *speechBuilder {
+	ssmlValues := make([]interface{}, len(args))
+	textValues := make([]interface{}, len(args))
+	var fragments []string
+	for i, arg := range args {
+		if fragment, ok := arg.(*speechBuilder); ok {
+			// Stand in for the markup until the rest of the message is escaped
+			ssmlValues[i] = fmt.Sprintf("\x00%d\x00", len(fragments))
+			textValues[i] = fragment.Plain()
+			fragments = append(fragments, fragment.ssml())
+			continue
+		}
+		ssmlValues[i] = arg
+		textValues[i] = arg
+	}
+	tmpl := messageTemplate(s.locale, id)
+	ssml := s.escape(formatMessage(tmpl, ssmlValues...))
+	for i, fragment := range fragments {
+		ssml = strings.Replace(ssml, fmt.Sprintf("\x00%d\x00", i), fragment, 1)
+	}
+	return s.add(speechPart{ssml: ssml, text: formatMessage(tmpl, textValues...)})
+}
+
+// A single word from the catalog, e.g. "chapter"
+func (s *speechBuilder) word(id string) *speechBuilder {
+	return s.Text(messageTemplate(s.locale, id))
+}
+
+// An empty builder in the same locale, for pieces of a message
+func (s *speechBuilder) fragment() *speechBuilder {
+	return newSpeech(s.locale)
+}
+
+// Add everything in another builder
+func (s *speechBuilder) Append(other *speechBuilder) *speechBuilder {
+	s.parts = append(s.parts, other.parts...)
+	return s
+}
+
+// Silent in the plain text
+func (s *speechBuilder) Pause(d time.Duration) *speechBuilder {
+	s.parts = append(s.parts, speechPart{ssml: fmt.Sprintf(`<break time="%dms"/>`, d.Milliseconds())})
+	return s
+}
+
+// Chapter and verse numbers, read as "sixteen" rather than "one six" or "sixteenth"
+func (s *speechBuilder) Number(n int) *speechBuilder {
+	s.parts = append(s.parts, speechPart{
+		ssml: fmt.Sprintf(`<say-as interpret-as="cardinal">%d</say-as>`, n),
+		text: strconv.Itoa(n),
+	})
+	return s
+}
+
+// Version abbreviations are spelled out, e.g. "E S V"
+func (s *speechBuilder) Version(version string) *speechBuilder {
+	if version == strings.ToUpper(version) && len(version) <= 5 {
+		s.parts = append(s.parts, speechPart{
+			ssml: fmt.Sprintf(`<say-as interpret-as="characters">%s</say-as>`, ssmlEscaper.Replace(version)),
+			text: version,
+		})
+		return s
+	}
+	return s.Text(version)
+}
+
+// "1 Corinthians" is read as "one Corinthians" otherwise
+func (s *speechBuilder) Book(book string) *speechBuilder {
+	for digit, id := range map[string]string{"1 ": msgFirst, "2 ": msgSecond, "3 ": msgThird} {
+		if strings.HasPrefix(book, digit) {
+			return s.Text(messageTemplate(s.locale, id) + " " + strings.TrimPrefix(book, digit))
+		}
+	}
+	return s.Text(book)
+}
+
+// A passage the way a person would say it, e.g. "John chapter 3 verses 16 through 18"
+func (s *speechBuilder) Passage(p biblescholar.Passage) *speechBuilder {
+	start, end := p.Bounds()
+	s.Book(start.Book)
+	if r, ok := p.(biblescholar.ChapterRange); ok {
+		if r.Start == r.End {
+			return s.word(msgChapter).Number(r.Start)
+		}
+		return s.word(msgChapters).Number(r.Start).word(msgThrough).Number(r.End)
+	}
+	switch {
+	case start == end:
+		return s.word(msgChapter).Number(start.Chapter).word(msgVerse).Number(start.Verse)
+	case start.Chapter == end.Chapter:
+		return s.word(msgChapter).Number(start.Chapter).word(msgVerses).Number(start.Verse).word(msgThrough).Number(end.Verse)
+	default:
+		return s.word(msgChapter).Number(start.Chapter).word(msgVerse).Number(start.Verse).
+			word(msgThrough).word(msgChapter).Number(end.Chapter).word(msgVerse).Number(end.Verse)
+	}
+}
+
+// Length of the rendered document, which Alexa limits to 8000 characters
+func (s *speechBuilder) Len() int {
+	return len(s.SSML())
+}
+
+func (s *speechBuilder) ssml() string {
+	var parts []string
+	for _, part := range s.parts {
+		parts = append(parts, part.ssml)
+	}
+	return strings.Join(parts, " ")
+}
+
+func (s *speechBuilder) SSML() string {
+	return fmt.Sprintf("<speak>%s</speak>", s.ssml())
+}
+
+// What is said, without markup
+func (s *speechBuilder) Plain() string {
+	var parts []string
+	for _, part := range s.parts {
+		if part.text != "" {
+			parts = append(parts, part.text)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func (s *speechBuilder) Speech() *Speech {
+	return &Speech{
+		SSML: s.SSML(),
+		Text: s.Plain(),
+	}
+}
+
+// Speech for a single search hit: where it is from, a pause, then the verse
+// The intro message gets the passage and version, followed by any other arguments
+func verseSpeech(locale string, intro string, v *biblescholar.Verse, args ...interface{}) *speechBuilder {
+	speech := newSpeech(locale)
+	args = append([]interface{}{speech.fragment().Passage(v.Ref()), speech.fragment().Version(v.Version)}, args...)
+	return speech.
+		Message(intro, args...).
+		Pause(referencePause).
+		Text(v.Text)
+}
+
+// Compact card rendering of a single verse, with the reference as the title
+func verseCard(v *biblescholar.Verse) (string, string) {
+	return fmt.Sprintf("%s (%s)", v.Ref().String(), v.Version), v.Text
+}
+
+// Compact card rendering of several verses, numbered inline, e.g. "16 For God so loved... 17 For God did not..."
+func passageCardText(verses []*biblescholar.Verse) string {
+	if len(verses) == 1 {
+		return verses[0].Text
+	}
+	parts := make([]string, len(verses))
+	for i, v := range verses {
+		parts[i] = fmt.Sprintf("%d %s", v.Verse, v.Text)
+	}
+	return strings.Join(parts, " ")
+}
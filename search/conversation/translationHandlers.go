@@ -0,0 +1,116 @@
+package conversation
+
+import (
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+	biblescholar "github.com/turtlemonvh/biblescholar/search"
+)
+
+// The version the user picked, or "" if they have not picked one
+// Preferences are a nicety, so a failure to read them is logged and the request carries on
+func (e *Engine) userTranslation(userID string) string {
+	if userID == "" {
+		return ""
+	}
+	version, err := e.preferences().Translation(userID)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"err": err,
+		}).Error("Error while reading user preferences.")
+		return ""
+	}
+	return version
+}
+
+// Version to search and read: the user's choice, then the channel's default, then the default for the user's language, or "" for any
+func (t *turn) preferredVersion() string {
+	if t.userVersion != "" {
+		return t.userVersion
+	}
+	if t.req.DefaultVersion != "" {
+		return t.req.DefaultVersion
+	}
+	return t.localeVersion
+}
+
+// Versions in the index, listed for the user
+func (e *Engine) availableVersions(t *turn) ([]string, string) {
+	versions, err := biblescholar.IndexedTerms(e.Index, "Version")
+	if err != nil {
+		log.WithFields(log.Fields{
+			"err": err,
+		}).Error("Error while listing indexed versions.")
+	}
+	var names []string
+	for _, version := range versions {
+		names = append(names, biblescholar.TranslationName(version))
+	}
+	return versions, t.joinList(msgAnd, names)
+}
+
+// "Use the King James", remembered for later conversations
+func (e *Engine) handleSetTranslationIntent(t *turn) {
+	versions, available := e.availableVersions(t)
+	title := t.message(msgTranslationTitle, t.name())
+
+	translation := t.req.Slot(TranslationSlot)
+	if translation == "" {
+		t.ask(t.message(msgWhichTranslation, available), t.message(msgTranslationExample), title)
+		return
+	}
+
+	version := biblescholar.LookupTranslation(translation)
+	indexed := false
+	for _, v := range versions {
+		indexed = indexed || v == version
+	}
+	if !indexed {
+		t.ask(t.message(msgMissingTranslation, translation, available), t.message(msgTranslationQuestion), title)
+		return
+	}
+
+	// Some channels cannot tell users apart
+	err := fmt.Errorf("No user id to save the translation for")
+	if t.req.UserID != "" {
+		err = e.preferences().SetTranslation(t.req.UserID, version)
+	}
+	if err != nil {
+		log.WithFields(log.Fields{
+			"err": err,
+		}).Error("Error while saving user preferences.")
+		t.fail(err)
+		t.resp.SetText(t.message(msgSaveError), t.message(msgErrorTitle))
+		return
+	}
+	log.WithFields(log.Fields{
+		"version": version,
+	}).Info("Saved translation preference.")
+
+	// Carry on with whatever the user was doing
+	t.keepSession()
+	t.resp.EndSession = false
+	speech := t.speech()
+	speech.Message(msgTranslationSaved, speech.fragment().Version(biblescholar.TranslationName(version))).
+		Message(msgWhatNext)
+	t.resp.Speech = speech.Speech()
+	t.resp.SetCard(title, t.message(msgTranslationCard, biblescholar.TranslationName(version), version))
+	t.resp.SetReprompt(t.message(msgWhatNext))
+}
+
+// "Which translation am I using?"
+func (e *Engine) handleGetTranslationIntent(t *turn) {
+	t.keepSession()
+
+	var txt string
+	switch version := t.preferredVersion(); {
+	case t.userVersion != "":
+		txt = t.message(msgUserTranslation, biblescholar.TranslationName(version))
+	case version != "":
+		txt = t.message(msgSkillTranslation, biblescholar.TranslationName(version))
+	default:
+		_, available := e.availableVersions(t)
+		txt = t.message(msgNoTranslation, available)
+	}
+	t.ask(txt, t.message(msgWhatNext), t.message(msgTranslationTitle, t.name()))
+}
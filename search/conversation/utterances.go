@@ -0,0 +1,125 @@
+package conversation
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	biblescholar "github.com/turtlemonvh/biblescholar/search"
+)
+
+// Matching typed text to intents, for channels that do not do it themselves, e.g. chat and text messages
+
+// Words that map directly to intents
+var wordIntents = map[string]string{
+	"open":     LaunchIntent,
+	"launch":   LaunchIntent,
+	"start":    LaunchIntent,
+	"next":     NextIntent,
+	"more":     NextIntent,
+	"previous": PreviousIntent,
+	"back":     PreviousIntent,
+	"repeat":   RepeatIntent,
+	"again":    RepeatIntent,
+	"yes":      YesIntent,
+	"no":       NoIntent,
+	"help":     HelpIntent,
+	"stop":     StopIntent,
+	"cancel":   StopIntent,
+	"home":     HomeIntent,
+	"pause":    PauseIntent,
+	"resume":   ResumeIntent,
+}
+
+var (
+	searchUtterancePattern  = regexp.MustCompile(`(?i)^(?:search for|search|look up|lookup|find)\s+(.+)$`)
+	ordinalUtterancePattern = regexp.MustCompile(`(?i)^(?:the\s+)?(first|second|third|1st|2nd|3rd)(?:\s+one)?$`)
+	choiceUtterancePattern  = regexp.MustCompile(`(?i)^the\s+(.+?)\s+one$`)
+	useUtterancePattern     = regexp.MustCompile(`(?i)^(?:use|switch to)\s+(?:the\s+)?(.+?)(?:\s+translation)?$`)
+	readUtterancePattern    = regexp.MustCompile(`(?i)^read\s+(.+?)(?:\s+(?:in|from)\s+(?:the\s+)?(.+?)(?:\s+translation)?)?$`)
+	playUtterancePattern    = regexp.MustCompile(`(?i)^(?:play|listen to)\s+(.+?)(?:\s+(?:in|from)\s+(?:the\s+)?(.+?)(?:\s+translation)?)?$`)
+)
+
+var ordinals = map[string]string{"first": "1", "second": "2", "third": "3", "1st": "1", "2nd": "2", "3rd": "3"}
+
+// Intent and slots for a typed utterance
+// Anything that is not a command is searched for, e.g. "love your neighbor"
+func ParseUtterance(utterance string) (string, map[string]string) {
+	utterance = strings.TrimSpace(utterance)
+	lower := strings.ToLower(strings.TrimRight(utterance, ".!?"))
+
+	if intent, ok := wordIntents[lower]; ok {
+		return intent, nil
+	}
+	if m := ordinalUtterancePattern.FindStringSubmatch(lower); m != nil {
+		return SelectChoiceIntent, map[string]string{OrdinalSlot: ordinals[m[1]]}
+	}
+	if m := choiceUtterancePattern.FindStringSubmatch(utterance); m != nil {
+		return SelectChoiceIntent, map[string]string{BookSlot: m[1]}
+	}
+	if strings.HasPrefix(lower, "which translation") || strings.HasPrefix(lower, "what translation") {
+		return GetTranslationIntent, nil
+	}
+	if m := useUtterancePattern.FindStringSubmatch(utterance); m != nil {
+		return SetTranslationIntent, map[string]string{TranslationSlot: m[1]}
+	}
+
+	if m := readUtterancePattern.FindStringSubmatch(utterance); m != nil {
+		// Could also be a search phrase starting with "read"
+		if slots, err := PassageSlots(m[1]); err == nil {
+			if m[2] != "" {
+				slots[TranslationSlot] = m[2]
+			}
+			return ReadPassageIntent, slots
+		}
+	}
+	if m := playUtterancePattern.FindStringSubmatch(utterance); m != nil {
+		if slots, err := PassageSlots(m[1]); err == nil {
+			// Recordings play to the end of the chapter
+			delete(slots, EndVerseSlot)
+			if m[2] != "" {
+				slots[TranslationSlot] = m[2]
+			}
+			return PlayPassageIntent, slots
+		}
+	}
+
+	phrase := utterance
+	if m := searchUtterancePattern.FindStringSubmatch(utterance); m != nil {
+		phrase = m[1]
+	}
+	return SearchBibleIntent, map[string]string{QuerySlot: phrase}
+}
+
+// Slots for the ReadPassage intent from a written reference, e.g. "John 3:16-18"
+func PassageSlots(ref string) (map[string]string, error) {
+	refs, err := biblescholar.ParseReference(ref)
+	if err != nil {
+		return nil, err
+	}
+	if len(refs) != 1 {
+		return nil, fmt.Errorf("Only a single passage can be read at once: '%s'", ref)
+	}
+
+	start, end := refs[0].Bounds()
+	slots := map[string]string{
+		BookSlot:    start.Book,
+		ChapterSlot: strconv.Itoa(start.Chapter),
+	}
+	switch p := refs[0].(type) {
+	case biblescholar.ChapterRange:
+		if p.Start != p.End {
+			return nil, fmt.Errorf("Only a single chapter can be read at once: '%s'", ref)
+		}
+	default:
+		if start.Chapter != end.Chapter {
+			return nil, fmt.Errorf("Only verses within a single chapter can be read at once: '%s'", ref)
+		}
+		slots[StartVerseSlot] = strconv.Itoa(start.Verse)
+		if end.Verse != start.Verse {
+			slots[EndVerseSlot] = strconv.Itoa(end.Verse)
+		}
+	}
+	return slots, nil
+}
@@ -0,0 +1,124 @@
+package biblescholar
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/search/query"
+)
+
+// Tolerant matching for half remembered or misheard quotations, e.g. "the lord is my shepard I shall not want"
+// Candidates are gathered with fuzzy term queries, then ranked by how much of the phrase appears in each verse
+
+const (
+	// Number of verses considered when ranking fuzzy matches
+	FuzzyCandidates int = 100
+	// Fuzzy matches below this confidence are dropped
+	MinFuzzyConfidence float64 = 0.5
+	// Exact search results below this confidence are worth retrying with fuzzy matching
+	LowConfidence float64 = 0.6
+	// Words shorter than this are too common to match fuzzily
+	minFuzzyWordLength int = 4
+)
+
+// A verse found by fuzzy matching, with the share of the phrase found in it, from 0 to 1
+type FuzzyMatch struct {
+	Verse      *Verse  `json:"verse"`
+	Confidence float64 `json:"confidence"`
+}
+
+// Find the verses that best match a phrase despite misspellings and missing or changed words
+// Returns the matches in [from, from+size) and the total number of matches above MinFuzzyConfidence
+// An empty versions list matches all versions
+func FuzzySearch(index bleve.Index, phrase string, versions []string, from int, size int) ([]*FuzzyMatch, int, error) {
+	if from < 0 || size < 0 {
+		return nil, 0, fmt.Errorf("Invalid page: from %d, size %d; neither may be negative", from, size)
+	}
+	words := strings.FieldsFunc(strings.ToLower(phrase), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+	})
+
+	// The phrase as analyzed by the index, plus each longer word allowing for typos
+	exact := bleve.NewMatchQuery(phrase)
+	exact.SetField("Text")
+	disjuncts := []query.Query{exact}
+	for _, word := range words {
+		if len(word) < minFuzzyWordLength {
+			continue
+		}
+		fq := bleve.NewFuzzyQuery(word)
+		fq.SetField("Text")
+		fq.SetPrefix(1)
+		if len(word) >= 6 {
+			fq.SetFuzziness(2)
+		}
+		disjuncts = append(disjuncts, fq)
+	}
+
+	q := LimitToVersions(bleve.NewDisjunctionQuery(disjuncts...), versions)
+	searchRequest := bleve.NewSearchRequestOptions(q, FuzzyCandidates, 0, false)
+	searchRequest.Fields = verseFields
+	searchResult, err := index.Search(searchRequest)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var matches []*FuzzyMatch
+	for _, hit := range searchResult.Hits {
+		verse := NewVerseFromFields(hit.Fields)
+		confidence := PhraseConfidence(phrase, verse.Text)
+		if confidence < MinFuzzyConfidence {
+			continue
+		}
+		matches = append(matches, &FuzzyMatch{verse, confidence})
+	}
+	// Stable, so ties keep the search engine's order
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Confidence > matches[j].Confidence
+	})
+
+	total := len(matches)
+	if from >= total {
+		return nil, total, nil
+	}
+	end := from + size
+	if end > total {
+		end = total
+	}
+	return matches[from:end], total, nil
+}
+
+// Share of the phrase's character trigrams that appear in the text, from 0 to 1
+// Trigrams tolerate misspellings, since "shepard" still shares most of its trigrams with "shepherd"
+func PhraseConfidence(phrase string, text string) float64 {
+	phraseGrams := trigrams(phrase)
+	if len(phraseGrams) == 0 {
+		return 0
+	}
+	textGrams := trigrams(text)
+	found := 0
+	for gram := range phraseGrams {
+		if textGrams[gram] {
+			found++
+		}
+	}
+	return float64(found) / float64(len(phraseGrams))
+}
+
+// Character trigrams of the words in s, padded so word boundaries count
+func trigrams(s string) map[string]bool {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	grams := make(map[string]bool)
+	for _, word := range words {
+		padded := []rune(" " + word + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			grams[string(padded[i:i+3])] = true
+		}
+	}
+	return grams
+}
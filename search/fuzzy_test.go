@@ -0,0 +1,83 @@
+package biblescholar
+
+import "testing"
+
+func TestPhraseConfidence(t *testing.T) {
+	shepherd := "The LORD is my shepherd; I shall not want."
+	cases := []struct {
+		phrase string
+		text   string
+		min    float64
+		max    float64
+	}{
+		{"the lord is my shepherd", shepherd, 1, 1},
+		{"The Lord is my Shepherd!", shepherd, 1, 1},
+		// Misspelled
+		{"the lord is my shepard", shepherd, 0.8, 0.99},
+		// Half remembered
+		{"the lord is my shepherd I shall not be in want", shepherd, LowConfidence, 0.99},
+		{"for god so loved the world", shepherd, 0, MinFuzzyConfidence},
+		{"", shepherd, 0, 0},
+		{"the lord", "", 0, 0},
+	}
+	for _, c := range cases {
+		confidence := PhraseConfidence(c.phrase, c.text)
+		if confidence < c.min || confidence > c.max {
+			t.Errorf("PhraseConfidence(%q, %q): expected between %v and %v, got %v", c.phrase, c.text, c.min, c.max, confidence)
+		}
+	}
+}
+
+func TestFuzzySearch(t *testing.T) {
+	index, cleanup := newTestIndex(t)
+	defer cleanup()
+
+	var verses []*Verse
+	for _, version := range []string{"KJV", "WEB"} {
+		verses = append(verses,
+			&Verse{Version: version, Book: "Psalm", Chapter: 23, Verse: 1, Text: "The LORD is my shepherd; I shall not want."},
+			&Verse{Version: version, Book: "John", Chapter: 10, Verse: 11, Text: "I am the good shepherd: the good shepherd giveth his life for the sheep."},
+			&Verse{Version: version, Book: "John", Chapter: 3, Verse: 16, Text: "For God so loved the world, that he gave his only begotten Son."},
+		)
+	}
+	source := &memorySource{verses: verses, lines: make([]int, len(verses)), total: len(verses)}
+	if _, err := IndexFromSources(index, []VerseSource{source}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	matches, total, err := FuzzySearch(index, "the lord is my shepard", nil, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || len(matches) != 2 {
+		t.Fatalf("Expected Psalm 23:1 in both versions, got %d matches", total)
+	}
+	for _, m := range matches {
+		if m.Verse.Ref() != (VerseRef{"Psalm", 23, 1}) || m.Confidence < LowConfidence {
+			t.Errorf("Unexpected match: %s %s with confidence %v", m.Verse.Version, m.Verse.Ref(), m.Confidence)
+		}
+	}
+
+	matches, total, err = FuzzySearch(index, "the lord is my shepard", []string{"WEB"}, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 || matches[0].Verse.Version != "WEB" {
+		t.Errorf("Expected a match in WEB only, got %d matches", total)
+	}
+
+	// Pages past the end are empty but still count every match
+	matches, total, err = FuzzySearch(index, "the lord is my shepard", nil, 2, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || len(matches) != 0 {
+		t.Errorf("Expected an empty page of 2 matches, got %d of %d", len(matches), total)
+	}
+
+	for _, page := range [][2]int{{-1, 10}, {0, -1}} {
+		if _, _, err := FuzzySearch(index, "the lord is my shepard", nil, page[0], page[1]); err == nil {
+			t.Errorf("Expected an error for from %d and size %d", page[0], page[1])
+		}
+	}
+}
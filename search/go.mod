@@ -3,7 +3,6 @@ module github.com/turtlemonvh/biblescholar/search
 go 1.13
 
 require (
-	github.com/Jeffail/gabs v1.4.0
 	github.com/RoaringBitmap/roaring v0.4.21 // indirect
 	github.com/blevesearch/bleve v0.8.1
 	github.com/blevesearch/go-porterstemmer v1.0.2 // indirect
@@ -12,7 +11,7 @@ require (
 	github.com/couchbase/ghistogram v0.1.0 // indirect
 	github.com/couchbase/moss v0.0.0-20190322010551-a0cae174c498 // indirect
 	github.com/couchbase/vellum v0.0.0-20190829182332-ef2e028c01fd // indirect
-	github.com/etcd-io/bbolt v1.3.3 // indirect
+	github.com/etcd-io/bbolt v1.3.3
 	github.com/gin-gonic/contrib v0.0.0-20191209060500-d6e26eeaa607
 	github.com/gin-gonic/gin v1.5.0
 	github.com/rcrowley/go-metrics v0.0.0-20190826022208-cac0b30c2563
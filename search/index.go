@@ -1,12 +1,7 @@
 package biblescholar
 
 import (
-	"encoding/csv"
-	"fmt"
-	"io"
 	"os"
-	"path/filepath"
-	"unicode/utf8"
 
 	"github.com/blevesearch/bleve"
 	"github.com/blevesearch/bleve/analysis/analyzer/keyword"
@@ -47,6 +42,11 @@ func CreateOrOpenIndex(indexName string) bleve.Index {
 		verseMapping := bleve.NewNumericFieldMapping()
 		verseMapping.IncludeInAll = false
 
+		// Searchable by field, e.g. "Heading:beatitudes", without matching searches of the verse text
+		headingMapping := bleve.NewTextFieldMapping()
+		headingMapping.Analyzer = "en"
+		headingMapping.IncludeInAll = false
+
 		// Add document mapping, including field mappings
 		docMapping := bleve.NewDocumentStaticMapping()
 		docMapping.AddFieldMappingsAt("Book", bookMapping)
@@ -55,6 +55,7 @@ func CreateOrOpenIndex(indexName string) bleve.Index {
 		docMapping.AddFieldMappingsAt("Chapter", chapterMapping)
 		docMapping.AddFieldMappingsAt("Verse", verseMapping)
 		docMapping.AddFieldMappingsAt("Text", textMapping)
+		docMapping.AddFieldMappingsAt("Heading", headingMapping)
 		docMapping.Dynamic = false
 		idxMapping.AddDocumentMapping("verse", docMapping)
 
@@ -68,64 +69,34 @@ func CreateOrOpenIndex(indexName string) bleve.Index {
 	return index
 }
 
-func IndexFromTSVs(index bleve.Index, dirpath string) (int, error) {
-	nindexed := 0
-	matches, err := filepath.Glob(fmt.Sprintf("%s/*.tsv", dirpath))
+// Index every verse from a path: a data directory, a single file, a zip archive, or "-" for stdin
+// The format is picked per file unless one is given; the version is required for files that do not name their translation, e.g. USFM books
+func IndexFromFiles(index bleve.Index, path string, format string, version string) (int, error) {
+	sources, err := OpenVerseSources(path, format, version)
 	if err != nil {
-		return nindexed, err
+		return 0, err
 	}
+	return IndexFromSources(index, sources, nil)
+}
 
-	for _, match := range matches {
-		fmt.Println("Starting to index records from: ", match)
-		nindexedPerFile := 0
+// Distinct values of a keyword field, e.g. every Version in the index
+func IndexedTerms(index bleve.Index, field string) ([]string, error) {
+	dict, err := index.FieldDict(field)
+	if err != nil {
+		return nil, err
+	}
+	defer dict.Close()
 
-		f, err := os.Open(match)
+	var terms []string
+	for {
+		entry, err := dict.Next()
 		if err != nil {
-			return nindexed, err
+			return nil, err
 		}
-
-		r := csv.NewReader(f)
-
-		tabRune, _ := utf8.DecodeRuneInString("\t")
-		r.Comma = tabRune
-
-		// FIXME: Read in a line at a time, and feed in directly to indexer
-		// FIXME: Use the bulk indexer for performance improvements
-		b := index.NewBatch()
-		for {
-			record, err := r.Read()
-			if err != nil {
-				if err == io.EOF {
-					break
-				}
-				return nindexed, err
-			}
-
-			verse := NewVerseFromLine(record)
-
-			// FIXME: Check for error
-			b.Index(verse.Id(), verse)
-
-			nindexedPerFile++
-			nindexed++
-
-			if nindexed%100 == 0 {
-				err := index.Batch(b)
-				if err != nil {
-					return nindexed, err
-				}
-				b = index.NewBatch()
-				fmt.Printf("Indexed %d records from: %s [ %d total ] \n", nindexedPerFile, match, nindexed)
-			}
+		if entry == nil {
+			break
 		}
-
-		// CLeanup batch
-		err = index.Batch(b)
-		if err != nil {
-			return nindexed, err
-		}
-		fmt.Printf("Indexed %d records from: %s [ %d total ] \n", nindexedPerFile, match, nindexed)
+		terms = append(terms, entry.Term)
 	}
-
-	return nindexed, nil
+	return terms, nil
 }
@@ -0,0 +1,312 @@
+package biblescholar
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/blevesearch/bleve"
+	log "github.com/sirupsen/logrus"
+)
+
+// Indexing in stages: readers pull verses from sources, parsers map them into batches sized by bytes,
+// and writers commit the batches. The queues between the stages are bounded, so readers wait while the index catches up.
+
+// How IndexFromSources spreads its work; fields left at zero take the value in DefaultIndexOptions
+type IndexOptions struct {
+	// Sources read at once
+	ReadWorkers int
+	// Goroutines mapping verses into batches
+	ParseWorkers int
+	// Batches committed to the index at once
+	WriteWorkers int
+	// Verse data in each batch, in bytes
+	BatchBytes int
+	// Verses read ahead of the parsers
+	QueueSize int
+	// How often progress is logged
+	ProgressInterval time.Duration
+}
+
+var DefaultIndexOptions = IndexOptions{
+	ReadWorkers:  2,
+	ParseWorkers: runtime.NumCPU(),
+	WriteWorkers: 2,
+	// Much larger batches are slower to write, e.g. 1MB batches take several times as long as 64KB ones
+	BatchBytes:       64 << 10,
+	QueueSize:        1000,
+	ProgressInterval: 5 * time.Second,
+}
+
+func (o *IndexOptions) withDefaults() IndexOptions {
+	opts := DefaultIndexOptions
+	if o == nil {
+		return opts
+	}
+	if o.ReadWorkers > 0 {
+		opts.ReadWorkers = o.ReadWorkers
+	}
+	if o.ParseWorkers > 0 {
+		opts.ParseWorkers = o.ParseWorkers
+	}
+	if o.WriteWorkers > 0 {
+		opts.WriteWorkers = o.WriteWorkers
+	}
+	if o.BatchBytes > 0 {
+		opts.BatchBytes = o.BatchBytes
+	}
+	if o.QueueSize > 0 {
+		opts.QueueSize = o.QueueSize
+	}
+	if o.ProgressInterval > 0 {
+		opts.ProgressInterval = o.ProgressInterval
+	}
+	return opts
+}
+
+// A batch waiting to be written, with the number of verses in it
+type pendingBatch struct {
+	batch  *bleve.Batch
+	verses int
+}
+
+type indexPipeline struct {
+	index bleve.Index
+	opts  IndexOptions
+
+	verses  chan *Verse
+	batches chan *pendingBatch
+
+	// Closed on the first error, to stop every stage
+	done    chan struct{}
+	errOnce sync.Once
+	err     error
+
+	start time.Time
+	// Bytes in all sources, or 0 if any size is not known, e.g. for stdin
+	totalBytes int64
+	// Updated atomically
+	bytesRead  int64
+	versesRead int64
+	indexed    int64
+}
+
+// Index every verse from the sources, several sources at a time
+// Verses that are in more than one source end up with whichever is written last; check for duplicates with a Linter first
+// Each source is closed when it is finished
+func IndexFromSources(index bleve.Index, sources []VerseSource, opts *IndexOptions) (int, error) {
+	p := &indexPipeline{
+		index: index,
+		opts:  opts.withDefaults(),
+		done:  make(chan struct{}),
+		start: time.Now(),
+	}
+	p.verses = make(chan *Verse, p.opts.QueueSize)
+	// One batch per writer waits to be written; parsers wait after that
+	p.batches = make(chan *pendingBatch, p.opts.WriteWorkers)
+
+	queue := make(chan VerseSource, len(sources))
+	sizesKnown := true
+	for _, source := range sources {
+		size := source.Info().Size
+		sizesKnown = sizesKnown && size != 0
+		p.totalBytes += size
+		queue <- source
+	}
+	close(queue)
+	if !sizesKnown {
+		p.totalBytes = 0
+	}
+
+	var readers, parsers, writers sync.WaitGroup
+	for i := 0; i < p.opts.ReadWorkers; i++ {
+		readers.Add(1)
+		go p.read(queue, &readers)
+	}
+	for i := 0; i < p.opts.ParseWorkers; i++ {
+		parsers.Add(1)
+		go p.parse(&parsers)
+	}
+	for i := 0; i < p.opts.WriteWorkers; i++ {
+		writers.Add(1)
+		go p.write(&writers)
+	}
+	stopReports := make(chan struct{})
+	go p.report(stopReports)
+
+	readers.Wait()
+	close(p.verses)
+	parsers.Wait()
+	close(p.batches)
+	writers.Wait()
+	close(stopReports)
+
+	if p.err != nil {
+		p.logProgress("Stopped indexing after an error.")
+	} else {
+		p.logProgress("Finished indexing.")
+	}
+	return int(atomic.LoadInt64(&p.indexed)), p.err
+}
+
+func (p *indexPipeline) fail(err error) {
+	p.errOnce.Do(func() {
+		p.err = err
+		close(p.done)
+	})
+}
+
+func (p *indexPipeline) failed() bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Reader stage: one source at a time from the queue, until the queue is empty
+func (p *indexPipeline) read(queue chan VerseSource, wg *sync.WaitGroup) {
+	defer wg.Done()
+	for source := range queue {
+		if !p.failed() {
+			p.readSource(source)
+		}
+		source.Close()
+	}
+}
+
+func (p *indexPipeline) readSource(source VerseSource) {
+	var nread int
+	var bytesRead int64
+	for {
+		v, err := source.Next()
+
+		info := source.Info()
+		atomic.AddInt64(&p.bytesRead, info.BytesRead-bytesRead)
+		bytesRead = info.BytesRead
+
+		if err == io.EOF {
+			break
+		}
+		if rowErr, ok := err.(*RowError); ok {
+			log.WithFields(log.Fields{
+				"source": rowErr.Source,
+				"line":   rowErr.Line,
+				"error":  rowErr.Err,
+			}).Warn("Skipping a row that could not be read; use --strict or the lint command to check files before indexing.")
+			continue
+		}
+		if err != nil {
+			p.fail(err)
+			return
+		}
+
+		select {
+		case p.verses <- v:
+			nread++
+			atomic.AddInt64(&p.versesRead, 1)
+		case <-p.done:
+			return
+		}
+	}
+
+	info := source.Info()
+	log.WithFields(log.Fields{
+		"source":      info.Name,
+		"format":      info.Format,
+		"compression": info.Compression,
+		"verses":      nread,
+	}).Info("Finished reading source.")
+}
+
+// Approximate bytes a verse adds to a batch
+func verseBytes(v *Verse) int {
+	return len(v.Text) + len(v.Heading) + len(v.Book) + len(v.Version) + len(v.VersionBook) + 64
+}
+
+// Parse stage: map verses into batches, handing each on once it holds BatchBytes of verses
+func (p *indexPipeline) parse(wg *sync.WaitGroup) {
+	defer wg.Done()
+	b, nverses, size := p.index.NewBatch(), 0, 0
+	for v := range p.verses {
+		if p.failed() {
+			// Drain the queue so readers are not left waiting
+			continue
+		}
+		if err := b.Index(v.Id(), v); err != nil {
+			p.fail(fmt.Errorf("Error indexing %s: %v", v.Id(), err))
+			continue
+		}
+		nverses++
+		size += verseBytes(v)
+		if size >= p.opts.BatchBytes {
+			p.send(&pendingBatch{batch: b, verses: nverses})
+			b, nverses, size = p.index.NewBatch(), 0, 0
+		}
+	}
+	if nverses > 0 {
+		p.send(&pendingBatch{batch: b, verses: nverses})
+	}
+}
+
+func (p *indexPipeline) send(b *pendingBatch) {
+	select {
+	case p.batches <- b:
+	case <-p.done:
+	}
+}
+
+// Write stage
+func (p *indexPipeline) write(wg *sync.WaitGroup) {
+	defer wg.Done()
+	for b := range p.batches {
+		if p.failed() {
+			continue
+		}
+		if err := p.index.Batch(b.batch); err != nil {
+			p.fail(err)
+			continue
+		}
+		atomic.AddInt64(&p.indexed, int64(b.verses))
+	}
+}
+
+func (p *indexPipeline) report(stop chan struct{}) {
+	ticker := time.NewTicker(p.opts.ProgressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.logProgress("Indexing.")
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Verses indexed, docs/sec, and an ETA when the size of the sources is known
+// The total number of verses is estimated from the verses in the bytes read so far
+func (p *indexPipeline) logProgress(msg string) {
+	indexed := atomic.LoadInt64(&p.indexed)
+	elapsed := time.Since(p.start)
+	rate := float64(indexed) / elapsed.Seconds()
+	fields := log.Fields{
+		"indexed":      indexed,
+		"elapsed":      elapsed.Round(time.Second).String(),
+		"docs_per_sec": int(rate),
+	}
+	bytesRead, read := atomic.LoadInt64(&p.bytesRead), atomic.LoadInt64(&p.versesRead)
+	if p.totalBytes > 0 && bytesRead > 0 && indexed > 0 {
+		total := float64(read) * float64(p.totalBytes) / float64(bytesRead)
+		if total < float64(read) {
+			total = float64(read)
+		}
+		fields["percent"] = int(float64(indexed) / total * 100)
+		fields["eta"] = time.Duration((total - float64(indexed)) / rate * float64(time.Second)).Round(time.Second).String()
+	}
+	log.WithFields(fields).Info(msg)
+}
@@ -0,0 +1,132 @@
+package biblescholar
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/blevesearch/bleve"
+)
+
+// A new index in a temporary directory, removed by the returned function
+func newTestIndex(t *testing.T) (bleve.Index, func()) {
+	dir, err := ioutil.TempDir("", "index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	index := CreateOrOpenIndex(filepath.Join(dir, DefaultIndexName))
+	return index, func() {
+		index.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+// Verses 1 to n of Psalm 119 in a version
+func testSource(version string, n int) *memorySource {
+	m := &memorySource{info: SourceInfo{Name: version + ".tsv", Size: int64(n * 100)}}
+	for i := 1; i <= n; i++ {
+		m.verses = append(m.verses, &Verse{
+			Version:     version,
+			Book:        "Psalm",
+			Chapter:     119,
+			Verse:       i,
+			Text:        fmt.Sprintf("Verse %d of the longest psalm", i),
+			VersionBook: version + "-Psalm",
+		})
+		m.lines = append(m.lines, i)
+	}
+	m.total = n
+	return m
+}
+
+// Hands out a row error, then verses, then fails, recording whether it was closed
+type failingSource struct {
+	*memorySource
+	rowErrors int
+	fail      bool
+	closed    bool
+}
+
+func (f *failingSource) Next() (*Verse, error) {
+	if f.rowErrors > 0 {
+		f.rowErrors--
+		return nil, &RowError{Source: f.info.Name, Line: 1, Err: fmt.Errorf("Expected 5 columns, found 2")}
+	}
+	v, err := f.memorySource.Next()
+	if err == io.EOF && f.fail {
+		return nil, fmt.Errorf("Error reading '%s': unexpected end of data", f.info.Name)
+	}
+	return v, err
+}
+
+func (f *failingSource) Close() error {
+	f.closed = true
+	return nil
+}
+
+func TestIndexFromSources(t *testing.T) {
+	index, cleanup := newTestIndex(t)
+	defer cleanup()
+
+	skipping := &failingSource{memorySource: testSource("WEB", 50), rowErrors: 3}
+	sources := []VerseSource{testSource("KJV", 176), testSource("ESV", 176), skipping}
+	// Small batches and queues, so every stage waits on the next
+	opts := &IndexOptions{ReadWorkers: 2, ParseWorkers: 3, WriteWorkers: 2, BatchBytes: 1000, QueueSize: 4}
+	n, err := IndexFromSources(index, sources, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 402 {
+		t.Errorf("Expected 402 verses indexed, got %d", n)
+	}
+	if count, _ := index.DocCount(); count != 402 {
+		t.Errorf("Expected 402 documents, got %d", count)
+	}
+	for _, id := range []string{"Psalm-119-1-KJV", "Psalm-119-176-ESV", "Psalm-119-50-WEB"} {
+		if doc, err := index.Document(id); err != nil || doc == nil {
+			t.Errorf("Expected %s to be indexed, got %v", id, err)
+		}
+	}
+	if !skipping.closed {
+		t.Errorf("Expected every source to be closed")
+	}
+}
+
+func TestIndexFromSourcesError(t *testing.T) {
+	index, cleanup := newTestIndex(t)
+	defer cleanup()
+
+	var sources []VerseSource
+	var all []*failingSource
+	for i, version := range []string{"KJV", "ESV", "WEB", "NIV"} {
+		f := &failingSource{memorySource: testSource(version, 100), fail: i == 0}
+		sources = append(sources, f)
+		all = append(all, f)
+	}
+	n, err := IndexFromSources(index, sources, &IndexOptions{ReadWorkers: 1, QueueSize: 10})
+	if err == nil || err.Error() != "Error reading 'KJV.tsv': unexpected end of data" {
+		t.Errorf("Expected the error from the first source, got %v", err)
+	}
+	if n >= 400 {
+		t.Errorf("Expected indexing to stop early, got %d verses indexed", n)
+	}
+	for _, f := range all {
+		if !f.closed {
+			t.Errorf("Expected %s to be closed", f.info.Name)
+		}
+	}
+}
+
+func TestIndexOptionsDefaults(t *testing.T) {
+	var nilOpts *IndexOptions
+	if opts := nilOpts.withDefaults(); opts != DefaultIndexOptions {
+		t.Errorf("Expected the defaults for nil options, got %+v", opts)
+	}
+	opts := (&IndexOptions{WriteWorkers: 5, BatchBytes: -1}).withDefaults()
+	if opts.WriteWorkers != 5 || opts.BatchBytes != DefaultIndexOptions.BatchBytes || opts.ReadWorkers != DefaultIndexOptions.ReadWorkers {
+		t.Errorf("Expected only WriteWorkers to change, got %+v", opts)
+	}
+}
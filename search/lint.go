@@ -0,0 +1,320 @@
+package biblescholar
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Checking data files before they are indexed, for the lint command and strict indexing
+
+// A problem with one row or verse of a data file
+type LintIssue struct {
+	Source string
+	// 0 for formats without lines
+	Line   int
+	Reason string
+}
+
+// e.g. "KJV.tsv:12: Invalid chapter number: 'x'"
+func (i *LintIssue) String() string {
+	location := i.Source
+	if i.Line != 0 {
+		location = fmt.Sprintf("%s:%d", i.Source, i.Line)
+	}
+	if i.Reason == "" {
+		return location
+	}
+	return fmt.Sprintf("%s: %s", location, i.Reason)
+}
+
+// Verses read for one version, to compare against the canon in Books
+type VersionSummary struct {
+	Version  string
+	Verses   int
+	Chapters int
+	// Verses read per book, by canonical name
+	BookVerses map[string]int
+}
+
+// Checks verses source by source, remembering what it has seen to find duplicates across files
+type Linter struct {
+	Issues []*LintIssue
+
+	// Verse id -> where it was first read
+	seen     map[string]string
+	versions map[string]*VersionSummary
+	// Version and book -> chapters read
+	chapters map[string]map[int]bool
+	// Source and book name -> whether a warning was logged for using an alias
+	aliases map[string]bool
+}
+
+func NewLinter() *Linter {
+	return &Linter{
+		seen:     make(map[string]string),
+		versions: make(map[string]*VersionSummary),
+		chapters: make(map[string]map[int]bool),
+		aliases:  make(map[string]bool),
+	}
+}
+
+// Read every verse of a source, recording problems
+// Verses without problems are handed to keep, if it is not nil; reading stops at the first error that is not a *RowError
+func (l *Linter) Check(source VerseSource, keep func(v *Verse, line int)) {
+	name := source.Info().Name
+	// Last verse read per version, to check the order within this source
+	last := make(map[string]*Verse)
+	for {
+		v, err := source.Next()
+		if err == io.EOF {
+			return
+		}
+		if rowErr, ok := err.(*RowError); ok {
+			l.add(name, rowErr.Line, rowErr.Err.Error())
+			continue
+		}
+		if err != nil {
+			l.add(name, source.Line(), fmt.Sprintf("Stopped reading: %s", strings.TrimPrefix(err.Error(), fmt.Sprintf("Error reading '%s': ", name))))
+			return
+		}
+
+		line := source.Line()
+		if !l.checkVerse(v, name, line, last[v.Version]) {
+			continue
+		}
+		last[v.Version] = v
+		if keep != nil {
+			keep(v, line)
+		}
+	}
+}
+
+// Whether the verse can be indexed as it is
+// Books must be stored under their canonical name or a listed alias, the names passage lookups search for;
+// duplicates, order and counts go by the canonical name
+func (l *Linter) checkVerse(v *Verse, name string, line int, previous *Verse) bool {
+	ok := true
+	ref := v.Ref().String()
+
+	book, err := LookupBook(v.Book)
+	switch {
+	case err != nil:
+		l.add(name, line, fmt.Sprintf("Unknown book name: '%s'", v.Book))
+		book, ok = nil, false
+	case !isBookName(book, v.Book):
+		// e.g. a prefix like "Deuter", or a name in another language
+		l.add(name, line, fmt.Sprintf("Book name '%s' would not be found by passage lookups, use '%s'", v.Book, book.Name))
+		ok = false
+	case v.Chapter < 1:
+		l.add(name, line, fmt.Sprintf("Invalid chapter number: %d", v.Chapter))
+		ok = false
+	case v.Chapter > book.Chapters:
+		l.add(name, line, fmt.Sprintf("%s is past the end of %s, which has %d chapters", ref, book.Name, book.Chapters))
+		ok = false
+	}
+	if v.Verse < 1 {
+		l.add(name, line, fmt.Sprintf("Invalid verse number: %d", v.Verse))
+		ok = false
+	}
+
+	if strings.TrimSpace(v.Text) == "" {
+		l.add(name, line, fmt.Sprintf("Empty text for %s", ref))
+		ok = false
+	}
+
+	canonical := *v
+	if book != nil {
+		canonical.Book = book.Name
+		l.warnAlias(name, v.Book, book.Name)
+	}
+
+	location := (&LintIssue{Source: name, Line: line}).String()
+	if first, seen := l.seen[canonical.Id()]; seen {
+		l.add(name, line, fmt.Sprintf("Duplicate verse %s in %s, first read at %s", ref, v.Version, first))
+		return false
+	}
+	l.seen[canonical.Id()] = location
+
+	if previous != nil && book != nil && !versesInOrder(previous, v) {
+		l.add(name, line, fmt.Sprintf("%s comes after %s", ref, previous.Ref()))
+		ok = false
+	}
+
+	if book != nil {
+		l.count(&canonical)
+	}
+	return ok
+}
+
+// Aliases are indexed as they are, so searches by book name only match the name used in the file
+func (l *Linter) warnAlias(name string, alias string, canonical string) {
+	key := name + "-" + alias
+	if alias == canonical || l.aliases[key] {
+		return
+	}
+	l.aliases[key] = true
+	log.WithFields(log.Fields{
+		"source":    name,
+		"book":      alias,
+		"canonical": canonical,
+	}).Warn("Book is named by an alias.")
+}
+
+// Whether the book is indexed as it is named, exactly as bookQuery matches it
+func isBookName(book *Book, name string) bool {
+	for _, n := range book.Names() {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Whether b comes after a in canonical order
+func versesInOrder(a *Verse, b *Verse) bool {
+	if ia, ib := bookIndex(a.Book), bookIndex(b.Book); ia != ib {
+		return ia < ib
+	}
+	return a.Ref().Before(b.Ref())
+}
+
+// Position in Books, or -1 for an unknown name; aliases count as the book they name
+func bookIndex(name string) int {
+	book, err := LookupBook(name)
+	if err != nil {
+		return -1
+	}
+	for i, b := range Books {
+		if b == book {
+			return i
+		}
+	}
+	return -1
+}
+
+func (l *Linter) add(name string, line int, reason string) {
+	l.Issues = append(l.Issues, &LintIssue{Source: name, Line: line, Reason: reason})
+}
+
+func (l *Linter) count(v *Verse) {
+	summary, ok := l.versions[v.Version]
+	if !ok {
+		summary = &VersionSummary{Version: v.Version, BookVerses: make(map[string]int)}
+		l.versions[v.Version] = summary
+	}
+	summary.Verses++
+	summary.BookVerses[v.Book]++
+
+	key := v.Version + "-" + v.Book
+	if l.chapters[key] == nil {
+		l.chapters[key] = make(map[int]bool)
+	}
+	if !l.chapters[key][v.Chapter] {
+		l.chapters[key][v.Chapter] = true
+		summary.Chapters++
+	}
+}
+
+// Verses read per version, sorted by version
+func (l *Linter) Summary() []*VersionSummary {
+	var summaries []*VersionSummary
+	for _, s := range l.versions {
+		summaries = append(summaries, s)
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Version < summaries[j].Version })
+	return summaries
+}
+
+// Check every source, keeping the verses read in memory
+// The sources returned hand out those verses again, so data read from stdin can be checked before any of it is indexed
+func (l *Linter) CheckAll(sources []VerseSource) []VerseSource {
+	var checked []VerseSource
+	for _, source := range sources {
+		m := &memorySource{}
+		l.Check(source, func(v *Verse, line int) {
+			m.verses = append(m.verses, v)
+			m.lines = append(m.lines, line)
+		})
+		m.info = source.Info()
+		m.total = len(m.verses)
+		source.Close()
+		checked = append(checked, m)
+	}
+	return checked
+}
+
+// Every issue, one per line, then how many verses, books and chapters of the canon each version has
+func (l *Linter) WriteReport(w io.Writer) {
+	for _, issue := range l.Issues {
+		fmt.Fprintln(w, issue)
+	}
+
+	totalVerses, totalChapters := 0, 0
+	for _, b := range Books {
+		totalVerses += bookVerseCounts[b.Name]
+		totalChapters += b.Chapters
+	}
+	for _, s := range l.Summary() {
+		fmt.Fprintf(w, "%s: %d of %d verses, %d of %d books, %d of %d chapters\n",
+			s.Version, s.Verses, totalVerses, len(s.BookVerses), len(Books), s.Chapters, totalChapters)
+
+		var missing []string
+		for _, b := range Books {
+			n, ok := s.BookVerses[b.Name]
+			switch {
+			case !ok:
+				missing = append(missing, b.Name)
+			case n != bookVerseCounts[b.Name]:
+				fmt.Fprintf(w, "  %s: %d of %d verses\n", b.Name, n, bookVerseCounts[b.Name])
+			}
+		}
+		switch {
+		case len(missing) == 0:
+		case len(missing) <= 10:
+			fmt.Fprintf(w, "  Missing: %s\n", strings.Join(missing, ", "))
+		default:
+			fmt.Fprintf(w, "  Missing %d books\n", len(missing))
+		}
+	}
+	fmt.Fprintf(w, "%d problems found\n", len(l.Issues))
+}
+
+// Verses already read, handed out again
+type memorySource struct {
+	info   SourceInfo
+	verses []*Verse
+	lines  []int
+	line   int
+	total  int
+}
+
+func (m *memorySource) Next() (*Verse, error) {
+	if len(m.verses) == 0 {
+		return nil, io.EOF
+	}
+	v := m.verses[0]
+	m.line = m.lines[0]
+	m.verses, m.lines = m.verses[1:], m.lines[1:]
+	return v, nil
+}
+
+func (m *memorySource) Line() int {
+	return m.line
+}
+
+// BytesRead goes up with the share of the verses handed out again, for progress reports
+func (m *memorySource) Info() SourceInfo {
+	info := m.info
+	if m.total != 0 {
+		info.BytesRead = info.Size * int64(m.total-len(m.verses)) / int64(m.total)
+	}
+	return info
+}
+
+func (m *memorySource) Close() error {
+	return nil
+}
@@ -0,0 +1,73 @@
+package biblescholar
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckVerse(t *testing.T) {
+	genesis := &Verse{Version: "KJV", Book: "Genesis", Chapter: 1, Verse: 1, Text: "In the beginning"}
+	cases := []struct {
+		name     string
+		verse    *Verse
+		previous *Verse
+		ok       bool
+		reason   string
+	}{
+		{"valid", &Verse{Version: "KJV", Book: "Genesis", Chapter: 1, Verse: 2, Text: "And the earth"}, genesis, true, ""},
+		{"alias", &Verse{Version: "KJV", Book: "Song of Solomon", Chapter: 1, Verse: 1, Text: "The song of songs"}, genesis, true, ""},
+		{"alias for psalms", &Verse{Version: "KJV", Book: "Psalms", Chapter: 23, Verse: 1, Text: "The Lord is my shepherd"}, genesis, true, ""},
+		{"prefix", &Verse{Version: "KJV", Book: "Deuter", Chapter: 1, Verse: 1, Text: "x"}, genesis, false, "Book name 'Deuter' would not be found by passage lookups, use 'Deuteronomy'"},
+		{"lower case", &Verse{Version: "KJV", Book: "psalms", Chapter: 23, Verse: 1, Text: "x"}, genesis, false, "Book name 'psalms' would not be found by passage lookups, use 'Psalm'"},
+		{"german name", &Verse{Version: "LUT", Book: "Johannes", Chapter: 3, Verse: 16, Text: "x"}, nil, false, "Book name 'Johannes' would not be found by passage lookups, use 'John'"},
+		{"unknown book", &Verse{Version: "KJV", Book: "Hezekiah", Chapter: 1, Verse: 1, Text: "x"}, nil, false, "Unknown book name: 'Hezekiah'"},
+		{"chapter 0", &Verse{Version: "KJV", Book: "Genesis", Chapter: 0, Verse: 1, Text: "x"}, nil, false, "Invalid chapter number: 0"},
+		{"verse 0", &Verse{Version: "KJV", Book: "Genesis", Chapter: 1, Verse: 0, Text: "x"}, nil, false, "Invalid verse number: 0"},
+		{"past the end", &Verse{Version: "KJV", Book: "Jude", Chapter: 2, Verse: 1, Text: "x"}, nil, false, "Jude 2:1 is past the end of Jude, which has 1 chapters"},
+		{"empty text", &Verse{Version: "KJV", Book: "Genesis", Chapter: 1, Verse: 3, Text: " "}, nil, false, "Empty text for Genesis 1:3"},
+		{"out of order", &Verse{Version: "KJV", Book: "Genesis", Chapter: 1, Verse: 1, Text: "x"}, &Verse{Version: "KJV", Book: "Exodus", Chapter: 1, Verse: 1}, false, "Genesis 1:1 comes after Exodus 1:1"},
+		{"alias in order", &Verse{Version: "KJV", Book: "Song of Solomon", Chapter: 1, Verse: 2, Text: "x"}, &Verse{Version: "KJV", Book: "Ecclesiastes", Chapter: 12, Verse: 14}, true, ""},
+	}
+	for _, c := range cases {
+		l := NewLinter()
+		ok := l.checkVerse(c.verse, "KJV.tsv", 2, c.previous)
+		if ok != c.ok {
+			t.Errorf("%s: expected ok to be %v, got %v with issues %v", c.name, c.ok, ok, l.Issues)
+			continue
+		}
+		switch {
+		case c.reason == "" && len(l.Issues) != 0:
+			t.Errorf("%s: expected no issues, got %v", c.name, l.Issues)
+		case c.reason != "" && (len(l.Issues) != 1 || l.Issues[0].Reason != c.reason):
+			t.Errorf("%s: expected the issue '%s', got %v", c.name, c.reason, l.Issues)
+		}
+	}
+}
+
+func TestCheckVerseDuplicates(t *testing.T) {
+	l := NewLinter()
+	first := &Verse{Version: "KJV", Book: "Song of Songs", Chapter: 1, Verse: 1, Text: "The song of songs"}
+	if !l.checkVerse(first, "KJV.tsv", 1, nil) {
+		t.Fatalf("Expected the first verse to be ok, got %v", l.Issues)
+	}
+
+	// The same verse under an alias, in another file
+	alias := &Verse{Version: "KJV", Book: "Song of Solomon", Chapter: 1, Verse: 1, Text: "The song of songs"}
+	if l.checkVerse(alias, "more.tsv", 4, nil) {
+		t.Fatalf("Expected a duplicate to be rejected")
+	}
+	if len(l.Issues) != 1 || !strings.Contains(l.Issues[0].Reason, "first read at KJV.tsv:1") {
+		t.Errorf("Expected a duplicate issue pointing at KJV.tsv:1, got %v", l.Issues)
+	}
+
+	// Another version of the same verse is not a duplicate
+	other := &Verse{Version: "ESV", Book: "Song of Songs", Chapter: 1, Verse: 1, Text: "The Song of Songs"}
+	if !l.checkVerse(other, "ESV.tsv", 1, nil) {
+		t.Errorf("Expected another version to be ok, got %v", l.Issues)
+	}
+
+	summary := l.Summary()
+	if len(summary) != 2 || summary[1].BookVerses["Song of Songs"] != 1 {
+		t.Errorf("Expected verses counted by canonical name, got %+v", summary[1])
+	}
+}
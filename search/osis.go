@@ -0,0 +1,221 @@
+package biblescholar
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Reading OSIS XML, used for many public domain texts
+// http://www.crosswire.org/osis/
+
+// Book ids used in osisIDs, mapped to the names in Books
+var osisBookIDs = map[string]string{
+	"Gen": "Genesis", "Exod": "Exodus", "Lev": "Leviticus", "Num": "Numbers", "Deut": "Deuteronomy",
+	"Josh": "Joshua", "Judg": "Judges", "Ruth": "Ruth", "1Sam": "1 Samuel", "2Sam": "2 Samuel",
+	"1Kgs": "1 Kings", "2Kgs": "2 Kings", "1Chr": "1 Chronicles", "2Chr": "2 Chronicles", "Ezra": "Ezra",
+	"Neh": "Nehemiah", "Esth": "Esther", "Job": "Job", "Ps": "Psalm", "Prov": "Proverbs",
+	"Eccl": "Ecclesiastes", "Song": "Song of Songs", "Isa": "Isaiah", "Jer": "Jeremiah", "Lam": "Lamentations",
+	"Ezek": "Ezekiel", "Dan": "Daniel", "Hos": "Hosea", "Joel": "Joel", "Amos": "Amos",
+	"Obad": "Obadiah", "Jonah": "Jonah", "Mic": "Micah", "Nah": "Nahum", "Hab": "Habakkuk",
+	"Zeph": "Zephaniah", "Hag": "Haggai", "Zech": "Zechariah", "Mal": "Malachi",
+	"Matt": "Matthew", "Mark": "Mark", "Luke": "Luke", "John": "John", "Acts": "Acts",
+	"Rom": "Romans", "1Cor": "1 Corinthians", "2Cor": "2 Corinthians", "Gal": "Galatians", "Eph": "Ephesians",
+	"Phil": "Philippians", "Col": "Colossians", "1Thess": "1 Thessalonians", "2Thess": "2 Thessalonians", "1Tim": "1 Timothy",
+	"2Tim": "2 Timothy", "Titus": "Titus", "Phlm": "Philemon", "Heb": "Hebrews", "Jas": "James",
+	"1Pet": "1 Peter", "2Pet": "2 Peter", "1John": "1 John", "2John": "2 John", "3John": "3 John",
+	"Jude": "Jude", "Rev": "Revelation",
+}
+
+// Elements that separate words, e.g. paragraphs and poetry lines
+var osisBreakElements = map[string]bool{
+	"p": true, "l": true, "lg": true, "lb": true, "div": true, "chapter": true, "milestone": true,
+	"list": true, "item": true, "table": true, "row": true, "cell": true,
+}
+
+// Titles that name the book or chapter rather than a section
+var osisIgnoredTitles = map[string]bool{"main": true, "chapter": true, "runningHead": true}
+
+// What an open element means for the text inside it
+const (
+	osisPlain int = iota
+	// Notes and titles that are neither verse text nor headings
+	osisSkipped
+	osisHeading
+	// <verse osisID="John.3.16">...</verse>, ended by its closing tag
+	osisVerseContainer
+	// <verse sID="John.3.16" osisID="John.3.16"/>, ended by <verse eID="John.3.16"/>
+	osisVerseMilestone
+)
+
+// Reads verses one at a time, for both container and milestone verses
+type OSISReader struct {
+	dec       *xml.Decoder
+	c         *verseCollector
+	elements  []int
+	skipped   int
+	done      bool
+	line      int
+	unknownID map[string]bool
+}
+
+// The version is taken from the osisIDWork of the file; the one given is used if the file does not name one
+func NewOSISReader(r io.Reader, version string) *OSISReader {
+	return &OSISReader{
+		dec:       xml.NewDecoder(r),
+		c:         &verseCollector{version: version},
+		unknownID: make(map[string]bool),
+	}
+}
+
+// Next verse in the file, or io.EOF after the last one
+func (o *OSISReader) Next() (*Verse, error) {
+	for {
+		if v, line := o.c.next(); v != nil {
+			o.line = line
+			return v, nil
+		}
+		if o.done {
+			return nil, io.EOF
+		}
+
+		tok, err := o.dec.Token()
+		if err == io.EOF {
+			o.c.end()
+			o.done = true
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := o.handle(tok); err != nil {
+			line, _ := o.dec.InputPos()
+			return nil, fmt.Errorf("Line %d: %v", line, err)
+		}
+	}
+}
+
+// Line the last verse read started on
+func (o *OSISReader) Line() int {
+	return o.line
+}
+
+func (o *OSISReader) handle(tok xml.Token) error {
+	switch el := tok.(type) {
+	case xml.StartElement:
+		kind := osisPlain
+		name := el.Name.Local
+		switch {
+		case o.skipped > 0:
+			kind = osisSkipped
+		case name == "osisText":
+			if work := attr(el, "osisIDWork"); work != "" {
+				o.c.version = work
+			}
+		case name == "note" || name == "header":
+			// Notes, and the description of the work
+			kind = osisSkipped
+		case name == "title":
+			kind = osisHeading
+			if osisIgnoredTitles[attr(el, "type")] {
+				kind = osisSkipped
+			}
+		case name == "verse" && attr(el, "eID") != "":
+			kind = osisVerseMilestone
+			o.c.end()
+		case name == "verse":
+			kind = osisVerseContainer
+			if attr(el, "sID") != "" {
+				kind = osisVerseMilestone
+			}
+			if err := o.startVerse(attr(el, "osisID")); err != nil {
+				return err
+			}
+		case osisBreakElements[name]:
+			o.c.addText(" ")
+		}
+
+		switch kind {
+		case osisSkipped:
+			o.skipped++
+		case osisHeading:
+			o.c.startHeading()
+		}
+		o.elements = append(o.elements, kind)
+
+	case xml.EndElement:
+		if len(o.elements) == 0 {
+			return nil
+		}
+		kind := o.elements[len(o.elements)-1]
+		o.elements = o.elements[:len(o.elements)-1]
+		switch kind {
+		case osisSkipped:
+			o.skipped--
+		case osisHeading:
+			o.c.endHeading()
+		case osisVerseContainer:
+			o.c.end()
+		default:
+			if osisBreakElements[el.Name.Local] {
+				o.c.addText(" ")
+			}
+		}
+
+	case xml.CharData:
+		if o.skipped == 0 {
+			o.c.addText(string(el))
+		}
+	}
+	return nil
+}
+
+// Start a verse from an osisID such as "John.3.16"
+// Bridged verses, e.g. "John.3.16 John.3.17", are stored under the first verse
+// Verses from books outside the canon in Books, e.g. Tobit, are skipped
+func (o *OSISReader) startVerse(osisID string) error {
+	o.c.end()
+	ids := strings.Fields(osisID)
+	if len(ids) == 0 {
+		return fmt.Errorf("Verse without an osisID")
+	}
+	id := ids[0]
+	if i := strings.Index(id, ":"); i != -1 {
+		// Work prefix, e.g. "KJV:John.3.16"
+		id = id[i+1:]
+	}
+
+	parts := strings.Split(id, ".")
+	if len(parts) != 3 {
+		return fmt.Errorf("Invalid verse osisID: '%s'", osisID)
+	}
+	chapter, err := strconv.Atoi(parts[1])
+	if err != nil || chapter < 1 {
+		return fmt.Errorf("Invalid verse osisID: '%s'", osisID)
+	}
+	verse, err := strconv.Atoi(parts[2])
+	if err != nil || verse < 1 {
+		return fmt.Errorf("Invalid verse osisID: '%s'", osisID)
+	}
+
+	book, ok := osisBookIDs[parts[0]]
+	if !ok {
+		if !o.unknownID[parts[0]] {
+			o.unknownID[parts[0]] = true
+			log.WithFields(log.Fields{
+				"book": parts[0],
+			}).Warn("Skipping verses from unknown OSIS book.")
+		}
+		return nil
+	}
+	if o.c.version == "" {
+		return fmt.Errorf("No version given, and the osisText does not name one in osisIDWork")
+	}
+	line, _ := o.dec.InputPos()
+	o.c.start(book, chapter, verse, line)
+	return nil
+}
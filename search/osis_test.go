@@ -0,0 +1,75 @@
+package biblescholar
+
+import (
+	"strings"
+	"testing"
+)
+
+const osisFixture = `<?xml version="1.0" encoding="UTF-8"?>
+<osis xmlns="http://www.bibletechnologies.net/2003/OSIS/namespace">
+<osisText osisIDWork="KJV" osisRefWork="defaultReferenceScheme" xml:lang="en">
+<header><work osisWork="KJV"><title>King James Version</title></work></header>
+<div type="book" osisID="John">
+<title type="main">THE GOSPEL ACCORDING TO ST. JOHN</title>
+<chapter osisID="John.3">
+<title type="chapter">CHAPTER 3</title>
+<title type="section">Jesus and Nicodemus</title>
+<verse osisID="John.3.16">For God so <w lemma="strong:G25">loved</w> the world,<note type="study">Or <hi type="italic">cosmos</hi></note> that he gave his <transChange type="added">only</transChange> begotten Son.</verse>
+<verse osisID="John.3.17 John.3.18">For God sent not his Son<lb/>into the world.</verse>
+</chapter>
+</div>
+<div type="book" osisID="Tob"><chapter osisID="Tob.1"><verse osisID="Tob.1.1">Apocrypha</verse></chapter></div>
+<div type="book" osisID="Ps">
+<chapter sID="Ps.23" osisID="Ps.23"/>
+<title type="psalm" canonical="true">A Psalm of David.</title>
+<lg><l><verse sID="Ps.23.1" osisID="Ps.23.1"/>The LORD is my shepherd;</l><l>I shall not want.<verse eID="Ps.23.1"/></l>
+<l><verse sID="Ps.23.2" osisID="Ps.23.2"/>He maketh me to lie down<note>x</note> in green pastures<verse eID="Ps.23.2"/></l></lg>
+<chapter eID="Ps.23"/>
+</div>
+</osisText>
+</osis>
+`
+
+func TestOSISReader(t *testing.T) {
+	verses, lines := readAllVerses(t, NewOSISReader(strings.NewReader(osisFixture), "ignored"))
+	checkVerses(t, "KJV", verses, lines, []expectedVerse{
+		{VerseRef{"John", 3, 16}, 10, "For God so loved the world, that he gave his only begotten Son.", "Jesus and Nicodemus"},
+		{VerseRef{"John", 3, 17}, 11, "For God sent not his Son into the world.", ""},
+		{VerseRef{"Psalm", 23, 1}, 18, "The LORD is my shepherd; I shall not want.", "A Psalm of David."},
+		{VerseRef{"Psalm", 23, 2}, 19, "He maketh me to lie down in green pastures", ""},
+	})
+}
+
+// The version given is used when the file does not name its work
+func TestOSISReaderVersion(t *testing.T) {
+	data := strings.Replace(osisFixture, ` osisIDWork="KJV"`, "", 1)
+	verses, _ := readAllVerses(t, NewOSISReader(strings.NewReader(data), "KJVA"))
+	if len(verses) == 0 || verses[0].Version != "KJVA" {
+		t.Errorf("Expected verses in the version given, got %v", verses)
+	}
+}
+
+func TestOSISReaderErrors(t *testing.T) {
+	cases := []struct {
+		name  string
+		verse string
+	}{
+		{"missing osisID", `<verse>x</verse>`},
+		{"short osisID", `<verse osisID="John.3">x</verse>`},
+		{"bad chapter", `<verse osisID="John.x.16">x</verse>`},
+		{"bad verse", `<verse osisID="John.3.x">x</verse>`},
+		{"chapter 0", `<verse osisID="John.0.16">x</verse>`},
+		{"verse 0", `<verse osisID="John.3.0">x</verse>`},
+	}
+	for _, c := range cases {
+		data := `<osis><osisText osisIDWork="KJV"><div type="book" osisID="John">` + c.verse + `</div></osisText></osis>`
+		r := NewOSISReader(strings.NewReader(data), "")
+		var err error
+		for err == nil {
+			_, err = r.Next()
+		}
+		if !strings.HasPrefix(err.Error(), "Line 1: ") {
+			t.Errorf("%s: expected an error on line 1, got %v", c.name, err)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package biblescholar
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/search/query"
+)
+
+// Upper limit on the number of verses (across all versions) returned for a single passage lookup
+// Psalm 119 in 4 versions is about 700 verses
+const MaxPassageVerses = 5000
+
+// Returned when a lookup would exceed MaxPassageVerses
+var ErrPassageTooLong = errors.New("Passage is too long")
+
+// Fields to load for each verse in a passage
+var verseFields = []string{
+	"Version",
+	"Book",
+	"Chapter",
+	"Verse",
+	"Text",
+	"Heading",
+}
+
+// Match a single chapter and an inclusive range of verses within it
+// A verse bound of 0 leaves that side open
+func chapterVersesQuery(chapter int, startVerse int, endVerse int) query.Query {
+	conjuncts := []query.Query{numericEqualsQuery("Chapter", chapter)}
+	if startVerse > 1 || endVerse > 0 {
+		var min, max *float64
+		if startVerse > 1 {
+			f := float64(startVerse)
+			min = &f
+		}
+		if endVerse > 0 {
+			f := float64(endVerse)
+			max = &f
+		}
+		conjuncts = append(conjuncts, numericRangeQuery("Verse", min, max))
+	}
+	return bleve.NewConjunctionQuery(conjuncts...)
+}
+
+func numericEqualsQuery(field string, n int) query.Query {
+	f := float64(n)
+	return numericRangeQuery(field, &f, &f)
+}
+
+func numericRangeQuery(field string, min *float64, max *float64) query.Query {
+	inclusive := true
+	q := bleve.NewNumericRangeInclusiveQuery(min, max, &inclusive, &inclusive)
+	q.SetField(field)
+	return q
+}
+
+// Match any of the names a book may be stored under
+func bookQuery(book *Book) query.Query {
+	var disjuncts []query.Query
+	for _, name := range book.Names() {
+		q := bleve.NewTermQuery(name)
+		q.SetField("Book")
+		disjuncts = append(disjuncts, q)
+	}
+	return bleve.NewDisjunctionQuery(disjuncts...)
+}
+
+// Build a query matching every verse in a single passage
+func NewPassageQuery(p Passage) (query.Query, error) {
+	start, end := p.Bounds()
+	book, err := LookupBook(start.Book)
+	if err != nil {
+		return nil, err
+	}
+
+	var verses query.Query
+	switch {
+	case start.Chapter == end.Chapter:
+		verses = chapterVersesQuery(start.Chapter, start.Verse, end.Verse)
+	case start.Verse <= 1 && end.Verse == 0:
+		// Whole chapters
+		first, last := float64(start.Chapter), float64(end.Chapter)
+		verses = numericRangeQuery("Chapter", &first, &last)
+	default:
+		// Tail of the first chapter, any full chapters, then the head of the last chapter
+		disjuncts := []query.Query{chapterVersesQuery(start.Chapter, start.Verse, 0)}
+		if end.Chapter-start.Chapter > 1 {
+			first, last := float64(start.Chapter+1), float64(end.Chapter-1)
+			disjuncts = append(disjuncts, numericRangeQuery("Chapter", &first, &last))
+		}
+		disjuncts = append(disjuncts, chapterVersesQuery(end.Chapter, 1, end.Verse))
+		verses = bleve.NewDisjunctionQuery(disjuncts...)
+	}
+
+	return bleve.NewConjunctionQuery(bookQuery(book), verses), nil
+}
+
+// Build a query matching every verse in the list of passages, limited to the given versions
+// An empty list of versions matches all versions
+func NewReferenceListQuery(refs ReferenceList, versions []string) (query.Query, error) {
+	var passages []query.Query
+	for _, p := range refs {
+		q, err := NewPassageQuery(p)
+		if err != nil {
+			return nil, err
+		}
+		passages = append(passages, q)
+	}
+	return LimitToVersions(bleve.NewDisjunctionQuery(passages...), versions), nil
+}
+
+// Limit a query to verses from any of the given versions
+// An empty list of versions matches all versions
+func LimitToVersions(q query.Query, versions []string) query.Query {
+	if len(versions) == 0 {
+		return q
+	}
+	var versionQueries []query.Query
+	for _, version := range versions {
+		vq := bleve.NewTermQuery(version)
+		vq.SetField("Version")
+		versionQueries = append(versionQueries, vq)
+	}
+	return bleve.NewConjunctionQuery(q, bleve.NewDisjunctionQuery(versionQueries...))
+}
+
+// Fetch all verses in a list of passages
+// Verses are returned in the order the passages were listed, then by chapter and verse, then by version
+func LookupPassage(index bleve.Index, refs ReferenceList, versions []string) ([]*Verse, error) {
+	q, err := NewReferenceListQuery(refs, versions)
+	if err != nil {
+		return nil, err
+	}
+
+	searchRequest := bleve.NewSearchRequestOptions(q, MaxPassageVerses, 0, false)
+	searchRequest.Fields = verseFields
+	searchResult, err := index.Search(searchRequest)
+	if err != nil {
+		return nil, err
+	}
+	if searchResult.Total > MaxPassageVerses {
+		return nil, fmt.Errorf("%w: '%s' matched %d verses, the limit is %d", ErrPassageTooLong, refs.String(), searchResult.Total, MaxPassageVerses)
+	}
+
+	verses := make([]*Verse, 0, len(searchResult.Hits))
+	positions := make(map[*Verse]int)
+	for _, hit := range searchResult.Hits {
+		v := NewVerseFromFields(hit.Fields)
+		ref := v.Ref()
+		// Stored book names may be aliases of the canonical name
+		if book, err := LookupBook(v.Book); err == nil {
+			ref.Book = book.Name
+		}
+		positions[v] = refs.IndexOf(ref)
+		verses = append(verses, v)
+	}
+
+	sort.SliceStable(verses, func(i, j int) bool {
+		a, b := verses[i], verses[j]
+		if positions[a] != positions[b] {
+			return positions[a] < positions[b]
+		}
+		if a.Ref() != b.Ref() {
+			return a.Ref().Before(b.Ref())
+		}
+		return a.Version < b.Version
+	})
+	return verses, nil
+}
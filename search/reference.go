@@ -0,0 +1,285 @@
+package biblescholar
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// A reference to a span of scripture
+// Bounds are inclusive; a Verse of 0 in the end bound means "through the end of the chapter"
+type Passage interface {
+	Bounds() (start VerseRef, end VerseRef)
+	Contains(v VerseRef) bool
+	String() string
+}
+
+// A single verse, e.g. "John 3:16"
+type VerseRef struct {
+	Book    string
+	Chapter int
+	Verse   int
+}
+
+// A contiguous set of verses, possibly spanning chapters, e.g. "Romans 8:28-9:5"
+type VerseRange struct {
+	Start VerseRef
+	End   VerseRef
+}
+
+// One or more whole chapters, e.g. "1 Corinthians 13" or "Psalm 1-2"
+type ChapterRange struct {
+	Book  string
+	Start int
+	End   int
+}
+
+// An ordered list of passages, e.g. "Psalm 23; Isaiah 53:1-6"
+type ReferenceList []Passage
+
+// Returned when a reference string cannot be understood
+type ReferenceError struct {
+	Input  string
+	Reason string
+}
+
+func (e *ReferenceError) Error() string {
+	return fmt.Sprintf("Invalid reference '%s': %s", e.Input, e.Reason)
+}
+
+func (v VerseRef) Bounds() (VerseRef, VerseRef) {
+	return v, v
+}
+
+func (v VerseRef) Contains(o VerseRef) bool {
+	return v == o
+}
+
+func (v VerseRef) String() string {
+	return fmt.Sprintf("%s %d:%d", v.Book, v.Chapter, v.Verse)
+}
+
+// Orders verses within the same book
+func (v VerseRef) Before(o VerseRef) bool {
+	if v.Chapter != o.Chapter {
+		return v.Chapter < o.Chapter
+	}
+	return v.Verse < o.Verse
+}
+
+func (r VerseRange) Bounds() (VerseRef, VerseRef) {
+	return r.Start, r.End
+}
+
+func (r VerseRange) Contains(v VerseRef) bool {
+	return v.Book == r.Start.Book && !v.Before(r.Start) && !r.End.Before(v)
+}
+
+func (r VerseRange) String() string {
+	if r.Start == r.End {
+		return r.Start.String()
+	}
+	if r.Start.Chapter == r.End.Chapter {
+		return fmt.Sprintf("%s-%d", r.Start.String(), r.End.Verse)
+	}
+	return fmt.Sprintf("%s-%d:%d", r.Start.String(), r.End.Chapter, r.End.Verse)
+}
+
+func (r ChapterRange) Bounds() (VerseRef, VerseRef) {
+	return VerseRef{r.Book, r.Start, 1}, VerseRef{r.Book, r.End, 0}
+}
+
+func (r ChapterRange) Contains(v VerseRef) bool {
+	return v.Book == r.Book && v.Chapter >= r.Start && v.Chapter <= r.End
+}
+
+func (r ChapterRange) String() string {
+	if r.Start == r.End {
+		return fmt.Sprintf("%s %d", r.Book, r.Start)
+	}
+	return fmt.Sprintf("%s %d-%d", r.Book, r.Start, r.End)
+}
+
+// Index of the first passage containing this verse, or -1
+func (l ReferenceList) IndexOf(v VerseRef) int {
+	for i, p := range l {
+		if p.Contains(v) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (l ReferenceList) Contains(v VerseRef) bool {
+	return l.IndexOf(v) != -1
+}
+
+// Canonical rendering, e.g. "Psalm 23; Isaiah 53:1-6"
+func (l ReferenceList) String() string {
+	parts := make([]string, len(l))
+	for i, p := range l {
+		parts[i] = p.String()
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Render a list of passages in canonical form
+func FormatReference(l ReferenceList) string {
+	return l.String()
+}
+
+var (
+	// Optional book name followed by the chapter and verse portion
+	// The leading number of books like "1 John" or "1. Mose" is only treated as part of the name when letters follow it
+	referenceGroupPattern = regexp.MustCompile(`^\s*((?:[1-3](?:st|nd|rd|\.)?\s*)?[\pL][\pL\s.']*?)?\s*(\d.*)?$`)
+	// A single item in a comma separated list: "3", "3:16", "3:16-18", "8:28-9:5", "1-2"
+	referenceItemPattern = regexp.MustCompile(`^(\d+)(?:[:.](\d+))?(?:\s*-\s*(\d+)(?:[:.](\d+))?)?$`)
+	// Hyphens, en dashes, em dashes, and minus signs all mark ranges
+	dashReplacer = strings.NewReplacer("‐", "-", "‑", "-", "‒", "-", "–", "-", "—", "-", "−", "-")
+)
+
+// Parse a human readable reference such as "Jn 3:16-18", "1 Cor 13", "Romans 8:28–9:5" or "Ps 23; Isa 53:1-6"
+// Groups are separated by semicolons and may omit the book to continue with the previous one.
+// Within a group, commas continue the previous chapter when it was given with a verse, so "John 3:16, 18" is two verses.
+func ParseReference(s string) (ReferenceList, error) {
+	var refs ReferenceList
+	var book *Book
+
+	normalized := dashReplacer.Replace(s)
+	for _, group := range strings.Split(normalized, ";") {
+		if strings.TrimSpace(group) == "" {
+			continue
+		}
+
+		m := referenceGroupPattern.FindStringSubmatch(group)
+		if m == nil || m[2] == "" {
+			return nil, &ReferenceError{s, fmt.Sprintf("expected a chapter or verse in '%s'", strings.TrimSpace(group))}
+		}
+		if name := strings.TrimSpace(m[1]); name != "" {
+			b, err := LookupBook(name)
+			if err != nil {
+				return nil, &ReferenceError{s, err.Error()}
+			}
+			book = b
+		}
+		if book == nil {
+			return nil, &ReferenceError{s, "missing book name"}
+		}
+
+		passages, err := parseReferenceItems(book, m[2])
+		if err != nil {
+			return nil, &ReferenceError{s, err.Error()}
+		}
+		refs = append(refs, passages...)
+	}
+
+	if len(refs) == 0 {
+		return nil, &ReferenceError{s, "empty reference"}
+	}
+	return refs, nil
+}
+
+// Parse the comma separated chapter/verse portion of a group for a single book
+func parseReferenceItems(book *Book, spec string) ([]Passage, error) {
+	var passages []Passage
+
+	// Chapter to use for bare numbers after an item with a verse, e.g. the "18" in "3:16, 18"
+	verseContext := 0
+	if book.IsSingleChapter() {
+		verseContext = 1
+	}
+
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		m := referenceItemPattern.FindStringSubmatch(item)
+		if m == nil {
+			return nil, fmt.Errorf("cannot parse '%s'", item)
+		}
+		// Regex guarantees these are numeric when present
+		nums := make([]int, 4)
+		for i, g := range m[1:] {
+			if g != "" {
+				nums[i], _ = strconv.Atoi(g)
+			}
+		}
+		hasVerse, hasRange, hasEndVerse := m[2] != "", m[3] != "", m[4] != ""
+
+		var p Passage
+		switch {
+		case hasVerse && hasEndVerse:
+			// 8:28-9:5
+			p = VerseRange{VerseRef{book.Name, nums[0], nums[1]}, VerseRef{book.Name, nums[2], nums[3]}}
+			verseContext = nums[2]
+		case hasVerse && hasRange:
+			// 3:16-18
+			p = VerseRange{VerseRef{book.Name, nums[0], nums[1]}, VerseRef{book.Name, nums[0], nums[2]}}
+			verseContext = nums[0]
+		case hasVerse:
+			// 3:16
+			p = VerseRef{book.Name, nums[0], nums[1]}
+			verseContext = nums[0]
+		case hasEndVerse:
+			// 8-9:5, from the start of chapter 8
+			p = VerseRange{VerseRef{book.Name, nums[0], 1}, VerseRef{book.Name, nums[2], nums[3]}}
+			verseContext = nums[2]
+		case verseContext != 0 && hasRange:
+			// "Jude 3-5" or the "18-20" in "3:16, 18-20"
+			p = VerseRange{VerseRef{book.Name, verseContext, nums[0]}, VerseRef{book.Name, verseContext, nums[2]}}
+		case verseContext != 0:
+			p = VerseRef{book.Name, verseContext, nums[0]}
+		case hasRange:
+			p = ChapterRange{book.Name, nums[0], nums[2]}
+		default:
+			p = ChapterRange{book.Name, nums[0], nums[0]}
+		}
+
+		if err := validatePassage(book, p); err != nil {
+			return nil, err
+		}
+		passages = append(passages, p)
+	}
+	return passages, nil
+}
+
+// Build a passage from its parts, as when the book, chapter and verses are given separately
+// A startVerse of 0 means the whole chapter, and an endVerse of 0 means a single verse
+func NewPassage(bookName string, chapter int, startVerse int, endVerse int) (Passage, error) {
+	book, err := LookupBook(bookName)
+	if err != nil {
+		return nil, err
+	}
+	if chapter == 0 && book.IsSingleChapter() {
+		chapter = 1
+	}
+
+	var p Passage
+	switch {
+	case startVerse == 0:
+		p = ChapterRange{book.Name, chapter, chapter}
+	case endVerse == 0 || endVerse == startVerse:
+		p = VerseRef{book.Name, chapter, startVerse}
+	default:
+		p = VerseRange{VerseRef{book.Name, chapter, startVerse}, VerseRef{book.Name, chapter, endVerse}}
+	}
+	if err := validatePassage(book, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func validatePassage(book *Book, p Passage) error {
+	start, end := p.Bounds()
+	for _, v := range []VerseRef{start, end} {
+		if v.Chapter < 1 || v.Chapter > book.Chapters {
+			return fmt.Errorf("%s has %d chapters, got chapter %d", book.Name, book.Chapters, v.Chapter)
+		}
+	}
+	if _, ok := p.(ChapterRange); !ok && (start.Verse < 1 || end.Verse < 1) {
+		return fmt.Errorf("verse numbers start at 1")
+	}
+	if end.Before(start) && !(end.Chapter == start.Chapter && end.Verse == 0) {
+		return fmt.Errorf("range '%s' ends before it starts", p.String())
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package biblescholar
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLookupBook(t *testing.T) {
+	cases := []struct {
+		name     string
+		expected string
+	}{
+		{"John", "John"},
+		{"jn", "John"},
+		{"Jhn.", "John"},
+		{"Psalms", "Psalm"},
+		{"Song of Solomon", "Song of Songs"},
+		{"1 Cor", "1 Corinthians"},
+		{"1cor", "1 Corinthians"},
+		{"First Corinthians", "1 Corinthians"},
+		{"1st John", "1 John"},
+		{"II Kings", "2 Kings"},
+		{"Deuter", "Deuteronomy"},
+		{"Phil", "Philippians"},
+		{"Philem", "Philemon"},
+		// German names
+		{"Johannes", "John"},
+		{"Matthäus", "Matthew"},
+		{"Psalmen", "Psalm"},
+		{"1. Korinther", "1 Corinthians"},
+		{"erster Johannes", "1 John"},
+		{"5. Mose", "Deuteronomy"},
+		// Errors
+		{"", ""},
+		{"Hezekiah", ""},
+		// Prefix of both Philippians and Philemon
+		{"Phi", ""},
+	}
+	for _, c := range cases {
+		book, err := LookupBook(c.name)
+		switch {
+		case c.expected == "" && err == nil:
+			t.Errorf("LookupBook(%q): expected an error, got %s", c.name, book.Name)
+		case c.expected != "" && err != nil:
+			t.Errorf("LookupBook(%q): unexpected error: %v", c.name, err)
+		case c.expected != "" && book.Name != c.expected:
+			t.Errorf("LookupBook(%q): expected %s, got %s", c.name, c.expected, book.Name)
+		}
+	}
+}
+
+func TestParseReference(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected string
+	}{
+		{"John 3:16", "John 3:16"},
+		{"Jn 3:16-18", "John 3:16-18"},
+		{"John 3:16, 18", "John 3:16; John 3:18"},
+		{"John 3:16, 18-20", "John 3:16; John 3:18-20"},
+		{"1 Cor 13", "1 Corinthians 13"},
+		{"Psalm 1-2", "Psalm 1-2"},
+		{"Romans 8:28–9:5", "Romans 8:28-9:5"},
+		{"Romans 8-9:5", "Romans 8:1-9:5"},
+		{"Ps 23; Isa 53:1-6", "Psalm 23; Isaiah 53:1-6"},
+		{"John 3:16; 4:1", "John 3:16; John 4:1"},
+		{"Jude 3-5", "Jude 1:3-5"},
+		{"Jude 3", "Jude 1:3"},
+		{"John 3.16", "John 3:16"},
+		{"1. Korinther 13", "1 Corinthians 13"},
+		{"Johannes 3:16", "John 3:16"},
+	}
+	for _, c := range cases {
+		refs, err := ParseReference(c.input)
+		if err != nil {
+			t.Errorf("ParseReference(%q): unexpected error: %v", c.input, err)
+			continue
+		}
+		if refs.String() != c.expected {
+			t.Errorf("ParseReference(%q): expected %s, got %s", c.input, c.expected, refs.String())
+		}
+	}
+}
+
+func TestParseReferenceErrors(t *testing.T) {
+	cases := []struct {
+		input  string
+		reason string
+	}{
+		{"", "empty reference"},
+		{"John", "expected a chapter or verse in 'John'"},
+		{"3:16", "missing book name"},
+		{"Hezekiah 3:16", "Unknown book name: 'Hezekiah'"},
+		{"John 22", "John has 21 chapters, got chapter 22"},
+		{"John 3:0", "verse numbers start at 1"},
+		{"John 3:18-16", "range 'John 3:18-16' ends before it starts"},
+		{"John 3:16, x", "cannot parse 'x'"},
+	}
+	for _, c := range cases {
+		_, err := ParseReference(c.input)
+		refErr, ok := err.(*ReferenceError)
+		if !ok {
+			t.Errorf("ParseReference(%q): expected a *ReferenceError, got %v", c.input, err)
+			continue
+		}
+		if refErr.Reason != c.reason {
+			t.Errorf("ParseReference(%q): expected the reason %q, got %q", c.input, c.reason, refErr.Reason)
+		}
+		if !strings.Contains(refErr.Error(), c.input) {
+			t.Errorf("ParseReference(%q): expected the error to quote the input, got %q", c.input, refErr.Error())
+		}
+	}
+}
+
+func TestParseReferenceContains(t *testing.T) {
+	refs, err := ParseReference("Psalm 23; Romans 8:28-9:5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		ref      VerseRef
+		expected int
+	}{
+		{VerseRef{"Psalm", 23, 6}, 0},
+		{VerseRef{"Psalm", 24, 1}, -1},
+		{VerseRef{"Romans", 8, 28}, 1},
+		{VerseRef{"Romans", 9, 5}, 1},
+		{VerseRef{"Romans", 8, 27}, -1},
+		{VerseRef{"Romans", 9, 6}, -1},
+	}
+	for _, c := range cases {
+		if i := refs.IndexOf(c.ref); i != c.expected {
+			t.Errorf("IndexOf(%s): expected %d, got %d", c.ref, c.expected, i)
+		}
+	}
+}
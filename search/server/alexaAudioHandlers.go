@@ -0,0 +1,143 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	biblescholar "github.com/turtlemonvh/biblescholar/search"
+	"github.com/turtlemonvh/biblescholar/search/conversation"
+)
+
+// Playing recorded chapters with the AudioPlayer interface
+// https://developer.amazon.com/docs/custom-skills/audioplayer-interface-reference.html
+
+const (
+	// Directives
+	playDirective string = "AudioPlayer.Play"
+	stopDirective string = "AudioPlayer.Stop"
+	// How a Play directive affects the queue
+	replaceAllBehavior string = "REPLACE_ALL"
+	enqueueBehavior    string = "ENQUEUE"
+)
+
+// Responses to AudioPlayer and PlaybackController requests may only contain directives
+type alexaDirectiveResponse struct {
+	Version  string `json:"version"`
+	Response struct {
+		Directives []AlexaDirective `json:"directives,omitempty"`
+	} `json:"response"`
+}
+
+// Identifies the recording being played, e.g. "ESV|John|3"
+// Sent back by the device with every AudioPlayer request
+func audioToken(rec *biblescholar.AudioRecording) string {
+	return fmt.Sprintf("%s|%s|%d", rec.Version, rec.Book, rec.Chapter)
+}
+
+// Recording for a token from audioToken, or nil if it is not in the manifest
+func (s *ServerConfig) recordingForToken(token string) *biblescholar.AudioRecording {
+	if s.Audio == nil {
+		return nil
+	}
+	parts := strings.SplitN(token, "|", 3)
+	if len(parts) != 3 {
+		return nil
+	}
+	chapter, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return nil
+	}
+	return s.Audio.Lookup(parts[0], parts[1], chapter)
+}
+
+func (s *ServerConfig) newPlayDirective(c *gin.Context, rec *biblescholar.AudioRecording, offset int64, behavior string, previousToken string) AlexaDirective {
+	return AlexaDirective{
+		Type:         playDirective,
+		PlayBehavior: behavior,
+		AudioItem: &AlexaAudioItem{
+			Stream: AlexaAudioStream{
+				URL:                   s.audioURL(c, rec),
+				Token:                 audioToken(rec),
+				ExpectedPreviousToken: previousToken,
+				OffsetInMilliseconds:  offset,
+			},
+		},
+	}
+}
+
+// Play or Stop directive for the engine's audio action
+func (s *ServerConfig) newAudioDirective(c *gin.Context, action *conversation.AudioAction) AlexaDirective {
+	if action.Recording == nil {
+		return AlexaDirective{Type: stopDirective}
+	}
+	return s.newPlayDirective(c, action.Recording, action.OffsetInMilliseconds, replaceAllBehavior, "")
+}
+
+// Events from the player while a recording plays, and presses of the device's playback buttons
+// The next chapter is queued as each one nearly finishes, so playback continues until a chapter was not recorded
+func (s *ServerConfig) handleAudioRequest(c *gin.Context, req *AlexaRequest) {
+	out := &alexaDirectiveResponse{Version: s.VersionString()}
+	token, offset := req.AudioPlayerState()
+	rec := s.recordingForToken(token)
+
+	logger := log.WithFields(log.Fields{
+		"type":   req.Request.Type,
+		"token":  token,
+		"offset": offset,
+	})
+
+	var next *biblescholar.AudioRecording
+	switch req.Request.Type {
+	case playbackNearlyFinishedRequest:
+		if rec != nil {
+			if next = s.Audio.Next(rec); next != nil {
+				out.Response.Directives = append(out.Response.Directives, s.newPlayDirective(c, next, 0, enqueueBehavior, token))
+			}
+		}
+	case playbackFailedRequest:
+		logger.WithFields(log.Fields{
+			"err": req.Request.Error,
+		}).Error("Recording failed to play.")
+	case playCommandRequest:
+		if rec != nil {
+			out.Response.Directives = append(out.Response.Directives, s.newPlayDirective(c, rec, offset, replaceAllBehavior, ""))
+		}
+	case pauseCommandRequest:
+		out.Response.Directives = append(out.Response.Directives, AlexaDirective{Type: stopDirective})
+	case nextCommandRequest, previousCommandRequest:
+		if rec != nil {
+			next = s.Audio.Previous(rec)
+			if req.Request.Type == nextCommandRequest {
+				next = s.Audio.Next(rec)
+			}
+		}
+		if next != nil {
+			out.Response.Directives = append(out.Response.Directives, s.newPlayDirective(c, next, 0, replaceAllBehavior, ""))
+		}
+	}
+
+	if next != nil {
+		logger = logger.WithField("next", audioToken(next))
+	}
+	logger.Info("Handled audio player request.")
+	c.JSON(http.StatusOK, out)
+}
+
+// A directive we sent failed, e.g. an AudioPlayer.Play with a url the device could not reach
+// Alexa ignores any response to this request, so it is only logged
+func (s *ServerConfig) handleExceptionEncounteredRequest(c *gin.Context, req *AlexaRequest) {
+	fields := log.Fields{}
+	if req.Request.Error != nil {
+		fields["errType"] = req.Request.Error.Type
+		fields["err"] = req.Request.Error.Message
+	}
+	if req.Request.Cause != nil {
+		fields["cause"] = req.Request.Cause.RequestID
+	}
+	log.WithFields(fields).Error("Alexa could not carry out a directive.")
+	c.JSON(http.StatusOK, &alexaDirectiveResponse{Version: s.VersionString()})
+}
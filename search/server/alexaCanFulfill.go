@@ -0,0 +1,35 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// Answering whether the skill could handle an utterance, so alexa can route requests to it without the invocation name
+// https://developer.amazon.com/docs/custom-skills/implement-canfulfillintentrequest-for-name-free-interaction.html
+// The engine decides; the answer only needs converting
+
+func (s *ServerConfig) handleCanFulfillIntentRequest(c *gin.Context, req *AlexaRequest) {
+	answer := s.engine.CanFulfill(s.newConversationRequest(c, req))
+
+	out := &AlexaCanFulfillResponse{Version: s.VersionString()}
+	out.Response.CanFulfillIntent = AlexaCanFulfillIntent{CanFulfill: answer.CanFulfill}
+	if answer.Slots != nil {
+		out.Response.CanFulfillIntent.Slots = make(map[string]AlexaCanFulfillSlot)
+		for name, slot := range answer.Slots {
+			out.Response.CanFulfillIntent.Slots[name] = AlexaCanFulfillSlot{
+				CanUnderstand: slot.CanUnderstand,
+				CanFulfill:    slot.CanFulfill,
+			}
+		}
+	}
+
+	log.WithFields(log.Fields{
+		"intent":     req.IntentName(),
+		"canFulfill": out.Response.CanFulfillIntent.CanFulfill,
+		"slots":      out.Response.CanFulfillIntent.Slots,
+	}).Info("Answered CanFulfillIntentRequest.")
+	c.JSON(http.StatusOK, out)
+}
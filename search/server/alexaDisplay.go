@@ -0,0 +1,184 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"strings"
+	"unicode"
+
+	"github.com/turtlemonvh/biblescholar/search/conversation"
+)
+
+// Visual responses for devices with screens, using the Alexa Presentation Language
+// https://developer.amazon.com/docs/alexa-presentation-language/apl-render-document-skill-directive.html
+// Cards are still sent, so headless devices and the Alexa app show the same content as before
+
+const (
+	aplInterface            string = "Alexa.Presentation.APL"
+	renderDocumentDirective string = "Alexa.Presentation.APL.RenderDocument"
+)
+
+// A list of search hits, with the reference above the text of each
+// The hit being spoken is shown in a highlight color
+const searchResultsDocument string = `{
+  "type": "APL",
+  "version": "1.4",
+  "mainTemplate": {
+    "parameters": ["payload"],
+    "items": [{
+      "type": "Container",
+      "width": "100vw",
+      "height": "100vh",
+      "paddingLeft": "40dp",
+      "paddingRight": "40dp",
+      "paddingTop": "24dp",
+      "items": [
+        {"type": "Text", "text": "${payload.results.title}", "fontSize": "32dp", "fontWeight": "bold"},
+        {"type": "Text", "text": "${payload.results.subtitle}", "fontSize": "20dp", "color": "#B0B0B0", "paddingBottom": "12dp"},
+        {
+          "type": "Sequence",
+          "grow": 1,
+          "data": "${payload.results.items}",
+          "items": [{
+            "type": "Container",
+            "paddingTop": "12dp",
+            "paddingBottom": "12dp",
+            "items": [
+              {"type": "Text", "text": "${data.reference}", "fontSize": "24dp", "fontWeight": "bold", "color": "${data.current ? '#FFC940' : '#FAFAFA'}"},
+              {"type": "Text", "text": "${data.text}", "fontSize": "22dp"}
+            ]
+          }]
+        }
+      ]
+    }]
+  }
+}`
+
+// A passage as a page of text, with verse numbers inline
+const passageDocument string = `{
+  "type": "APL",
+  "version": "1.4",
+  "mainTemplate": {
+    "parameters": ["payload"],
+    "items": [{
+      "type": "Container",
+      "width": "100vw",
+      "height": "100vh",
+      "paddingLeft": "40dp",
+      "paddingRight": "40dp",
+      "paddingTop": "24dp",
+      "items": [
+        {"type": "Text", "text": "${payload.passage.title}", "fontSize": "32dp", "fontWeight": "bold"},
+        {"type": "Text", "text": "${payload.passage.subtitle}", "fontSize": "20dp", "color": "#B0B0B0", "paddingBottom": "12dp"},
+        {
+          "type": "ScrollView",
+          "grow": 1,
+          "item": {"type": "Text", "text": "${payload.passage.text}", "fontSize": "26dp", "lineHeight": 1.4}
+        }
+      ]
+    }]
+  }
+}`
+
+type aplSearchResults struct {
+	Title    string          `json:"title"`
+	Subtitle string          `json:"subtitle"`
+	Items    []aplSearchItem `json:"items"`
+}
+
+type aplSearchItem struct {
+	Reference string `json:"reference"`
+	// APL text markup, with the words of the search in bold
+	Text    string `json:"text"`
+	Current bool   `json:"current"`
+}
+
+type aplPassage struct {
+	Title    string `json:"title"`
+	Subtitle string `json:"subtitle"`
+	// APL text markup, with bold verse numbers
+	Text string `json:"text"`
+}
+
+func newRenderDocumentDirective(token string, document string, datasources interface{}) AlexaDirective {
+	return AlexaDirective{
+		Type:        renderDocumentDirective,
+		Token:       token,
+		Document:    json.RawMessage(document),
+		Datasources: datasources,
+	}
+}
+
+// APL document showing the engine's display
+// Display types are also used as document tokens
+func newDisplayDirective(d *conversation.Display) AlexaDirective {
+	if d.Type == conversation.PassageDisplay {
+		return newRenderDocumentDirective(d.Type, passageDocument, map[string]interface{}{
+			"passage": newPassageDocumentData(d),
+		})
+	}
+	return newRenderDocumentDirective(d.Type, searchResultsDocument, map[string]interface{}{
+		"results": newSearchResultsData(d),
+	})
+}
+
+// Search hits with the one being spoken highlighted, or close matches numbered so the user can say "the second one"
+func newSearchResultsData(d *conversation.Display) aplSearchResults {
+	results := aplSearchResults{
+		Title:    d.Title,
+		Subtitle: d.Subtitle,
+	}
+	for i, v := range d.Verses {
+		ref := fmt.Sprintf("%s (%s)", v.Ref().String(), v.Version)
+		if d.Type == conversation.ChoicesDisplay {
+			ref = fmt.Sprintf("%d. %s", i+1, ref)
+		}
+		results.Items = append(results.Items, aplSearchItem{
+			Reference: ref,
+			Text:      highlightWords(v.Text, d.Query),
+			Current:   i == d.Current,
+		})
+	}
+	return results
+}
+
+// The verses being read
+func newPassageDocumentData(d *conversation.Display) aplPassage {
+	var parts []string
+	for _, v := range d.Verses {
+		parts = append(parts, fmt.Sprintf("<b>%d</b> %s", v.Verse, html.EscapeString(v.Text)))
+	}
+	return aplPassage{
+		Title:    d.Title,
+		Subtitle: d.Subtitle,
+		Text:     strings.Join(parts, " "),
+	}
+}
+
+// Escape text for APL markup, putting words that appear in the phrase in bold
+// Words are compared ignoring case and punctuation, so "Love," matches "love"
+func highlightWords(text string, phrase string) string {
+	words := make(map[string]bool)
+	for _, w := range strings.Fields(phrase) {
+		if key := wordKey(w); key != "" {
+			words[key] = true
+		}
+	}
+
+	fields := strings.Fields(text)
+	for i, f := range fields {
+		if words[wordKey(f)] {
+			fields[i] = "<b>" + html.EscapeString(f) + "</b>"
+		} else {
+			fields[i] = html.EscapeString(f)
+		}
+	}
+	return strings.Join(fields, " ")
+}
+
+func wordKey(word string) string {
+	return strings.ToLower(strings.TrimFunc(word, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}))
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"github.com/turtlemonvh/biblescholar/search/conversation"
+)
+
+// Mapping of alexa intents to the conversation engine's intents
+// https://developer.amazon.com/docs/custom-skills/standard-built-in-intents.html
+
+const (
+	// Custom intents and slots have the same names in the interaction model as in the engine
+	searchBibleIntent    string = conversation.SearchBibleIntent
+	readPassageIntent    string = conversation.ReadPassageIntent
+	playPassageIntent    string = conversation.PlayPassageIntent
+	setTranslationIntent string = conversation.SetTranslationIntent
+	getTranslationIntent string = conversation.GetTranslationIntent
+	selectChoiceIntent   string = conversation.SelectChoiceIntent
+	querySlot            string = conversation.QuerySlot
+	bookSlot             string = conversation.BookSlot
+	chapterSlot          string = conversation.ChapterSlot
+	startVerseSlot       string = conversation.StartVerseSlot
+	endVerseSlot         string = conversation.EndVerseSlot
+	translationSlot      string = conversation.TranslationSlot
+	ordinalSlot          string = conversation.OrdinalSlot
+	// Built in intents
+	nextIntent         string = "AMAZON.NextIntent"
+	previousIntent     string = "AMAZON.PreviousIntent"
+	repeatIntent       string = "AMAZON.RepeatIntent"
+	yesIntent          string = "AMAZON.YesIntent"
+	noIntent           string = "AMAZON.NoIntent"
+	pauseIntent        string = "AMAZON.PauseIntent"
+	resumeIntent       string = "AMAZON.ResumeIntent"
+	helpIntent         string = "AMAZON.HelpIntent"
+	fallbackIntent     string = "AMAZON.FallbackIntent"
+	navigateHomeIntent string = "AMAZON.NavigateHomeIntent"
+	stopIntent         string = "AMAZON.StopIntent"
+	cancelIntent       string = "AMAZON.CancelIntent"
+)
+
+// Engine intents by alexa intent name; add new intents here
+var alexaIntents = map[string]string{
+	searchBibleIntent:    conversation.SearchBibleIntent,
+	readPassageIntent:    conversation.ReadPassageIntent,
+	playPassageIntent:    conversation.PlayPassageIntent,
+	setTranslationIntent: conversation.SetTranslationIntent,
+	getTranslationIntent: conversation.GetTranslationIntent,
+	selectChoiceIntent:   conversation.SelectChoiceIntent,
+	nextIntent:           conversation.NextIntent,
+	previousIntent:       conversation.PreviousIntent,
+	repeatIntent:         conversation.RepeatIntent,
+	yesIntent:            conversation.YesIntent,
+	noIntent:             conversation.NoIntent,
+	pauseIntent:          conversation.PauseIntent,
+	resumeIntent:         conversation.ResumeIntent,
+	helpIntent:           conversation.HelpIntent,
+	fallbackIntent:       conversation.FallbackIntent,
+	navigateHomeIntent:   conversation.HomeIntent,
+	// https://developer.amazon.com/public/solutions/alexa/alexa-skills-kit/docs/alexa-skills-kit-voice-interface-and-user-experience-testing?ref_=pe_679090_102923190#stopping-and-canceling
+	stopIntent:   conversation.StopIntent,
+	cancelIntent: conversation.StopIntent,
+}
+
+// Engine intent for an alexa intent
+// Intents the engine does not know about are passed on as they are, and answered with help
+func engineIntent(name string) string {
+	if intent, ok := alexaIntents[name]; ok {
+		return intent
+	}
+	return name
+}
+
+// Alexa intent for an engine intent, e.g. for simulating requests from parsed utterances
+// Returns "" if the skill does not route the intent
+func AlexaIntentName(intent string) string {
+	if intent == conversation.StopIntent {
+		return stopIntent
+	}
+	for name, engine := range alexaIntents {
+		if engine == intent {
+			return name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,416 @@
+package server
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	biblescholar "github.com/turtlemonvh/biblescholar/search"
+)
+
+// Generation of the skill's interaction model, so book names and translations match what is indexed
+// https://developer.amazon.com/docs/smapi/interaction-model-schema.html
+
+const (
+	// Slot types
+	bookSlotType        string = "BOOK_NAME"
+	translationSlotType string = "TRANSLATION"
+	numberSlotType      string = "AMAZON.NUMBER"
+	ordinalSlotType     string = "AMAZON.ORDINAL"
+	searchQuerySlotType string = "AMAZON.SearchQuery"
+	// Written aliases shorter than this are abbreviations nobody says, like "Gn"
+	minSpokenAliasLength int = 4
+)
+
+type AlexaInteractionModel struct {
+	InteractionModel struct {
+		LanguageModel AlexaLanguageModel `json:"languageModel"`
+	} `json:"interactionModel"`
+}
+
+type AlexaLanguageModel struct {
+	InvocationName string             `json:"invocationName"`
+	Intents        []AlexaIntentModel `json:"intents"`
+	Types          []AlexaSlotType    `json:"types"`
+}
+
+type AlexaIntentModel struct {
+	Name    string           `json:"name"`
+	Slots   []AlexaSlotModel `json:"slots,omitempty"`
+	Samples []string         `json:"samples"`
+}
+
+type AlexaSlotModel struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+type AlexaSlotType struct {
+	Name   string               `json:"name"`
+	Values []AlexaSlotTypeValue `json:"values"`
+}
+
+type AlexaSlotTypeValue struct {
+	ID   string `json:"id,omitempty"`
+	Name struct {
+		Value    string   `json:"value"`
+		Synonyms []string `json:"synonyms,omitempty"`
+	} `json:"name"`
+}
+
+// Ways of naming a translation at the end of a request for a passage
+var translationSuffixes = []string{" from the {Translation} translation", " in the {Translation}", " in {Translation}"}
+
+// Ways of asking for a passage; each is also sampled with a translation
+var readPassageSamples = []string{
+	"read {Book} {Chapter} {StartVerse} to {EndVerse}",
+	"read {Book} chapter {Chapter} verse {StartVerse} to verse {EndVerse}",
+	"read {Book} chapter {Chapter} verses {StartVerse} through {EndVerse}",
+	"read {Book} {Chapter} {StartVerse}",
+	"read {Book} chapter {Chapter} verse {StartVerse}",
+	"read {Book} {Chapter}",
+	"read {Book} chapter {Chapter}",
+	"read {Book}",
+}
+
+// Ways of asking for a recording; playback starts at a verse but runs to the end of the chapter
+var playPassageSamples = []string{
+	"play {Book} {Chapter} {StartVerse}",
+	"play {Book} chapter {Chapter} verse {StartVerse}",
+	"play {Book} chapter {Chapter} from verse {StartVerse}",
+	"play {Book} {Chapter}",
+	"play {Book} chapter {Chapter}",
+	"play {Book}",
+	"listen to {Book} chapter {Chapter}",
+	"listen to {Book} {Chapter}",
+}
+
+// Slots and sample utterances of the intents that are not built in
+// Every custom intent in alexaIntents needs an entry here
+var alexaCustomIntents = map[string]AlexaIntentModel{
+	searchBibleIntent: {
+		Slots: []AlexaSlotModel{
+			{querySlot, searchQuerySlotType},
+		},
+		Samples: []string{
+			"search for {QueryPhrase}",
+			"search the bible for {QueryPhrase}",
+			"look up {QueryPhrase}",
+			"lookup {QueryPhrase}",
+			"find {QueryPhrase}",
+			"find verses about {QueryPhrase}",
+			"where does it say {QueryPhrase}",
+		},
+	},
+	readPassageIntent: {
+		Slots: []AlexaSlotModel{
+			{bookSlot, bookSlotType},
+			{chapterSlot, numberSlotType},
+			{startVerseSlot, numberSlotType},
+			{endVerseSlot, numberSlotType},
+			{translationSlot, translationSlotType},
+		},
+		Samples: withTranslationSamples(readPassageSamples, translationSuffixes),
+	},
+	playPassageIntent: {
+		Slots: []AlexaSlotModel{
+			{bookSlot, bookSlotType},
+			{chapterSlot, numberSlotType},
+			{startVerseSlot, numberSlotType},
+			{translationSlot, translationSlotType},
+		},
+		Samples: withTranslationSamples(playPassageSamples, translationSuffixes),
+	},
+	setTranslationIntent: {
+		Slots: []AlexaSlotModel{
+			{translationSlot, translationSlotType},
+		},
+		Samples: []string{
+			"use the {Translation}",
+			"use the {Translation} translation",
+			"use {Translation}",
+			"switch to the {Translation}",
+			"switch to {Translation}",
+			"read from the {Translation}",
+			"set my translation to {Translation}",
+			"change my translation",
+			"change translation",
+		},
+	},
+	selectChoiceIntent: {
+		Slots: []AlexaSlotModel{
+			{ordinalSlot, ordinalSlotType},
+			{bookSlot, bookSlotType},
+		},
+		Samples: []string{
+			"the {Ordinal} one",
+			"the {Ordinal}",
+			"{Ordinal}",
+			"number {Ordinal}",
+			"the {Book} one",
+			"the one from {Book}",
+			"{Book}",
+		},
+	},
+	getTranslationIntent: {
+		Samples: []string{
+			"which translation am I using",
+			"which translation is selected",
+			"what translation are you using",
+			"what version are you reading",
+			"what is my translation",
+		},
+	},
+}
+
+// Ways of naming a translation at the end of a request for a passage, in German
+var germanTranslationSuffixes = []string{" aus der {Translation}", " in der {Translation}", " in {Translation}"}
+
+// German sample utterances of the custom intents, with the same slots as alexaCustomIntents
+var germanIntentSamples = map[string][]string{
+	searchBibleIntent: {
+		"suche nach {QueryPhrase}",
+		"suche in der Bibel nach {QueryPhrase}",
+		"finde {QueryPhrase}",
+		"finde Verse über {QueryPhrase}",
+		"wo steht {QueryPhrase}",
+	},
+	readPassageIntent: withTranslationSamples([]string{
+		"lies {Book} {Chapter} {StartVerse} bis {EndVerse}",
+		"lies {Book} Kapitel {Chapter} Vers {StartVerse} bis Vers {EndVerse}",
+		"lies {Book} Kapitel {Chapter} Verse {StartVerse} bis {EndVerse}",
+		"lies {Book} {Chapter} {StartVerse}",
+		"lies {Book} Kapitel {Chapter} Vers {StartVerse}",
+		"lies {Book} {Chapter}",
+		"lies {Book} Kapitel {Chapter}",
+		"lies {Book}",
+	}, germanTranslationSuffixes),
+	playPassageIntent: withTranslationSamples([]string{
+		"spiele {Book} {Chapter} {StartVerse}",
+		"spiele {Book} Kapitel {Chapter} Vers {StartVerse}",
+		"spiele {Book} Kapitel {Chapter} ab Vers {StartVerse}",
+		"spiele {Book} {Chapter}",
+		"spiele {Book} Kapitel {Chapter}",
+		"spiele {Book}",
+		"ich möchte {Book} Kapitel {Chapter} hören",
+	}, germanTranslationSuffixes),
+	setTranslationIntent: {
+		"nimm die {Translation}",
+		"nimm {Translation}",
+		"wechsle zur {Translation}",
+		"wechsle zu {Translation}",
+		"lies aus der {Translation}",
+		"stelle meine Übersetzung auf {Translation}",
+		"ändere meine Übersetzung",
+		"Übersetzung ändern",
+	},
+	selectChoiceIntent: {
+		"den {Ordinal}",
+		"die {Ordinal}",
+		"{Ordinal}",
+		"Nummer {Ordinal}",
+		"den aus {Book}",
+		"die Stelle aus {Book}",
+		"{Book}",
+	},
+	getTranslationIntent: {
+		"welche Übersetzung verwende ich",
+		"welche Übersetzung ist ausgewählt",
+		"welche Übersetzung benutzt du",
+		"welche Version liest du",
+		"was ist meine Übersetzung",
+	},
+}
+
+// Sample utterances by language, for languages other than English
+var localeIntentSamples = map[string]map[string][]string{
+	"de": germanIntentSamples,
+}
+
+// Spoken forms of the numbers that prefix books like "1 Samuel", by language
+var spokenBookNumbers = map[string]map[string][]string{
+	"en": {
+		"1": {"First", "1st", "one"},
+		"2": {"Second", "2nd", "two"},
+		"3": {"Third", "3rd", "three"},
+	},
+	"de": {
+		"1": {"Erster", "Erste", "Ersten"},
+		"2": {"Zweiter", "Zweite", "Zweiten"},
+		"3": {"Dritter", "Dritte", "Dritten"},
+	},
+}
+
+// Each sample, then each sample ending with one of the ways of naming a translation
+func withTranslationSamples(samples []string, suffixes []string) []string {
+	all := append([]string{}, samples...)
+	for _, suffix := range suffixes {
+		for _, sample := range samples {
+			all = append(all, sample+suffix)
+		}
+	}
+	return all
+}
+
+// Build the model for a locale, e.g. "en-US" or "de-DE", for every routed intent, with slot values for the books and versions in the index
+func BuildInteractionModel(invocationName string, locale string, books []string, versions []string) (*AlexaInteractionModel, error) {
+	language := strings.ToLower(strings.SplitN(locale, "-", 2)[0])
+	if _, ok := spokenBookNumbers[language]; !ok {
+		return nil, fmt.Errorf("No interaction model for locale: '%s'", locale)
+	}
+
+	var names []string
+	for name := range alexaIntents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var intents []AlexaIntentModel
+	for _, name := range names {
+		if strings.HasPrefix(name, "AMAZON.") {
+			// Built in intents bring their own samples
+			intents = append(intents, AlexaIntentModel{Name: name, Samples: []string{}})
+			continue
+		}
+		intent, ok := alexaCustomIntents[name]
+		if !ok {
+			return nil, fmt.Errorf("No interaction model for intent: '%s'", name)
+		}
+		if samples, ok := localeIntentSamples[language]; ok {
+			if intent.Samples, ok = samples[name]; !ok {
+				return nil, fmt.Errorf("No '%s' samples for intent: '%s'", language, name)
+			}
+		}
+		intent.Name = name
+		intents = append(intents, intent)
+	}
+
+	model := &AlexaInteractionModel{}
+	model.InteractionModel.LanguageModel = AlexaLanguageModel{
+		InvocationName: strings.ToLower(invocationName),
+		Intents:        intents,
+		Types: []AlexaSlotType{
+			bookSlotValues(books, language),
+			translationSlotValues(versions),
+		},
+	}
+	return model, nil
+}
+
+// One value per book, in canonical order, with spoken forms of its name in the language as synonyms
+// Values stay canonical, so the resolved book is the same in every language
+func bookSlotValues(indexed []string, language string) AlexaSlotType {
+	slotType := AlexaSlotType{Name: bookSlotType}
+
+	found := make(map[*biblescholar.Book]bool)
+	var unknown []string
+	for _, name := range indexed {
+		book, err := biblescholar.LookupBook(name)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"book": name,
+			}).Warn("Indexed book is not in the books table")
+			unknown = append(unknown, name)
+			continue
+		}
+		found[book] = true
+	}
+
+	for _, book := range biblescholar.Books {
+		if !found[book] {
+			continue
+		}
+		var synonyms []string
+		if local := book.LocalNames(language); len(local) > 0 {
+			for _, name := range local {
+				synonyms = append(synonyms, spokenBookNames(name, language)...)
+			}
+		} else {
+			for _, name := range book.Names() {
+				if name != book.Name && !isSpokenAlias(name) {
+					continue
+				}
+				synonyms = append(synonyms, spokenBookNames(name, language)...)
+			}
+		}
+		slotType.Values = append(slotType.Values, slotTypeValue(book.Name, book.Name, synonyms))
+	}
+	for _, name := range unknown {
+		slotType.Values = append(slotType.Values, slotTypeValue(name, name, nil))
+	}
+	return slotType
+}
+
+// Aliases that are words, like "Psalms" or "Song of Solomon", rather than abbreviations, like "Ps", "Pslm" or "SOS"
+func isSpokenAlias(alias string) bool {
+	fields := strings.Fields(alias)
+	last := fields[len(fields)-1]
+	return len(last) >= minSpokenAliasLength && last != strings.ToUpper(last) && strings.ContainsAny(last, "aeiouy")
+}
+
+// Numbered books are said "First Corinthians", and sometimes heard as "one Corinthians"
+func spokenBookNames(name string, language string) []string {
+	fields := strings.SplitN(name, " ", 2)
+	if len(fields) == 2 {
+		if words, ok := spokenBookNumbers[language][fields[0]]; ok {
+			names := []string{name}
+			for _, word := range words {
+				names = append(names, word+" "+fields[1])
+			}
+			return names
+		}
+	}
+	return []string{name}
+}
+
+// One value per indexed version, with the full name and spelled out abbreviation as synonyms
+func translationSlotValues(versions []string) AlexaSlotType {
+	slotType := AlexaSlotType{Name: translationSlotType}
+	for _, version := range versions {
+		var synonyms []string
+		for _, t := range biblescholar.Translations {
+			if t.Abbreviation == version {
+				synonyms = append(synonyms, t.Name)
+				for _, alias := range t.Aliases {
+					if alias == strings.ToUpper(alias) {
+						alias = spelledOut(alias)
+					}
+					synonyms = append(synonyms, alias)
+				}
+			}
+		}
+		synonyms = append(synonyms, spelledOut(version))
+		slotType.Values = append(slotType.Values, slotTypeValue(version, version, synonyms))
+	}
+	return slotType
+}
+
+// How Alexa transcribes a spelled out abbreviation, e.g. "ESV" -> "e. s. v."
+func spelledOut(abbreviation string) string {
+	letters := make([]string, 0, len(abbreviation))
+	for _, r := range strings.ToLower(abbreviation) {
+		letters = append(letters, string(r)+".")
+	}
+	return strings.Join(letters, " ")
+}
+
+// Slot type value with synonyms that differ from the value and each other
+func slotTypeValue(id string, value string, synonyms []string) AlexaSlotTypeValue {
+	v := AlexaSlotTypeValue{ID: slotValueID(id)}
+	v.Name.Value = value
+	seen := map[string]bool{strings.ToLower(value): true}
+	for _, synonym := range synonyms {
+		if seen[strings.ToLower(synonym)] {
+			continue
+		}
+		seen[strings.ToLower(synonym)] = true
+		v.Name.Synonyms = append(v.Name.Synonyms, synonym)
+	}
+	return v
+}
+
+// Ids are conventionally upper snake case, e.g. "1_CORINTHIANS"
+func slotValueID(name string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(name), "_"))
+}
@@ -0,0 +1,381 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Typed model of the Alexa Skills Kit request and response envelopes
+// https://developer.amazon.com/docs/custom-skills/request-and-response-json-reference.html
+
+const (
+	// Request types
+	intentRequest       string = "IntentRequest"
+	launchRequest       string = "LaunchRequest"
+	sessionEndedRequest string = "SessionEndedRequest"
+	// Asks whether the skill could handle an utterance, for name-free interaction
+	canFulfillIntentRequest string = "CanFulfillIntentRequest"
+	// Sent outside of a session while audio plays, and by the device's playback buttons
+	// https://developer.amazon.com/docs/custom-skills/audioplayer-interface-reference.html#requests
+	playbackStartedRequest        string = "AudioPlayer.PlaybackStarted"
+	playbackFinishedRequest       string = "AudioPlayer.PlaybackFinished"
+	playbackStoppedRequest        string = "AudioPlayer.PlaybackStopped"
+	playbackNearlyFinishedRequest string = "AudioPlayer.PlaybackNearlyFinished"
+	playbackFailedRequest         string = "AudioPlayer.PlaybackFailed"
+	playCommandRequest            string = "PlaybackController.PlayCommandIssued"
+	pauseCommandRequest           string = "PlaybackController.PauseCommandIssued"
+	nextCommandRequest            string = "PlaybackController.NextCommandIssued"
+	previousCommandRequest        string = "PlaybackController.PreviousCommandIssued"
+	// Sent when a directive in a response could not be carried out; no response is allowed
+	// https://developer.amazon.com/docs/custom-skills/request-types-reference.html#systemexceptionencountered
+	exceptionEncounteredRequest string = "System.ExceptionEncountered"
+	// Output speech types
+	plainTextSpeech string = "PlainText"
+	ssmlSpeech      string = "SSML"
+	// Card types
+	simpleCard string = "Simple"
+	// Entity resolution status codes
+	resolutionMatch string = "ER_SUCCESS_MATCH"
+	// Interfaces a device may support
+	audioPlayerInterface string = "AudioPlayer"
+)
+
+// Request types we know how to decode
+var knownRequestTypes = map[string]bool{
+	intentRequest:           true,
+	launchRequest:           true,
+	sessionEndedRequest:     true,
+	canFulfillIntentRequest: true,
+
+	playbackStartedRequest:        true,
+	playbackFinishedRequest:       true,
+	playbackStoppedRequest:        true,
+	playbackNearlyFinishedRequest: true,
+	playbackFailedRequest:         true,
+	playCommandRequest:            true,
+	pauseCommandRequest:           true,
+	nextCommandRequest:            true,
+	previousCommandRequest:        true,
+
+	exceptionEncounteredRequest: true,
+}
+
+type AlexaRequest struct {
+	Version string           `json:"version"`
+	Session *AlexaSession    `json:"session,omitempty"`
+	Context *AlexaContext    `json:"context,omitempty"`
+	Request AlexaRequestBody `json:"request"`
+}
+
+// Not sent for requests outside of a session, e.g. AudioPlayer events
+type AlexaSession struct {
+	New         bool                   `json:"new"`
+	SessionID   string                 `json:"sessionId"`
+	Application AlexaApplication       `json:"application"`
+	Attributes  map[string]interface{} `json:"attributes,omitempty"`
+	User        AlexaUser              `json:"user"`
+}
+
+type AlexaApplication struct {
+	ApplicationID string `json:"applicationId"`
+}
+
+type AlexaUser struct {
+	UserID      string `json:"userId"`
+	AccessToken string `json:"accessToken,omitempty"`
+}
+
+type AlexaContext struct {
+	System      AlexaSystem       `json:"System"`
+	AudioPlayer *AlexaPlayerState `json:"AudioPlayer,omitempty"`
+}
+
+type AlexaSystem struct {
+	Application AlexaApplication `json:"application"`
+	User        AlexaUser        `json:"user"`
+	Device      AlexaDevice      `json:"device"`
+	APIEndpoint string           `json:"apiEndpoint,omitempty"`
+}
+
+type AlexaDevice struct {
+	DeviceID string `json:"deviceId,omitempty"`
+	// Keyed by interface name, e.g. "AudioPlayer"
+	SupportedInterfaces map[string]json.RawMessage `json:"supportedInterfaces,omitempty"`
+}
+
+type AlexaPlayerState struct {
+	Token                string `json:"token,omitempty"`
+	OffsetInMilliseconds int64  `json:"offsetInMilliseconds"`
+	PlayerActivity       string `json:"playerActivity,omitempty"`
+}
+
+// Fields common to all request types, plus those of each variant
+// Only the fields for the variant named by Type are set
+type AlexaRequestBody struct {
+	Type      string `json:"type"`
+	RequestID string `json:"requestId"`
+	Timestamp string `json:"timestamp"`
+	Locale    string `json:"locale,omitempty"`
+
+	// IntentRequest and CanFulfillIntentRequest
+	DialogState string       `json:"dialogState,omitempty"`
+	Intent      *AlexaIntent `json:"intent,omitempty"`
+
+	// SessionEndedRequest
+	Reason string `json:"reason,omitempty"`
+	// SessionEndedRequest, AudioPlayer.PlaybackFailed and System.ExceptionEncountered
+	Error *AlexaError `json:"error,omitempty"`
+	// System.ExceptionEncountered
+	Cause *AlexaErrorCause `json:"cause,omitempty"`
+
+	// AudioPlayer requests
+	Token                string `json:"token,omitempty"`
+	OffsetInMilliseconds int64  `json:"offsetInMilliseconds,omitempty"`
+}
+
+type AlexaIntent struct {
+	Name               string               `json:"name"`
+	ConfirmationStatus string               `json:"confirmationStatus,omitempty"`
+	Slots              map[string]AlexaSlot `json:"slots,omitempty"`
+}
+
+type AlexaSlot struct {
+	Name               string            `json:"name"`
+	Value              string            `json:"value,omitempty"`
+	ConfirmationStatus string            `json:"confirmationStatus,omitempty"`
+	Resolutions        *AlexaResolutions `json:"resolutions,omitempty"`
+}
+
+// Entity resolution of slot values against custom slot types
+// https://developer.amazon.com/docs/custom-skills/entity-resolution.html
+type AlexaResolutions struct {
+	ResolutionsPerAuthority []AlexaResolution `json:"resolutionsPerAuthority"`
+}
+
+type AlexaResolution struct {
+	Authority string `json:"authority"`
+	Status    struct {
+		Code string `json:"code"`
+	} `json:"status"`
+	Values []struct {
+		Value AlexaResolvedValue `json:"value"`
+	} `json:"values,omitempty"`
+}
+
+type AlexaResolvedValue struct {
+	Name string `json:"name"`
+	ID   string `json:"id,omitempty"`
+}
+
+type AlexaError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// The request whose response had the directive that failed
+type AlexaErrorCause struct {
+	RequestID string `json:"requestId"`
+}
+
+// Decode and check a request envelope
+func ParseAlexaRequest(r io.Reader) (*AlexaRequest, error) {
+	req := &AlexaRequest{}
+	if err := json.NewDecoder(r).Decode(req); err != nil {
+		return nil, fmt.Errorf("Invalid request body: %v", err)
+	}
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// Check that the fields required by the request type are present
+func (r *AlexaRequest) Validate() error {
+	if r.Request.Type == "" {
+		return fmt.Errorf("Missing required field: 'request.type'")
+	}
+	if !knownRequestTypes[r.Request.Type] {
+		return fmt.Errorf("Unknown request type: '%s'", r.Request.Type)
+	}
+	isIntent := r.Request.Type == intentRequest || r.Request.Type == canFulfillIntentRequest
+	if isIntent && (r.Request.Intent == nil || r.Request.Intent.Name == "") {
+		return fmt.Errorf("Missing required field: 'request.intent.name'")
+	}
+	return nil
+}
+
+// Application id from the session, or from the context for requests sent outside a session
+func (r *AlexaRequest) ApplicationID() string {
+	if r.Session != nil && r.Session.Application.ApplicationID != "" {
+		return r.Session.Application.ApplicationID
+	}
+	if r.Context != nil {
+		return r.Context.System.Application.ApplicationID
+	}
+	return ""
+}
+
+// User id from the session, or from the context for requests sent outside a session
+func (r *AlexaRequest) UserID() string {
+	if r.Session != nil && r.Session.User.UserID != "" {
+		return r.Session.User.UserID
+	}
+	if r.Context != nil {
+		return r.Context.System.User.UserID
+	}
+	return ""
+}
+
+// Whether the device can handle directives for an interface, e.g. "AudioPlayer"
+func (r *AlexaRequest) SupportsInterface(name string) bool {
+	if r.Context == nil {
+		return false
+	}
+	_, ok := r.Context.System.Device.SupportedInterfaces[name]
+	return ok
+}
+
+// Token and offset of the audio the device last played, from the request or the context
+// Empty when nothing has been played
+func (r *AlexaRequest) AudioPlayerState() (string, int64) {
+	if r.Request.Token != "" {
+		return r.Request.Token, r.Request.OffsetInMilliseconds
+	}
+	if r.Context != nil && r.Context.AudioPlayer != nil {
+		return r.Context.AudioPlayer.Token, r.Context.AudioPlayer.OffsetInMilliseconds
+	}
+	return "", 0
+}
+
+// Name of the intent, or "" for other request types
+func (r *AlexaRequest) IntentName() string {
+	if r.Request.Intent == nil {
+		return ""
+	}
+	return r.Request.Intent.Name
+}
+
+// Value of a slot after entity resolution, falling back to the spoken value
+func (s AlexaSlot) ResolvedValue() string {
+	if s.Resolutions != nil {
+		for _, res := range s.Resolutions.ResolutionsPerAuthority {
+			if res.Status.Code == resolutionMatch && len(res.Values) != 0 {
+				return res.Values[0].Value.Name
+			}
+		}
+	}
+	return s.Value
+}
+
+type AlexaResponse struct {
+	Version           string                 `json:"version"`
+	SessionAttributes map[string]interface{} `json:"sessionAttributes,omitempty"`
+	Response          AlexaResponseBody      `json:"response"`
+}
+
+type AlexaResponseBody struct {
+	OutputSpeech     *AlexaOutputSpeech `json:"outputSpeech,omitempty"`
+	Card             *AlexaCard         `json:"card,omitempty"`
+	Reprompt         *AlexaReprompt     `json:"reprompt,omitempty"`
+	Directives       []AlexaDirective   `json:"directives,omitempty"`
+	ShouldEndSession bool               `json:"shouldEndSession"`
+}
+
+type AlexaOutputSpeech struct {
+	Type string `json:"type"`
+	Text string `json:"text,omitempty"`
+	SSML string `json:"ssml,omitempty"`
+}
+
+type AlexaCard struct {
+	Type    string `json:"type"`
+	Title   string `json:"title,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+type AlexaReprompt struct {
+	OutputSpeech *AlexaOutputSpeech `json:"outputSpeech"`
+}
+
+// Only the fields for the directive named by Type are set
+type AlexaDirective struct {
+	Type string `json:"type"`
+
+	// AudioPlayer.Play
+	PlayBehavior string          `json:"playBehavior,omitempty"`
+	AudioItem    *AlexaAudioItem `json:"audioItem,omitempty"`
+
+	// AudioPlayer.ClearQueue
+	ClearBehavior string `json:"clearBehavior,omitempty"`
+
+	// Alexa.Presentation.APL.RenderDocument
+	Token       string          `json:"token,omitempty"`
+	Document    json.RawMessage `json:"document,omitempty"`
+	Datasources interface{}     `json:"datasources,omitempty"`
+}
+
+type AlexaAudioItem struct {
+	Stream AlexaAudioStream `json:"stream"`
+}
+
+// https://developer.amazon.com/docs/custom-skills/audioplayer-interface-reference.html#play
+type AlexaAudioStream struct {
+	// Must be https
+	URL   string `json:"url"`
+	Token string `json:"token"`
+	// Required when enqueuing, so the item is dropped if something else started playing
+	ExpectedPreviousToken string `json:"expectedPreviousToken,omitempty"`
+	OffsetInMilliseconds  int64  `json:"offsetInMilliseconds"`
+}
+
+func (r *AlexaResponse) SetOutputSpeech(speech *AlexaOutputSpeech) {
+	r.Response.OutputSpeech = speech
+}
+
+// Spoken if the user does not answer while the session is open
+func (r *AlexaResponse) SetReprompt(speech *AlexaOutputSpeech) {
+	r.Response.Reprompt = &AlexaReprompt{OutputSpeech: speech}
+}
+
+func (r *AlexaResponse) SetSimpleCard(title string, content string) {
+	r.Response.Card = &AlexaCard{
+		Type:    simpleCard,
+		Title:   title,
+		Content: content,
+	}
+}
+
+func (r *AlexaResponse) AddDirective(directive AlexaDirective) {
+	r.Response.Directives = append(r.Response.Directives, directive)
+}
+
+// Store a value to be sent back with the next request in this session
+func (r *AlexaResponse) SetAttribute(key string, value interface{}) {
+	if r.SessionAttributes == nil {
+		r.SessionAttributes = make(map[string]interface{})
+	}
+	r.SessionAttributes[key] = value
+}
+
+// Answer to a CanFulfillIntentRequest; no speech or session settings are allowed
+// https://developer.amazon.com/docs/custom-skills/request-types-reference.html#CanFulfillIntentRequest
+type AlexaCanFulfillResponse struct {
+	Version  string `json:"version"`
+	Response struct {
+		CanFulfillIntent AlexaCanFulfillIntent `json:"canFulfillIntent"`
+	} `json:"response"`
+}
+
+type AlexaCanFulfillIntent struct {
+	// "YES", "NO" or "MAYBE"
+	CanFulfill string                         `json:"canFulfill"`
+	Slots      map[string]AlexaCanFulfillSlot `json:"slots,omitempty"`
+}
+
+type AlexaCanFulfillSlot struct {
+	// "YES", "NO" or "MAYBE"
+	CanUnderstand string `json:"canUnderstand"`
+	// "YES" or "NO"
+	CanFulfill string `json:"canFulfill"`
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseAlexaRequest(t *testing.T) {
+	cases := []struct {
+		name        string
+		body        string
+		requestType string
+		err         string
+	}{
+		{"launch", `{"version":"1.0","request":{"type":"LaunchRequest","requestId":"r1"}}`, launchRequest, ""},
+		{"intent", `{"version":"1.0","request":{"type":"IntentRequest","intent":{"name":"SearchBible","slots":{"QueryPhrase":{"name":"QueryPhrase","value":"love"}}}}}`, intentRequest, ""},
+		{"session ended", `{"version":"1.0","request":{"type":"SessionEndedRequest","reason":"USER_INITIATED"}}`, sessionEndedRequest, ""},
+		{"can fulfill", `{"version":"1.0","request":{"type":"CanFulfillIntentRequest","intent":{"name":"ReadPassage"}}}`, canFulfillIntentRequest, ""},
+		{"audio player", `{"version":"1.0","request":{"type":"AudioPlayer.PlaybackStarted","token":"ESV|John|3"}}`, playbackStartedRequest, ""},
+		{"exception", `{"version":"1.0","request":{"type":"System.ExceptionEncountered","error":{"type":"INVALID_RESPONSE","message":"x"}}}`, exceptionEncounteredRequest, ""},
+		// Errors
+		{"not json", `not json`, "", "Invalid request body: "},
+		{"truncated", `{"version":"1.0","request":{"type":"LaunchRequest"`, "", "Invalid request body: "},
+		{"wrong type", `{"version":"1.0","request":{"type":3}}`, "", "Invalid request body: "},
+		{"empty body", ``, "", "Invalid request body: "},
+		{"no type", `{"version":"1.0","request":{}}`, "", "Missing required field: 'request.type'"},
+		{"unknown type", `{"version":"1.0","request":{"type":"Messaging.MessageReceived"}}`, "", "Unknown request type: 'Messaging.MessageReceived'"},
+		{"intent without intent", `{"version":"1.0","request":{"type":"IntentRequest"}}`, "", "Missing required field: 'request.intent.name'"},
+		{"can fulfill without name", `{"version":"1.0","request":{"type":"CanFulfillIntentRequest","intent":{}}}`, "", "Missing required field: 'request.intent.name'"},
+	}
+	for _, c := range cases {
+		req, err := ParseAlexaRequest(strings.NewReader(c.body))
+		if c.err != "" {
+			if err == nil || !strings.HasPrefix(err.Error(), c.err) {
+				t.Errorf("%s: expected an error starting %q, got %v", c.name, c.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", c.name, err)
+			continue
+		}
+		if req.Request.Type != c.requestType {
+			t.Errorf("%s: expected a %s, got %s", c.name, c.requestType, req.Request.Type)
+		}
+	}
+}
+
+func TestAlexaRequestIDs(t *testing.T) {
+	// Requests sent outside a session only name the application and user in the context
+	body := `{"version":"1.0","context":{"System":{"application":{"applicationId":"app"},"user":{"userId":"user"},"device":{"supportedInterfaces":{"AudioPlayer":{}}}},"AudioPlayer":{"token":"ESV|John|3","offsetInMilliseconds":1500}},"request":{"type":"PlaybackController.PlayCommandIssued"}}`
+	req, err := ParseAlexaRequest(strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.ApplicationID() != "app" || req.UserID() != "user" {
+		t.Errorf("Expected the ids from the context, got %q and %q", req.ApplicationID(), req.UserID())
+	}
+	if !req.SupportsInterface(audioPlayerInterface) {
+		t.Errorf("Expected the AudioPlayer interface to be supported")
+	}
+	if token, offset := req.AudioPlayerState(); token != "ESV|John|3" || offset != 1500 {
+		t.Errorf("Expected the player state from the context, got %q at %d", token, offset)
+	}
+}
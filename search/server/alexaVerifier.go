@@ -0,0 +1,293 @@
+package server
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Checks that requests were signed by the Alexa service
+// https://developer.amazon.com/docs/custom-skills/host-a-custom-skill-as-a-web-service.html#verify-request-sent-by-alexa
+
+const (
+	// Name the signing certificate must be issued for
+	alexaCertSAN string = "echo-api.amazon.com"
+	// Amazon requires rejecting requests more than 150 seconds old
+	DefaultTimestampTolerance time.Duration = 150 * time.Second
+	// How long to trust a downloaded certificate chain before fetching it again
+	DefaultCertCacheTTL time.Duration = 1 * time.Hour
+)
+
+// Downloads the PEM encoded certificate chain at a SignatureCertChainUrl
+type CertFetcher interface {
+	FetchCertChain(certURL string) ([]byte, error)
+}
+
+// Adapter to allow use of ordinary functions as CertFetchers
+type CertFetcherFunc func(certURL string) ([]byte, error)
+
+func (f CertFetcherFunc) FetchCertChain(certURL string) ([]byte, error) {
+	return f(certURL)
+}
+
+// Fetch certificate chains over https
+type HTTPCertFetcher struct {
+	Client *http.Client
+}
+
+func (f *HTTPCertFetcher) FetchCertChain(certURL string) ([]byte, error) {
+	client := f.Client
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Second}
+	}
+	resp, err := client.Get(certURL)
+	if err != nil {
+		return nil, errors.New("Could not download Amazon cert file.")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Could not download Amazon cert file: status %d", resp.StatusCode)
+	}
+	certContents, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.New("Could not read Amazon cert file.")
+	}
+	return certContents, nil
+}
+
+type AlexaVerifier struct {
+	Fetcher CertFetcher
+	// Trust roots for the certificate chain; nil means the system roots
+	Roots *x509.CertPool
+	// Skip the check on the certificate chain url; only for use with a local CA when testing
+	AllowAnyCertURL bool
+	CacheTTL        time.Duration
+	// Requests with a timestamp further than this from now are rejected
+	TimestampTolerance time.Duration
+	// Clock, overridable for testing
+	Now func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]*cachedCert
+}
+
+// A verified signing certificate
+type cachedCert struct {
+	cert    *x509.Certificate
+	expires time.Time
+}
+
+// A verifier that downloads certificates over https and checks them against the system roots
+func NewAlexaVerifier() *AlexaVerifier {
+	return &AlexaVerifier{
+		Fetcher:            &HTTPCertFetcher{},
+		CacheTTL:           DefaultCertCacheTTL,
+		TimestampTolerance: DefaultTimestampTolerance,
+	}
+}
+
+func (v *AlexaVerifier) now() time.Time {
+	if v.Now != nil {
+		return v.Now()
+	}
+	return time.Now()
+}
+
+// Check the signature headers against the request body
+// The body is read and replaced, so it can still be decoded afterwards
+func (v *AlexaVerifier) VerifyRequest(r *http.Request) error {
+	certURL := r.Header.Get("SignatureCertChainUrl")
+	if !v.AllowAnyCertURL && !verifyCertURL(certURL) {
+		return fmt.Errorf("Invalid cert URL: '%v'", certURL)
+	}
+
+	cert, err := v.signingCert(certURL)
+	if err != nil {
+		return err
+	}
+
+	// Prefer the SHA-256 signature when Alexa sends it
+	var hashFunc crypto.Hash
+	var h hash.Hash
+	var encodedSig string
+	if sig := r.Header.Get("Signature-256"); sig != "" {
+		hashFunc, h, encodedSig = crypto.SHA256, sha256.New(), sig
+	} else if sig := r.Header.Get("Signature"); sig != "" {
+		hashFunc, h, encodedSig = crypto.SHA1, sha1.New(), sig
+	} else {
+		return errors.New("Missing request signature.")
+	}
+
+	encryptedSig, err := base64.StdEncoding.DecodeString(encodedSig)
+	if err != nil {
+		return errors.New("Request signature is not valid base64.")
+	}
+
+	var bodyBuf bytes.Buffer
+	if _, err := io.Copy(h, io.TeeReader(r.Body, &bodyBuf)); err != nil {
+		return fmt.Errorf("Could not read request body: %v", err)
+	}
+	r.Body = ioutil.NopCloser(&bodyBuf)
+
+	publicKey, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return errors.New("Amazon certificate does not have an RSA key.")
+	}
+	if err := rsa.VerifyPKCS1v15(publicKey, hashFunc, h.Sum(nil), encryptedSig); err != nil {
+		return errors.New("Signature match failed.")
+	}
+	return nil
+}
+
+// Check that a request was sent recently, to prevent replays
+func (v *AlexaVerifier) VerifyTimestamp(timestamp string) error {
+	ts, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		return fmt.Errorf("Invalid request timestamp: '%s'", timestamp)
+	}
+	tolerance := v.TimestampTolerance
+	if tolerance == 0 {
+		tolerance = DefaultTimestampTolerance
+	}
+	diff := v.now().Sub(ts)
+	if diff > tolerance || diff < -tolerance {
+		return fmt.Errorf("Request timestamp %s is outside the allowed window of %s", timestamp, tolerance)
+	}
+	return nil
+}
+
+// Signing certificate for a chain url, from the cache or freshly downloaded and verified
+func (v *AlexaVerifier) signingCert(certURL string) (*x509.Certificate, error) {
+	now := v.now()
+
+	v.mu.Lock()
+	cached, ok := v.cache[certURL]
+	v.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.cert, nil
+	}
+
+	if v.Fetcher == nil {
+		return nil, errors.New("No certificate fetcher configured.")
+	}
+	certContents, err := v.Fetcher.FetchCertChain(certURL)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read certificate for url: %v", certURL)
+	}
+	cert, err := v.verifyCertChain(certContents, now)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid certificate for url: %v: %v", certURL, err)
+	}
+
+	ttl := v.CacheTTL
+	if ttl == 0 {
+		ttl = DefaultCertCacheTTL
+	}
+	expires := now.Add(ttl)
+	if cert.NotAfter.Before(expires) {
+		expires = cert.NotAfter
+	}
+
+	v.mu.Lock()
+	if v.cache == nil {
+		v.cache = make(map[string]*cachedCert)
+	}
+	v.cache[certURL] = &cachedCert{cert: cert, expires: expires}
+	v.mu.Unlock()
+
+	return cert, nil
+}
+
+// Parse a PEM chain of leaf followed by intermediates, and check it
+// Checks the validity window of every certificate, the chain up to a trusted root, and the leaf's subject alternative names
+func (v *AlexaVerifier) verifyCertChain(certContents []byte, now time.Time) (*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for rest := certContents; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse certificate: %v", err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("Failed to parse certificate PEM")
+	}
+
+	leaf := certs[0]
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	if _, err := leaf.Verify(x509.VerifyOptions{
+		DNSName:       alexaCertSAN,
+		Intermediates: intermediates,
+		Roots:         v.Roots,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}); err != nil {
+		return nil, err
+	}
+	return leaf, nil
+}
+
+// Load a PEM bundle of trusted roots
+func LoadCertPool(pemFile string) (*x509.CertPool, error) {
+	contents, err := ioutil.ReadFile(pemFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(contents) {
+		return nil, fmt.Errorf("No certificates found in: %s", pemFile)
+	}
+	return pool, nil
+}
+
+// The certificate chain must be hosted by amazon in a specific bucket
+func verifyCertURL(certURL string) bool {
+	link, err := url.Parse(certURL)
+	if err != nil {
+		return false
+	}
+
+	if strings.ToLower(link.Scheme) != "https" {
+		return false
+	}
+
+	host := strings.ToLower(link.Host)
+	if host != "s3.amazonaws.com" && host != "s3.amazonaws.com:443" {
+		return false
+	}
+
+	// Reject paths like "/echo.api/../other"
+	if !strings.HasPrefix(path.Clean(link.Path), "/echo.api/") {
+		return false
+	}
+
+	return true
+}
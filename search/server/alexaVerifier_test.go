@@ -0,0 +1,275 @@
+package server
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const testCertURL = "https://s3.amazonaws.com/echo.api/echo-api-cert-6.pem"
+
+// All certificates are valid around this time
+var testNow = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// A locally generated CA, standing in for the one that signs Amazon's certificates
+type testCA struct {
+	cert  *x509.Certificate
+	key   *rsa.PrivateKey
+	roots *x509.CertPool
+}
+
+var serialNumber int64
+
+func newTestCA(t *testing.T) *testCA {
+	key := newTestKey(t)
+	template := &x509.Certificate{
+		SerialNumber:          nextSerialNumber(),
+		Subject:               pkix.Name{CommonName: "Test Root CA"},
+		NotBefore:             testNow.Add(-24 * time.Hour),
+		NotAfter:              testNow.Add(365 * 24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	return &testCA{cert: cert, key: key, roots: roots}
+}
+
+// A PEM encoded leaf certificate for a name, valid between the times given, and its key
+func (ca *testCA) issue(t *testing.T, san string, notBefore time.Time, notAfter time.Time) ([]byte, *rsa.PrivateKey) {
+	key := newTestKey(t)
+	template := &x509.Certificate{
+		SerialNumber: nextSerialNumber(),
+		Subject:      pkix.Name{CommonName: san},
+		DNSNames:     []string{san},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), key
+}
+
+func newTestKey(t *testing.T) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func nextSerialNumber() *big.Int {
+	serialNumber++
+	return big.NewInt(serialNumber)
+}
+
+// A verifier that trusts the CA and hands out the chain, counting the downloads
+func newTestVerifier(ca *testCA, chain []byte, fetches *int) *AlexaVerifier {
+	return &AlexaVerifier{
+		Fetcher: CertFetcherFunc(func(certURL string) ([]byte, error) {
+			*fetches++
+			return chain, nil
+		}),
+		Roots: ca.roots,
+		Now:   func() time.Time { return testNow },
+	}
+}
+
+// A request with the body signed by the key, in the Signature-256 header or the SHA-1 Signature header
+func signedRequest(t *testing.T, key *rsa.PrivateKey, body string, signed string, sha256Header bool) *http.Request {
+	hashFunc, header := crypto.SHA1, "Signature"
+	var digest []byte
+	if sha256Header {
+		sum := sha256.Sum256([]byte(signed))
+		hashFunc, header, digest = crypto.SHA256, "Signature-256", sum[:]
+	} else {
+		sum := sha1.Sum([]byte(signed))
+		digest = sum[:]
+	}
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, hashFunc, digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest("POST", "/alexa/search", bytes.NewBufferString(body))
+	r.Header.Set("SignatureCertChainUrl", testCertURL)
+	r.Header.Set(header, base64.StdEncoding.EncodeToString(sig))
+	return r
+}
+
+func TestVerifyRequest(t *testing.T) {
+	ca := newTestCA(t)
+	valid, key := ca.issue(t, alexaCertSAN, testNow.Add(-time.Hour), testNow.Add(24*time.Hour))
+	expired, expiredKey := ca.issue(t, alexaCertSAN, testNow.Add(-48*time.Hour), testNow.Add(-24*time.Hour))
+	wrongSAN, wrongSANKey := ca.issue(t, "example.com", testNow.Add(-time.Hour), testNow.Add(24*time.Hour))
+	untrusted, untrustedKey := newTestCA(t).issue(t, alexaCertSAN, testNow.Add(-time.Hour), testNow.Add(24*time.Hour))
+
+	body := `{"version":"1.0","request":{"type":"LaunchRequest"}}`
+	cases := []struct {
+		name    string
+		chain   []byte
+		request *http.Request
+		ok      bool
+	}{
+		{"valid", valid, signedRequest(t, key, body, body, true), true},
+		{"valid sha1", valid, signedRequest(t, key, body, body, false), true},
+		{"bad signature", valid, signedRequest(t, key, body, body+" ", true), false},
+		{"signed by another key", valid, signedRequest(t, expiredKey, body, body, true), false},
+		{"expired cert", expired, signedRequest(t, expiredKey, body, body, true), false},
+		{"wrong SAN", wrongSAN, signedRequest(t, wrongSANKey, body, body, true), false},
+		{"untrusted CA", untrusted, signedRequest(t, untrustedKey, body, body, true), false},
+		{"not PEM", []byte("not a certificate"), signedRequest(t, key, body, body, true), false},
+	}
+	for _, c := range cases {
+		fetches := 0
+		err := newTestVerifier(ca, c.chain, &fetches).VerifyRequest(c.request)
+		if c.ok != (err == nil) {
+			t.Errorf("%s: expected ok to be %v, got %v", c.name, c.ok, err)
+			continue
+		}
+		if c.ok {
+			// The body can still be decoded after it was checked
+			if read, _ := ioutil.ReadAll(c.request.Body); string(read) != body {
+				t.Errorf("%s: expected the body to be kept, got %q", c.name, read)
+			}
+		}
+	}
+
+	// Missing or malformed signatures and bad urls are rejected; bad urls before anything is downloaded
+	missing := signedRequest(t, key, body, body, true)
+	missing.Header.Del("Signature-256")
+	notBase64 := signedRequest(t, key, body, body, true)
+	notBase64.Header.Set("Signature-256", "not base64!")
+	badURL := signedRequest(t, key, body, body, true)
+	badURL.Header.Set("SignatureCertChainUrl", "https://example.com/echo.api/cert.pem")
+	for name, r := range map[string]*http.Request{"missing signature": missing, "not base64": notBase64, "bad url": badURL} {
+		fetches := 0
+		if err := newTestVerifier(ca, valid, &fetches).VerifyRequest(r); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+		if name == "bad url" && fetches != 0 {
+			t.Errorf("Expected a bad url not to be downloaded")
+		}
+	}
+}
+
+func TestVerifyCertURL(t *testing.T) {
+	cases := []struct {
+		url string
+		ok  bool
+	}{
+		{"https://s3.amazonaws.com/echo.api/echo-api-cert.pem", true},
+		{"HTTPS://s3.amazonaws.com/echo.api/echo-api-cert.pem", true},
+		{"https://S3.AMAZONAWS.COM/echo.api/echo-api-cert.pem", true},
+		{"https://s3.amazonaws.com:443/echo.api/echo-api-cert.pem", true},
+		{"https://s3.amazonaws.com/echo.api/../echo.api/echo-api-cert.pem", true},
+		// Scheme
+		{"http://s3.amazonaws.com/echo.api/echo-api-cert.pem", false},
+		{"ftp://s3.amazonaws.com/echo.api/echo-api-cert.pem", false},
+		// Host
+		{"https://notamazon.com/echo.api/echo-api-cert.pem", false},
+		{"https://s3.amazonaws.com.evil.com/echo.api/echo-api-cert.pem", false},
+		// Port
+		{"https://s3.amazonaws.com:563/echo.api/echo-api-cert.pem", false},
+		// Path
+		{"https://s3.amazonaws.com/EcHo.aPi/echo-api-cert.pem", false},
+		{"https://s3.amazonaws.com/invalid.path/echo-api-cert.pem", false},
+		{"https://s3.amazonaws.com/echo.api/../invalid.path/echo-api-cert.pem", false},
+		{"https://s3.amazonaws.com/echo.api", false},
+		// Not a url
+		{"", false},
+		{"://s3.amazonaws.com", false},
+	}
+	for _, c := range cases {
+		if ok := verifyCertURL(c.url); ok != c.ok {
+			t.Errorf("verifyCertURL(%q): expected %v, got %v", c.url, c.ok, ok)
+		}
+	}
+}
+
+func TestVerifyTimestamp(t *testing.T) {
+	v := &AlexaVerifier{Now: func() time.Time { return testNow }}
+	cases := []struct {
+		timestamp string
+		ok        bool
+	}{
+		{testNow.Format(time.RFC3339), true},
+		{testNow.Add(-DefaultTimestampTolerance).Format(time.RFC3339), true},
+		{testNow.Add(DefaultTimestampTolerance).Format(time.RFC3339), true},
+		{testNow.Add(-DefaultTimestampTolerance - time.Second).Format(time.RFC3339), false},
+		{testNow.Add(DefaultTimestampTolerance + time.Second).Format(time.RFC3339), false},
+		{"2026-03-01 12:00:00", false},
+		{"", false},
+	}
+	for _, c := range cases {
+		if err := v.VerifyTimestamp(c.timestamp); c.ok != (err == nil) {
+			t.Errorf("VerifyTimestamp(%q): expected ok to be %v, got %v", c.timestamp, c.ok, err)
+		}
+	}
+
+	v.TimestampTolerance = 10 * time.Second
+	if err := v.VerifyTimestamp(testNow.Add(-11 * time.Second).Format(time.RFC3339)); err == nil {
+		t.Errorf("Expected a custom tolerance to be used")
+	}
+}
+
+func TestCertCache(t *testing.T) {
+	ca := newTestCA(t)
+	// Expires before the cache would
+	chain, key := ca.issue(t, alexaCertSAN, testNow.Add(-time.Hour), testNow.Add(2*time.Hour))
+	body := `{"version":"1.0"}`
+
+	fetches := 0
+	now := testNow
+	v := newTestVerifier(ca, chain, &fetches)
+	v.CacheTTL = 90 * time.Minute
+	v.Now = func() time.Time { return now }
+
+	steps := []struct {
+		after   time.Duration
+		fetches int
+		ok      bool
+	}{
+		{0, 1, true},
+		// Cached
+		{time.Minute, 1, true},
+		// Past the TTL
+		{90 * time.Minute, 2, true},
+		// Cached again, until the certificate expires
+		{20 * time.Minute, 2, true},
+		{10 * time.Minute, 3, false},
+	}
+	for i, step := range steps {
+		now = now.Add(step.after)
+		err := v.VerifyRequest(signedRequest(t, key, body, body, true))
+		if step.ok != (err == nil) || fetches != step.fetches {
+			t.Errorf("Step %d: expected ok to be %v after %d downloads, got %v after %d", i, step.ok, step.fetches, err, fetches)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	biblescholar "github.com/turtlemonvh/biblescholar/search"
+)
+
+// Serving recorded chapters listed in the audio manifest
+
+// Types for common audio extensions, which are missing from some systems' mime tables
+var audioContentTypes = map[string]string{
+	".mp3": "audio/mpeg",
+	".m4a": "audio/mp4",
+	".aac": "audio/aac",
+	".ogg": "audio/ogg",
+}
+
+// Path of the endpoint serving a recording
+func audioPath(rec *biblescholar.AudioRecording) string {
+	return fmt.Sprintf("/audio/%s/%s/%d", url.PathEscape(rec.Version), url.PathEscape(rec.Book), rec.Chapter)
+}
+
+// Absolute url of a recording, which is what alexa needs to stream it
+// Uses AudioBaseURL when set, otherwise the host the request was sent to
+func (s *ServerConfig) audioURL(c *gin.Context, rec *biblescholar.AudioRecording) string {
+	base := s.AudioBaseURL
+	if base == "" {
+		base = fmt.Sprintf("https://%s", c.Request.Host)
+	}
+	return strings.TrimRight(base, "/") + audioPath(rec)
+}
+
+// Stream a recorded chapter
+// Range requests are supported, so players can seek to a verse
+func audioFileHandler(s *ServerConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.Audio == nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"err": "No recordings are available",
+			})
+			return
+		}
+
+		chapter, err := strconv.Atoi(c.Param("chapter"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"err": fmt.Sprintf("Invalid format of chapter, expected int, got: %v", c.Param("chapter")),
+			})
+			return
+		}
+		rec := s.Audio.Lookup(c.Param("version"), c.Param("book"), chapter)
+		if rec == nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"err": fmt.Sprintf("No recording of %s %d (%s)", c.Param("book"), chapter, c.Param("version")),
+			})
+			return
+		}
+
+		f, err := os.Open(rec.File)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"err":  err,
+				"file": rec.File,
+			}).Error("Error while opening recording.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"err": "Could not read recording",
+			})
+			return
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"err": "Could not read recording",
+			})
+			return
+		}
+
+		ext := strings.ToLower(filepath.Ext(rec.File))
+		contentType, ok := audioContentTypes[ext]
+		if !ok {
+			contentType = mime.TypeByExtension(ext)
+		}
+		if contentType != "" {
+			c.Header("Content-Type", contentType)
+		}
+		http.ServeContent(c.Writer, c.Request, filepath.Base(rec.File), info.ModTime(), f)
+	}
+}
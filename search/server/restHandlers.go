@@ -1,9 +1,12 @@
 package server
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/blevesearch/bleve"
@@ -11,6 +14,7 @@ import (
 	"github.com/blevesearch/bleve/search/query"
 	"github.com/gin-gonic/gin"
 	log "github.com/sirupsen/logrus"
+	biblescholar "github.com/turtlemonvh/biblescholar/search"
 )
 
 var (
@@ -47,8 +51,8 @@ func (s *ServerConfig) processQueryParams(c *gin.Context, defaultQuery *string)
 		size = "10"
 	}
 	isize, err := strconv.Atoi(size)
-	if err != nil {
-		err := fmt.Errorf("Invalid format of query parameter 'size', expected int, got: %v", size)
+	if err != nil || isize < 0 {
+		err := fmt.Errorf("Invalid format of query parameter 'size', expected a non-negative int, got: %v", size)
 		c.JSON(http.StatusBadRequest, gin.H{
 			"err": err.Error(),
 		})
@@ -61,8 +65,8 @@ func (s *ServerConfig) processQueryParams(c *gin.Context, defaultQuery *string)
 		from = "0"
 	}
 	ifrom, err := strconv.Atoi(from)
-	if err != nil {
-		err := fmt.Errorf("Invalid format of query parameter 'from', expected int, got: %v", from)
+	if err != nil || ifrom < 0 {
+		err := fmt.Errorf("Invalid format of query parameter 'from', expected a non-negative int, got: %v", from)
 		c.JSON(http.StatusBadRequest, gin.H{
 			"err": err.Error(),
 		})
@@ -180,6 +184,7 @@ func htmlHandler(s *ServerConfig) gin.HandlerFunc {
 }
 
 // Handle a general search query via q query param
+// Optionally limited to versions via 'version' query params
 func searchHandler(s *ServerConfig) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		nRestRequests.Inc(1)
@@ -190,6 +195,10 @@ func searchHandler(s *ServerConfig) gin.HandlerFunc {
 			return
 		}
 
+		userQuery := searchRequest.Query.(*query.QueryStringQuery).Query
+		versions := getVersionParams(c)
+		searchRequest.Query = biblescholar.LimitToVersions(searchRequest.Query, versions)
+
 		searchResult, err := s.Index.Search(searchRequest)
 		if err != nil {
 			log.WithFields(log.Fields{
@@ -202,7 +211,8 @@ func searchHandler(s *ServerConfig) gin.HandlerFunc {
 		}
 
 		log.WithFields(log.Fields{
-			"q":               searchRequest.Query.(*query.QueryStringQuery).Query,
+			"q":               userQuery,
+			"versions":        versions,
 			"size":            searchRequest.Size,
 			"from":            searchRequest.From,
 			"nresults":        len(searchResult.Hits),
@@ -210,8 +220,210 @@ func searchHandler(s *ServerConfig) gin.HandlerFunc {
 			"facets":          (len(searchRequest.Facets) == 0),
 		}).Debug("Composed search object")
 
-		c.JSON(http.StatusOK, searchResult)
+		// Fall back to fuzzy matching when nothing matches the phrase well
+		fuzzy := c.DefaultQuery("fuzzy", "auto")
+		useFuzzy := fuzzy == "on"
+		if fuzzy == "auto" {
+			if useFuzzy, err = s.preferFuzzy(userQuery, versions, searchRequest, searchResult); err != nil {
+				log.WithFields(log.Fields{
+					"err": err,
+				}).Error("Error while choosing between exact and fuzzy search.")
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"err": err.Error(),
+				})
+				return
+			}
+		}
+		if useFuzzy {
+			matches, total, err := biblescholar.FuzzySearch(s.Index, userQuery, versions, searchRequest.From, searchRequest.Size)
+			if err != nil {
+				log.WithFields(log.Fields{
+					"err": err,
+				}).Error("Error while executing fuzzy search query.")
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"err": err.Error(),
+				})
+				return
+			}
+			c.JSON(http.StatusOK, newFuzzySearchResult(searchRequest, matches, total))
+			return
+		}
+
+		c.JSON(http.StatusOK, &restSearchResult{searchResult, false})
 
 		return
 	}
 }
+
+// Search results, with whether they came from fuzzy matching
+// For fuzzy matches each hit's score is its confidence, from 0 to 1; keep paging with fuzzy=on to get more of them
+type restSearchResult struct {
+	*bleve.SearchResult
+	Fuzzy bool `json:"fuzzy"`
+}
+
+func newFuzzySearchResult(searchRequest *bleve.SearchRequest, matches []*biblescholar.FuzzyMatch, total int) *restSearchResult {
+	result := &bleve.SearchResult{
+		Status:  &bleve.SearchStatus{Total: 1, Successful: 1},
+		Request: searchRequest,
+		Hits:    search.DocumentMatchCollection{},
+		Total:   uint64(total),
+	}
+	for _, m := range matches {
+		result.Hits = append(result.Hits, &search.DocumentMatch{
+			ID:    m.Verse.Id(),
+			Score: m.Confidence,
+			Fields: map[string]interface{}{
+				"Version": m.Verse.Version,
+				"Book":    m.Verse.Book,
+				"Chapter": m.Verse.Chapter,
+				"Verse":   m.Verse.Verse,
+				"Text":    m.Verse.Text,
+			},
+		})
+		if m.Confidence > result.MaxScore {
+			result.MaxScore = m.Confidence
+		}
+	}
+	return &restSearchResult{result, true}
+}
+
+// Whether fuzzy matches answer the query better than the exact search
+// The first page decides for every page, so paging with fuzzy=auto stays on one kind of result
+func (s *ServerConfig) preferFuzzy(userQuery string, versions []string, searchRequest *bleve.SearchRequest, searchResult *bleve.SearchResult) (bool, error) {
+	first := searchResult
+	if searchRequest.From != 0 {
+		firstRequest := bleve.NewSearchRequestOptions(searchRequest.Query, 1, 0, false)
+		firstRequest.Fields = searchRequest.Fields
+		var err error
+		if first, err = s.Index.Search(firstRequest); err != nil {
+			return false, err
+		}
+	}
+	if !isWeakResult(userQuery, first) {
+		return false, nil
+	}
+
+	matches, _, err := biblescholar.FuzzySearch(s.Index, userQuery, versions, 0, 1)
+	if err != nil {
+		return false, err
+	}
+	return len(matches) != 0 && (len(first.Hits) == 0 || matches[0].Confidence > hitConfidence(userQuery, first.Hits[0])), nil
+}
+
+// No hits, or a best hit that shares little with the query
+func isWeakResult(userQuery string, searchResult *bleve.SearchResult) bool {
+	return len(searchResult.Hits) == 0 || hitConfidence(userQuery, searchResult.Hits[0]) < biblescholar.LowConfidence
+}
+
+func hitConfidence(userQuery string, hit *search.DocumentMatch) float64 {
+	text, _ := hit.Fields["Text"].(string)
+	return biblescholar.PhraseConfidence(userQuery, text)
+}
+
+// Versions from the 'version' query param
+// Accepts both repeated params and comma separated lists, e.g. "?version=ESV&version=NIV" or "?version=ESV,NIV"
+func getVersionParams(c *gin.Context) []string {
+	var versions []string
+	for _, param := range c.QueryArray("version") {
+		for _, version := range strings.Split(param, ",") {
+			if version = strings.TrimSpace(version); version != "" {
+				versions = append(versions, version)
+			}
+		}
+	}
+	return versions
+}
+
+// Look up a passage by reference via 'ref' and optional 'version' query params
+// e.g. /passage?ref=John+3:16-21&version=ESV&version=NIV
+func passageHandler(s *ServerConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		nRestRequests.Inc(1)
+
+		ref, exists := c.GetQuery("ref")
+		if !exists {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"err": "Missing required query parameter 'ref'",
+			})
+			return
+		}
+
+		refs, err := biblescholar.ParseReference(ref)
+		if err != nil {
+			resp := gin.H{
+				"err":       err.Error(),
+				"reference": ref,
+			}
+			if refErr, ok := err.(*biblescholar.ReferenceError); ok {
+				resp["reason"] = refErr.Reason
+			}
+			c.JSON(http.StatusBadRequest, resp)
+			return
+		}
+
+		versions := getVersionParams(c)
+		verses, err := biblescholar.LookupPassage(s.Index, refs, versions)
+		if err != nil {
+			if errors.Is(err, biblescholar.ErrPassageTooLong) {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"err":       err.Error(),
+					"reference": refs.String(),
+				})
+				return
+			}
+			log.WithFields(log.Fields{
+				"err": err,
+			}).Error("Error while executing passage query.")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"err": err.Error(),
+			})
+			return
+		}
+
+		log.WithFields(log.Fields{
+			"ref":      ref,
+			"parsed":   refs.String(),
+			"versions": versions,
+			"nverses":  len(verses),
+		}).Debug("Looked up passage")
+
+		if len(verses) == 0 {
+			c.JSON(http.StatusNotFound, gin.H{
+				"err":       fmt.Sprintf("No verses found for '%s'", refs.String()),
+				"reference": refs.String(),
+				"versions":  versions,
+			})
+			return
+		}
+
+		// Group by version, keeping the canonical verse order within each
+		byVersion := make(map[string][]*biblescholar.Verse)
+		for _, v := range verses {
+			byVersion[v.Version] = append(byVersion[v.Version], v)
+		}
+		if len(versions) == 0 {
+			for version := range byVersion {
+				versions = append(versions, version)
+			}
+			sort.Strings(versions)
+		}
+
+		groups := []gin.H{}
+		for _, version := range versions {
+			versionVerses := byVersion[version]
+			if versionVerses == nil {
+				versionVerses = []*biblescholar.Verse{}
+			}
+			groups = append(groups, gin.H{
+				"version": version,
+				"verses":  versionVerses,
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"reference": refs.String(),
+			"versions":  groups,
+		})
+	}
+}
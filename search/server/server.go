@@ -5,15 +5,17 @@ import (
 	"html/template"
 	"net/http"
 	"runtime"
+	"sync"
 	"time"
 
-	"github.com/Jeffail/gabs"
 	"github.com/blevesearch/bleve"
 	"github.com/gin-gonic/contrib/ginrus"
 	"github.com/gin-gonic/gin"
 	"github.com/rcrowley/go-metrics"
 	"github.com/rcrowley/go-metrics/exp"
 	log "github.com/sirupsen/logrus"
+	biblescholar "github.com/turtlemonvh/biblescholar/search"
+	"github.com/turtlemonvh/biblescholar/search/conversation"
 
 	//"github.com/rcrowley/go-metrics/exp"
 	"gopkg.in/tylerb/graceful.v1"
@@ -48,7 +50,19 @@ type ServerConfig struct {
 	BuildBranch         string
 	Index               bleve.Index
 	ShouldValidateAlexa bool
-	template            *template.Template
+	// Skills allowed to call the alexa endpoint; BibleScholarAppId with default settings if empty
+	Skills []*SkillProfile
+	// Checks request signatures when ShouldValidateAlexa is set; defaults to downloading certs from amazon
+	AlexaVerifier *AlexaVerifier
+	// Per user settings such as the preferred translation; kept in memory if nil
+	Preferences conversation.PreferenceStore
+	// Recorded chapters to play instead of reading; passages are always read if nil
+	Audio *biblescholar.AudioManifest
+	// Public https address of this server, used in links to recordings; defaults to the request's host
+	AudioBaseURL string
+	template     *template.Template
+	engine       *conversation.Engine
+	verifierOnce sync.Once
 }
 
 func (s *ServerConfig) VersionString() string {
@@ -62,6 +76,31 @@ func (s *ServerConfig) StartServer() {
 		"branch": s.BuildBranch,
 	}).Info("Starting server")
 
+	r := s.Router()
+
+	log.WithFields(log.Fields{
+		"port": s.Port,
+	}).Info("Starting server")
+
+	srv := &graceful.Server{
+		Timeout: 10 * time.Second,
+		Server: &http.Server{
+			Addr:    fmt.Sprintf(":%d", s.Port),
+			Handler: r,
+		},
+		BeforeShutdown: func() bool {
+			log.Warn("Starting shutdown.")
+			return true
+		},
+	}
+	srv.ListenAndServe()
+
+	log.Warn("Everything safely closed. Exiting main process.")
+}
+
+// All routes served by the application
+// Also used to run requests through the handlers in-process, e.g. by the alexa simulator
+func (s *ServerConfig) Router() *gin.Engine {
 	var err error
 	s.template, err = template.New("ServerTemplate").Funcs(template.FuncMap{
 		// Allow rendering of raw html
@@ -73,6 +112,15 @@ func (s *ServerConfig) StartServer() {
 		panic(err)
 	}
 
+	if s.Preferences == nil {
+		s.Preferences = conversation.NewMemoryPreferenceStore()
+	}
+	s.engine = &conversation.Engine{
+		Index:       s.Index,
+		Preferences: s.Preferences,
+		Audio:       s.Audio,
+	}
+
 	gin.SetMode(gin.ReleaseMode)
 	r := gin.New()
 	r.Use(ginrus.Ginrus(log.StandardLogger(), time.RFC3339, true))
@@ -84,26 +132,11 @@ func (s *ServerConfig) StartServer() {
 		c.JSON(200, s.Index.Mapping())
 	})
 	r.GET("/search", searchHandler(s))
+	r.GET("/passage", passageHandler(s))
+	r.GET("/audio/:version/:book/:chapter", audioFileHandler(s))
+	r.HEAD("/audio/:version/:book/:chapter", audioFileHandler(s))
 	r.POST("/alexa/search", alexaSearchHandler(s))
-
-	log.WithFields(log.Fields{
-		"port": s.Port,
-	}).Info("Starting server")
-
-	srv := &graceful.Server{
-		Timeout: 10 * time.Second,
-		Server: &http.Server{
-			Addr:    fmt.Sprintf(":%d", s.Port),
-			Handler: r,
-		},
-		BeforeShutdown: func() bool {
-			log.Warn("Starting shutdown.")
-			return true
-		},
-	}
-	srv.ListenAndServe()
-
-	log.Warn("Everything safely closed. Exiting main process.")
+	return r
 }
 
 // Top level handlers
@@ -119,7 +152,6 @@ func versionHandler(s *ServerConfig) gin.HandlerFunc {
 }
 
 // Respond to search requests
-// FIXME: Add tests
 // FIXME: Break down into smaller functions
 func alexaSearchHandler(s *ServerConfig) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -134,13 +166,12 @@ func alexaSearchHandler(s *ServerConfig) gin.HandlerFunc {
 
 		resp := s.getNewResponseTemplate()
 
-		req, err := gabs.ParseJSONBuffer(c.Request.Body)
-		if err != nil || req == nil {
+		req, err := ParseAlexaRequest(c.Request.Body)
+		if err != nil {
 			log.WithFields(log.Fields{
 				"err": err,
-				"req": req,
 			}).Error("Error while parsing request body")
-			c.JSON(http.StatusBadRequest, resp.Data())
+			c.JSON(http.StatusBadRequest, resp)
 			return
 		}
 
@@ -149,29 +180,32 @@ func alexaSearchHandler(s *ServerConfig) gin.HandlerFunc {
 				// Actual response is set inside this function
 				return
 			}
+			if err := s.validateTimestamp(c, req); err != nil {
+				return
+			}
 		}
 
-		t, err := getRequestType(req)
-		if err != nil {
-			log.WithFields(log.Fields{
-				"err": err,
-			}).Error("Error getting request type")
-			c.JSON(http.StatusBadRequest, resp.Data())
-			return
+		// Unknown skills are only possible when not validating, and get the default settings
+		if profile := s.skillProfileFor(req.ApplicationID()); profile != nil {
+			c.Set(skillProfileKey, profile)
 		}
 
-		switch t {
-		case intentRequest:
-			s.handleIntentRequest(c, req, resp)
-		case launchRequest:
-			s.handleLaunchRequest(c, req, resp)
+		log.WithFields(log.Fields{
+			"type": req.Request.Type,
+		}).Debug("Identified request type")
+
+		switch req.Request.Type {
+		case intentRequest, launchRequest:
+			s.handleConversationRequest(c, req)
 		case sessionEndedRequest:
-			s.handleSessionEndedRequest(c, req, resp)
+			s.handleSessionEndedRequest(c, req)
+		case canFulfillIntentRequest:
+			s.handleCanFulfillIntentRequest(c, req)
+		case exceptionEncounteredRequest:
+			s.handleExceptionEncounteredRequest(c, req)
 		default:
-			log.WithFields(log.Fields{
-				"type": t,
-			}).Error("Unknown request type")
-			c.JSON(http.StatusBadRequest, resp.Data())
+			// AudioPlayer and PlaybackController requests
+			s.handleAudioRequest(c, req)
 		}
 	}
 }
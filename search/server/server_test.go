@@ -0,0 +1,150 @@
+package server
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	biblescholar "github.com/turtlemonvh/biblescholar/search"
+)
+
+const testAppID = "amzn1.ask.skill.test"
+
+// A server over a small index in a temporary directory, removed by the returned function
+func newTestServer(t *testing.T) (*ServerConfig, func()) {
+	dir, err := ioutil.TempDir("", "server")
+	if err != nil {
+		t.Fatal(err)
+	}
+	index := biblescholar.CreateOrOpenIndex(filepath.Join(dir, biblescholar.DefaultIndexName))
+	for _, v := range []*biblescholar.Verse{
+		{Version: "KJV", Book: "John", Chapter: 3, Verse: 16, Text: "For God so loved the world, that he gave his only begotten Son."},
+		{Version: "KJV", Book: "John", Chapter: 3, Verse: 17, Text: "For God sent not his Son into the world to condemn the world."},
+		{Version: "KJV", Book: "Psalm", Chapter: 23, Verse: 1, Text: "The LORD is my shepherd; I shall not want."},
+	} {
+		v.VersionBook = v.Version + "-" + v.Book
+		if err := index.Index(v.Id(), v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := &ServerConfig{
+		Index:  index,
+		Skills: []*SkillProfile{{AppID: testAppID, Name: "Test Scholar"}},
+	}
+	return s, func() {
+		index.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+// An alexa request envelope for an app, with the request body given
+func alexaBody(appID string, request string) string {
+	return `{"version":"1.0","session":{"new":true,"sessionId":"s1","application":{"applicationId":"` + appID + `"},"user":{"userId":"u1"}},"request":` + request + `}`
+}
+
+func postAlexa(s *ServerConfig, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	s.Router().ServeHTTP(w, r)
+	return w
+}
+
+func TestAlexaSearchHandler(t *testing.T) {
+	s, cleanup := newTestServer(t)
+	defer cleanup()
+
+	cases := []struct {
+		name     string
+		body     string
+		status   int
+		contains []string
+		excludes []string
+	}{
+		{"launch", alexaBody(testAppID, `{"type":"LaunchRequest","requestId":"r1","locale":"en-US"}`),
+			http.StatusOK, []string{`"outputSpeech"`, "Test Scholar", `"shouldEndSession":false`}, nil},
+		{"intent", alexaBody(testAppID, `{"type":"IntentRequest","requestId":"r1","locale":"en-US","intent":{"name":"SearchBible","slots":{"QueryPhrase":{"name":"QueryPhrase","value":"my shepherd"}}}}`),
+			http.StatusOK, []string{"Psalm chapter", "I shall not want"}, nil},
+		{"read passage", alexaBody(testAppID, `{"type":"IntentRequest","requestId":"r1","intent":{"name":"ReadPassage","slots":{"Book":{"name":"Book","value":"John"},"Chapter":{"name":"Chapter","value":"3"},"StartVerse":{"name":"StartVerse","value":"16"}}}}`),
+			http.StatusOK, []string{"For God so loved the world"}, []string{"condemn"}},
+		{"unknown intent", alexaBody(testAppID, `{"type":"IntentRequest","requestId":"r1","intent":{"name":"OrderPizza"}}`),
+			http.StatusOK, []string{`"outputSpeech"`}, nil},
+		{"session ended", alexaBody(testAppID, `{"type":"SessionEndedRequest","requestId":"r1","reason":"USER_INITIATED"}`),
+			http.StatusOK, []string{`"shouldEndSession":true`}, []string{`"outputSpeech"`}},
+		{"can fulfill", alexaBody(testAppID, `{"type":"CanFulfillIntentRequest","requestId":"r1","intent":{"name":"ReadPassage","slots":{"Book":{"name":"Book","value":"John"},"Chapter":{"name":"Chapter","value":"3"}}}}`),
+			http.StatusOK, []string{`"canFulfill":"YES"`}, []string{`"outputSpeech"`, `"shouldEndSession"`}},
+		{"can fulfill missing passage", alexaBody(testAppID, `{"type":"CanFulfillIntentRequest","requestId":"r1","intent":{"name":"ReadPassage","slots":{"Book":{"name":"Book","value":"Jude"},"Chapter":{"name":"Chapter","value":"1"}}}}`),
+			http.StatusOK, []string{`"canFulfill":"NO"`}, nil},
+		{"audio player", `{"version":"1.0","context":{"System":{"application":{"applicationId":"` + testAppID + `"}}},"request":{"type":"AudioPlayer.PlaybackStarted","requestId":"r1","token":"KJV|John|3"}}`,
+			http.StatusOK, []string{`"response"`}, []string{`"outputSpeech"`, `"shouldEndSession"`}},
+		{"playback controller", `{"version":"1.0","context":{"System":{"application":{"applicationId":"` + testAppID + `"}}},"request":{"type":"PlaybackController.PauseCommandIssued","requestId":"r1"}}`,
+			http.StatusOK, []string{`"AudioPlayer.Stop"`}, []string{`"outputSpeech"`}},
+		{"exception encountered", `{"version":"1.0","context":{"System":{"application":{"applicationId":"` + testAppID + `"}}},"request":{"type":"System.ExceptionEncountered","requestId":"r1","error":{"type":"INVALID_RESPONSE","message":"Bad directive"},"cause":{"requestId":"r0"}}}`,
+			http.StatusOK, nil, []string{`"outputSpeech"`, `"directives"`, `"shouldEndSession"`}},
+		// Malformed bodies
+		{"not json", `not json`, http.StatusBadRequest, nil, nil},
+		{"empty", ``, http.StatusBadRequest, nil, nil},
+		{"truncated", alexaBody(testAppID, `{"type":"LaunchRequest"`), http.StatusBadRequest, nil, nil},
+		{"no type", alexaBody(testAppID, `{"requestId":"r1"}`), http.StatusBadRequest, nil, nil},
+		{"unknown type", alexaBody(testAppID, `{"type":"Messaging.MessageReceived","requestId":"r1"}`), http.StatusBadRequest, nil, nil},
+		{"intent without a name", alexaBody(testAppID, `{"type":"IntentRequest","requestId":"r1","intent":{}}`), http.StatusBadRequest, nil, nil},
+	}
+	for _, c := range cases {
+		w := postAlexa(s, httptest.NewRequest("POST", "/alexa/search", strings.NewReader(c.body)))
+		if w.Code != c.status {
+			t.Errorf("%s: expected status %d, got %d: %s", c.name, c.status, w.Code, w.Body.String())
+			continue
+		}
+		for _, text := range c.contains {
+			if !strings.Contains(w.Body.String(), text) {
+				t.Errorf("%s: expected the response to contain %s, got %s", c.name, text, w.Body.String())
+			}
+		}
+		for _, text := range c.excludes {
+			if strings.Contains(w.Body.String(), text) {
+				t.Errorf("%s: expected the response not to contain %s, got %s", c.name, text, w.Body.String())
+			}
+		}
+	}
+}
+
+func TestAlexaSearchHandlerValidation(t *testing.T) {
+	s, cleanup := newTestServer(t)
+	defer cleanup()
+
+	ca := newTestCA(t)
+	chain, key := ca.issue(t, alexaCertSAN, testNow.Add(-time.Hour), testNow.Add(24*time.Hour))
+	fetches := 0
+	s.ShouldValidateAlexa = true
+	s.AlexaVerifier = newTestVerifier(ca, chain, &fetches)
+
+	launch := func(appID string, timestamp time.Time) string {
+		return alexaBody(appID, `{"type":"LaunchRequest","requestId":"r1","timestamp":"`+timestamp.Format(time.RFC3339)+`"}`)
+	}
+	unsigned := httptest.NewRequest("POST", "/alexa/search", bytes.NewBufferString(launch(testAppID, testNow)))
+	unsigned.Header.Set("SignatureCertChainUrl", testCertURL)
+
+	cases := []struct {
+		name    string
+		request *http.Request
+		status  int
+	}{
+		{"valid", signedRequest(t, key, launch(testAppID, testNow), launch(testAppID, testNow), true), http.StatusOK},
+		{"wrong app id", signedRequest(t, key, launch("amzn1.ask.skill.other", testNow), launch("amzn1.ask.skill.other", testNow), true), http.StatusBadRequest},
+		// The production skill is only allowed when no skills are configured
+		{"default app id", signedRequest(t, key, launch(BibleScholarAppId, testNow), launch(BibleScholarAppId, testNow), true), http.StatusBadRequest},
+		{"missing app id", signedRequest(t, key, launch("", testNow), launch("", testNow), true), http.StatusBadRequest},
+		{"stale", signedRequest(t, key, launch(testAppID, testNow.Add(-time.Hour)), launch(testAppID, testNow.Add(-time.Hour)), true), http.StatusBadRequest},
+		{"unsigned", unsigned, http.StatusUnauthorized},
+		{"tampered", signedRequest(t, key, launch("amzn1.ask.skill.other", testNow), launch(testAppID, testNow), true), http.StatusUnauthorized},
+	}
+	for _, c := range cases {
+		if w := postAlexa(s, c.request); w.Code != c.status {
+			t.Errorf("%s: expected status %d, got %d: %s", c.name, c.status, w.Code, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// Settings for one Alexa skill served by this binary, e.g. production and staging skills
+type SkillProfile struct {
+	AppID string `mapstructure:"app-id"`
+	// Name used in prompts and card titles
+	Name string `mapstructure:"name"`
+	// Version to search and read from; empty searches all versions and reads the engine's default reading version
+	DefaultVersion string `mapstructure:"default-version"`
+}
+
+const (
+	defaultSkillName string = "BibleScholar"
+	// Key for the request's skill profile on the gin context
+	skillProfileKey string = "skillProfile"
+)
+
+// Used when no skills are configured
+var defaultSkillProfile = &SkillProfile{
+	AppID: BibleScholarAppId,
+	Name:  defaultSkillName,
+}
+
+// Profiles for the configured skills, or the default skill if none are configured
+func (s *ServerConfig) skillProfiles() []*SkillProfile {
+	if len(s.Skills) == 0 {
+		return []*SkillProfile{defaultSkillProfile}
+	}
+	return s.Skills
+}
+
+// Profile for an app id, or nil if the app id is not configured
+func (s *ServerConfig) skillProfileFor(appID string) *SkillProfile {
+	for _, profile := range s.skillProfiles() {
+		if profile.AppID == appID {
+			return profile
+		}
+	}
+	return nil
+}
+
+// Profile of the skill that sent the current request
+func skillProfile(c *gin.Context) *SkillProfile {
+	if profile, ok := c.Get(skillProfileKey); ok {
+		return profile.(*SkillProfile)
+	}
+	return defaultSkillProfile
+}
+
+func (p *SkillProfile) name() string {
+	if p.Name == "" {
+		return defaultSkillName
+	}
+	return p.Name
+}
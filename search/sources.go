@@ -0,0 +1,507 @@
+package biblescholar
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Reading verses from data files in any of the supported formats, plain or compressed
+
+// A stream of verses from one file, zip archive entry or stdin
+type VerseSource interface {
+	// Next verse, or io.EOF after the last one
+	// Errors name the source, and the line where the format allows; reading can carry on after a *RowError
+	Next() (*Verse, error)
+	// Line the last verse read started on, or 0 for formats without lines
+	Line() int
+	Info() SourceInfo
+	Close() error
+}
+
+// A row that could not be read, e.g. a tsv row with too few columns; the rows after it can still be read
+type RowError struct {
+	Source string
+	Line   int
+	Err    error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("Error reading '%s': Line %d: %v", e.Source, e.Line, e.Err)
+}
+
+// Where the verses of a source come from
+type SourceInfo struct {
+	// File path, "-" for stdin, or "<archive>.zip:<entry>" for a file in a zip archive
+	Name string
+	// Name in verseFormats; set once the source is read when it is detected from the content
+	Format string
+	// "gzip" for gzipped data, otherwise empty
+	Compression string
+	// Used by formats that do not name their translation, e.g. USFM
+	Version string
+	// Bytes in the file as stored, or 0 when not known, e.g. for stdin
+	Size int64
+	// Bytes of the file read so far, for progress reports
+	BytesRead int64
+}
+
+// Returns the next verse of a stream and the line it started on, or io.EOF after the last one
+// Rows that cannot be read are reported as a *RowError
+type nextVerse func() (*Verse, int, error)
+
+// How to read one format
+type verseFormat struct {
+	// Lower case, with the dot
+	Extensions []string
+	// Root element of XML formats; files ending in .xml are read as the format their root element names
+	XMLRoot string
+	// The version is for formats that do not name their translation
+	Open func(r io.Reader, version string) nextVerse
+}
+
+// Formats by name, as given to --format; add new formats here
+var verseFormats = map[string]*verseFormat{
+	"tsv":     {Extensions: []string{".tsv"}, Open: newTSVReader},
+	"csv":     {Extensions: []string{".csv"}, Open: newCSVReader},
+	"jsonl":   {Extensions: []string{".jsonl", ".ndjson"}, Open: newJSONLinesReader},
+	"usfm":    {Extensions: []string{".usfm", ".sfm"}, Open: newUSFMReader},
+	"usx":     {Extensions: []string{".usx"}, XMLRoot: "usx", Open: newUSXReader},
+	"osis":    {Extensions: []string{".osis"}, XMLRoot: "osis", Open: newOSISReader},
+	"zefania": {XMLRoot: "XMLBIBLE", Open: newZefaniaReader},
+}
+
+// Sorted names of the supported formats
+func VerseFormatNames() []string {
+	var names []string
+	for name := range verseFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Format of a file from its extension, ignoring a trailing .gz
+// Files ending in .xml have an empty format, to be detected from their root element
+func formatForName(name string) (format string, ok bool) {
+	ext := strings.ToLower(filepath.Ext(strings.TrimSuffix(strings.ToLower(name), ".gz")))
+	if ext == ".xml" {
+		return "", true
+	}
+	for format, f := range verseFormats {
+		for _, e := range f.Extensions {
+			if e == ext {
+				return format, true
+			}
+		}
+	}
+	return "", false
+}
+
+// Whether a file in a data directory should be indexed, e.g. "KJV.tsv", "web.usfm.gz" or "bibles.zip"
+func IsVerseFile(name string) bool {
+	if strings.ToLower(filepath.Ext(name)) == ".zip" {
+		return true
+	}
+	_, ok := formatForName(name)
+	return ok
+}
+
+// Sources for a path: every verse file in a directory, each verse file in a zip archive, a single file, or "-" for stdin
+// The format is picked by extension, or from the content for stdin and .xml files; a format given here is used for every source
+// Files are not opened until their first verse is read
+func OpenVerseSources(path string, format string, version string) ([]VerseSource, error) {
+	if format != "" {
+		if _, ok := verseFormats[format]; !ok {
+			return nil, fmt.Errorf("Unknown format: '%s'; expected one of: %s", format, strings.Join(VerseFormatNames(), ", "))
+		}
+	}
+	if path == "-" {
+		return []VerseSource{&verseSource{
+			info: SourceInfo{Name: path, Format: format, Version: version},
+			open: func() (io.ReadCloser, error) { return ioutil.NopCloser(os.Stdin), nil },
+		}}, nil
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !stat.IsDir() {
+		return fileSources(path, format, version)
+	}
+
+	entries, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	var sources []VerseSource
+	for _, entry := range entries {
+		if entry.IsDir() || !IsVerseFile(entry.Name()) {
+			continue
+		}
+		s, err := fileSources(filepath.Join(path, entry.Name()), format, version)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, s...)
+	}
+	return sources, nil
+}
+
+func fileSources(path string, format string, version string) ([]VerseSource, error) {
+	if strings.ToLower(filepath.Ext(path)) == ".zip" {
+		return zipSources(path, format, version)
+	}
+	if format == "" {
+		format, _ = formatForName(path)
+	}
+	var size int64
+	if stat, err := os.Stat(path); err == nil {
+		size = stat.Size()
+	}
+	return []VerseSource{&verseSource{
+		info: SourceInfo{Name: path, Format: format, Version: version, Size: size},
+		open: func() (io.ReadCloser, error) { return os.Open(path) },
+	}}, nil
+}
+
+// A source for each verse file in a zip archive, in archive order
+func zipSources(path string, format string, version string) ([]VerseSource, error) {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading '%s': %v", path, err)
+	}
+	defer archive.Close()
+
+	var sources []VerseSource
+	for _, entry := range archive.File {
+		if entry.FileInfo().IsDir() || !IsVerseFile(entry.Name) || strings.HasSuffix(strings.ToLower(entry.Name), ".zip") {
+			continue
+		}
+		entryFormat := format
+		if entryFormat == "" {
+			entryFormat, _ = formatForName(entry.Name)
+		}
+		name := entry.Name
+		sources = append(sources, &verseSource{
+			info: SourceInfo{Name: fmt.Sprintf("%s:%s", path, name), Format: entryFormat, Version: version, Size: int64(entry.UncompressedSize64)},
+			open: func() (io.ReadCloser, error) { return openZipEntry(path, name) },
+		})
+	}
+	return sources, nil
+}
+
+// Reads one file of a zip archive, closing the archive with it
+func openZipEntry(path string, name string) (io.ReadCloser, error) {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range archive.File {
+		if entry.Name == name {
+			rc, err := entry.Open()
+			if err != nil {
+				archive.Close()
+				return nil, err
+			}
+			return &zipEntryReader{ReadCloser: rc, archive: archive}, nil
+		}
+	}
+	archive.Close()
+	return nil, fmt.Errorf("No file named '%s' in the archive", name)
+}
+
+type zipEntryReader struct {
+	io.ReadCloser
+	archive *zip.ReadCloser
+}
+
+func (z *zipEntryReader) Close() error {
+	z.ReadCloser.Close()
+	return z.archive.Close()
+}
+
+// Opens its data on the first call to Next, undoing gzip and detecting the format if needed
+type verseSource struct {
+	info SourceInfo
+	open func() (io.ReadCloser, error)
+	rc   io.ReadCloser
+	next nextVerse
+	line int
+}
+
+func (s *verseSource) Info() SourceInfo {
+	return s.info
+}
+
+func (s *verseSource) Next() (*Verse, error) {
+	if s.next == nil {
+		if err := s.start(); err != nil {
+			s.next = func() (*Verse, int, error) { return nil, 0, err }
+		}
+	}
+	v, line, err := s.next()
+	s.line = line
+	if rowErr, ok := err.(*RowError); ok {
+		rowErr.Source = s.info.Name
+		return nil, rowErr
+	}
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("Error reading '%s': %v", s.info.Name, err)
+	}
+	return v, err
+}
+
+func (s *verseSource) Line() int {
+	return s.line
+}
+
+func (s *verseSource) start() error {
+	rc, err := s.open()
+	if err != nil {
+		return err
+	}
+	s.rc = rc
+
+	br := bufio.NewReaderSize(&countingReader{r: rc, n: &s.info.BytesRead}, 64*1024)
+	if magic, _ := br.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		s.info.Compression = "gzip"
+		br = bufio.NewReaderSize(gz, 64*1024)
+	}
+
+	if s.info.Format == "" {
+		if s.info.Format, err = sniffFormat(br); err != nil {
+			return err
+		}
+	}
+	s.next = verseFormats[s.info.Format].Open(br, s.info.Version)
+	return nil
+}
+
+func (s *verseSource) Close() error {
+	if s.rc == nil {
+		return nil
+	}
+	return s.rc.Close()
+}
+
+// Adds the bytes read through it to n
+type countingReader struct {
+	r io.Reader
+	n *int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	*c.n += int64(n)
+	return n, err
+}
+
+// Format of data without a telling extension, from its first bytes
+func sniffFormat(br *bufio.Reader) (string, error) {
+	head, err := br.Peek(64 * 1024)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return "", err
+	}
+	head = bytes.TrimLeft(bytes.TrimPrefix(head, []byte("\xef\xbb\xbf")), " \t\r\n")
+	switch {
+	case len(head) == 0:
+		return "", fmt.Errorf("No data")
+	case head[0] == '<':
+		root, err := xmlRootElement(head)
+		if err != nil {
+			return "", err
+		}
+		for name, f := range verseFormats {
+			if f.XMLRoot != "" && f.XMLRoot == root {
+				return name, nil
+			}
+		}
+		return "", fmt.Errorf("Unknown XML format with root element: '%s'", root)
+	case head[0] == '{':
+		return "jsonl", nil
+	case head[0] == '\\':
+		return "usfm", nil
+	}
+
+	firstLine := head
+	if i := bytes.IndexByte(head, '\n'); i != -1 {
+		firstLine = head[:i]
+	}
+	switch {
+	case bytes.Contains(firstLine, []byte("\t")):
+		return "tsv", nil
+	case bytes.Contains(firstLine, []byte(",")):
+		return "csv", nil
+	}
+	return "", fmt.Errorf("Could not tell the format of the data; give it with --format")
+}
+
+// Whether a first row names the columns, e.g. "version<tab>book<tab>chapter<tab>verse<tab>text"
+func isHeaderRow(row int, record []string) bool {
+	return row == 1 && strings.EqualFold(strings.TrimSpace(record[0]), "version")
+}
+
+// Rows of <version> <book> <chapter> <verse> <text>, as written by the scraper
+// Fields are not quoted, so quotes in the text are read as they are; a header row is skipped
+func newTSVReader(r io.Reader, version string) nextVerse {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	return func() (*Verse, int, error) {
+		for scanner.Scan() {
+			line++
+			text := strings.TrimSuffix(scanner.Text(), "\r")
+			if text == "" {
+				continue
+			}
+			record := strings.SplitN(text, "\t", 5)
+			if isHeaderRow(line, record) {
+				continue
+			}
+			v, err := NewVerseFromLine(record)
+			if err != nil {
+				return nil, line, &RowError{Line: line, Err: err}
+			}
+			return v, line, nil
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, line, err
+		}
+		return nil, line, io.EOF
+	}
+}
+
+// The same columns as a tsv, with fields quoted as needed
+// A header row is skipped; lines are counted by row, so are off after a quoted field spanning lines
+func newCSVReader(r io.Reader, version string) nextVerse {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	row := 0
+	return func() (*Verse, int, error) {
+		for {
+			record, err := cr.Read()
+			if parseErr, ok := err.(*csv.ParseError); ok {
+				row++
+				return nil, parseErr.Line, &RowError{Line: parseErr.Line, Err: parseErr.Err}
+			}
+			if err != nil {
+				return nil, row, err
+			}
+			row++
+			if isHeaderRow(row, record) {
+				continue
+			}
+			v, err := NewVerseFromLine(record)
+			if err != nil {
+				return nil, row, &RowError{Line: row, Err: err}
+			}
+			return v, row, nil
+		}
+	}
+}
+
+// One JSON object per line, e.g. {"version": "KJV", "book": "John", "chapter": 3, "verse": 16, "text": "For God so loved..."}
+// The version given is used for objects that do not have one
+func newJSONLinesReader(r io.Reader, version string) nextVerse {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	return func() (*Verse, int, error) {
+		for scanner.Scan() {
+			line++
+			data := bytes.TrimSpace(scanner.Bytes())
+			if len(data) == 0 {
+				continue
+			}
+			v := &Verse{}
+			if err := json.Unmarshal(data, v); err != nil {
+				return nil, line, &RowError{Line: line, Err: err}
+			}
+			if v.Version == "" {
+				v.Version = version
+			}
+			if v.Version == "" {
+				return nil, line, &RowError{Line: line, Err: fmt.Errorf("No version given, and the verse does not name one")}
+			}
+			if err := checkJSONVerse(v); err != nil {
+				return nil, line, &RowError{Line: line, Err: err}
+			}
+			v.VersionBook = fmt.Sprintf("%s-%s", v.Version, v.Book)
+			return v, line, nil
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, line, err
+		}
+		return nil, line, io.EOF
+	}
+}
+
+// The same checks NewVerseFromLine makes of a tsv row
+func checkJSONVerse(v *Verse) error {
+	switch {
+	case strings.TrimSpace(v.Book) == "":
+		return fmt.Errorf("Missing book name")
+	case v.Chapter < 1:
+		return fmt.Errorf("Invalid chapter number: %d", v.Chapter)
+	case v.Verse < 1:
+		return fmt.Errorf("Invalid verse number: %d", v.Verse)
+	}
+	return nil
+}
+
+// USFM books are read whole, then handed out a verse at a time
+func newUSFMReader(r io.Reader, version string) nextVerse {
+	u, err := readUSFM(r, version)
+	if err != nil {
+		return func() (*Verse, int, error) { return nil, 0, err }
+	}
+	return func() (*Verse, int, error) {
+		if len(u.verses) == 0 {
+			return nil, 0, io.EOF
+		}
+		v, line := u.verses[0], u.lines[0]
+		u.verses, u.lines = u.verses[1:], u.lines[1:]
+		return v, line, nil
+	}
+}
+
+// Readers that hand out verses one at a time, with the line each started on
+type lineReader interface {
+	Next() (*Verse, error)
+	Line() int
+}
+
+func withLines(r lineReader) nextVerse {
+	return func() (*Verse, int, error) {
+		v, err := r.Next()
+		return v, r.Line(), err
+	}
+}
+
+func newUSXReader(r io.Reader, version string) nextVerse {
+	return withLines(NewUSXReader(r, version))
+}
+
+func newOSISReader(r io.Reader, version string) nextVerse {
+	return withLines(NewOSISReader(r, version))
+}
+
+func newZefaniaReader(r io.Reader, version string) nextVerse {
+	return withLines(NewZefaniaReader(r, version))
+}
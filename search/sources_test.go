@@ -0,0 +1,230 @@
+package biblescholar
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Every verse in data, and the lines of the rows that could not be read
+func readFormat(t *testing.T, format string, data string, version string) ([]*Verse, []int) {
+	next := verseFormats[format].Open(strings.NewReader(data), version)
+	var verses []*Verse
+	var errLines []int
+	for {
+		v, line, err := next()
+		if err == io.EOF {
+			return verses, errLines
+		}
+		if rowErr, ok := err.(*RowError); ok {
+			if rowErr.Line != line {
+				t.Errorf("%s: row error on line %d reported as line %d", format, rowErr.Line, line)
+			}
+			errLines = append(errLines, line)
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", format, err)
+		}
+		verses = append(verses, v)
+	}
+}
+
+func TestTSVReader(t *testing.T) {
+	data := "version\tbook\tchapter\tverse\ttext\n" +
+		"KJV\tJohn\t3\t16\tFor God so loved the world\n" +
+		"KJV\tJohn\t1\t38\tThey said unto him, \"Rabbi\"\n" +
+		"KJV\tJohn\t1\t39\t\"Come and see.\" They came\r\n" +
+		"KJV\tJohn\tx\t1\tBad chapter\n" +
+		"\n" +
+		"KJV\tJohn\t1\t40\tOne of the two\twith a tab\n"
+	verses, errLines := readFormat(t, "tsv", data, "")
+
+	expected := []string{
+		"For God so loved the world",
+		"They said unto him, \"Rabbi\"",
+		"\"Come and see.\" They came",
+		"One of the two\twith a tab",
+	}
+	if len(verses) != len(expected) {
+		t.Fatalf("Expected %d verses, got %d", len(expected), len(verses))
+	}
+	for i, text := range expected {
+		if verses[i].Text != text {
+			t.Errorf("Verse %d: expected text %q, got %q", i, text, verses[i].Text)
+		}
+	}
+	if verses[1].Ref() != (VerseRef{"John", 1, 38}) || verses[1].VersionBook != "KJV-John" {
+		t.Errorf("Unexpected verse: %+v", verses[1])
+	}
+	if len(errLines) != 1 || errLines[0] != 5 {
+		t.Errorf("Expected a row error on line 5, got %v", errLines)
+	}
+}
+
+func TestCSVReader(t *testing.T) {
+	data := "KJV,John,11,35,Jesus wept.\n" +
+		"KJV,John,1,38,\"They said unto him, \"\"Rabbi\"\"\"\n"
+	verses, errLines := readFormat(t, "csv", data, "")
+	if len(errLines) != 0 || len(verses) != 2 {
+		t.Fatalf("Expected 2 verses, got %d and row errors on lines %v", len(verses), errLines)
+	}
+	if verses[1].Text != "They said unto him, \"Rabbi\"" {
+		t.Errorf("Unexpected text: %q", verses[1].Text)
+	}
+}
+
+func TestJSONLinesReader(t *testing.T) {
+	data := `{"version": "WEB", "book": "John", "chapter": 3, "verse": 16, "text": "For God so loved the world"}
+{"book": "John", "chapter": 3, "verse": 17, "text": "For God didn't send his Son"}
+{"version": "WEB", "book": "", "chapter": 3, "verse": 18, "text": "No book"}
+{"version": "WEB", "book": "John", "chapter": 0, "verse": 18, "text": "Chapter 0"}
+{"version": "WEB", "book": "John", "chapter": 3, "text": "No verse"}
+{"version": "WEB", "book": "John", "chapter": 3, "verse": 19
+`
+	verses, errLines := readFormat(t, "jsonl", data, "KJV")
+	if len(verses) != 2 {
+		t.Fatalf("Expected 2 verses, got %d", len(verses))
+	}
+	if verses[1].Version != "KJV" || verses[1].VersionBook != "KJV-John" {
+		t.Errorf("Expected the version given for a verse without one, got %+v", verses[1])
+	}
+	expected := []int{3, 4, 5, 6}
+	if len(errLines) != len(expected) {
+		t.Fatalf("Expected row errors on lines %v, got %v", expected, errLines)
+	}
+	for i, line := range expected {
+		if errLines[i] != line {
+			t.Errorf("Expected row errors on lines %v, got %v", expected, errLines)
+			break
+		}
+	}
+
+	if _, errLines := readFormat(t, "jsonl", data, ""); len(errLines) != 5 || errLines[0] != 2 {
+		t.Errorf("Expected a row error for the verse without a version, got %v", errLines)
+	}
+}
+
+func TestSniffFormat(t *testing.T) {
+	cases := []struct {
+		data     string
+		expected string
+	}{
+		{usx3Fixture, "usx"},
+		{osisFixture, "osis"},
+		{zefaniaFixture, "zefania"},
+		{usfmFixture, "usfm"},
+		{`{"version": "WEB", "book": "John", "chapter": 3, "verse": 16, "text": "For God"}`, "jsonl"},
+		{"KJV\tJohn\t3\t16\tFor God so loved the world, that he gave\n", "tsv"},
+		{"KJV,John,3,16,For God\n", "csv"},
+		{"<html><body>", ""},
+		{"For God so loved the world\n", ""},
+	}
+	for _, c := range cases {
+		format, err := sniffFormat(bufio.NewReader(strings.NewReader(c.data)))
+		if c.expected == "" {
+			if err == nil {
+				t.Errorf("%q: expected an error, got %s", c.data, format)
+			}
+			continue
+		}
+		if err != nil || format != c.expected {
+			t.Errorf("%q: expected %s, got %s and %v", c.data, c.expected, format, err)
+		}
+	}
+}
+
+func TestOpenVerseSources(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sources")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write([]byte("KJV\tJohn\t3\t16\tFor God so loved the world\nKJV\tJohn\t3\n"))
+	w.Close()
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	for _, entry := range [][2]string{{"43JHN.usfm", usfmFixture}, {"psalms.xml", zefaniaFixture}, {"README", "Not verses"}} {
+		f, _ := zw.Create(entry[0])
+		f.Write([]byte(entry[1]))
+	}
+	zw.Close()
+	files := map[string][]byte{
+		"KJV.tsv.gz": gz.Bytes(),
+		"web.jsonl":  []byte(`{"version": "WEB", "book": "John", "chapter": 3, "verse": 16, "text": "For God"}`),
+		"more.zip":   archive.Bytes(),
+		"notes.txt":  []byte("Not verses"),
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sources, err := OpenVerseSources(dir, "", "WEB")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []struct {
+		name        string
+		format      string
+		compression string
+		verses      int
+		errLines    []int
+	}{
+		{"KJV.tsv.gz", "tsv", "gzip", 1, []int{2}},
+		{"more.zip:43JHN.usfm", "usfm", "", 6, nil},
+		{"more.zip:psalms.xml", "zefania", "", 2, nil},
+		{"web.jsonl", "jsonl", "", 1, nil},
+	}
+	if len(sources) != len(expected) {
+		t.Fatalf("Expected %d sources, got %d", len(expected), len(sources))
+	}
+	for i, e := range expected {
+		source := sources[i]
+		verses, errLines := 0, []int(nil)
+		for {
+			_, err := source.Next()
+			if err == io.EOF {
+				break
+			}
+			if rowErr, ok := err.(*RowError); ok {
+				if rowErr.Source != source.Info().Name {
+					t.Errorf("%s: expected row errors to name the source, got %s", e.name, rowErr.Source)
+				}
+				errLines = append(errLines, rowErr.Line)
+				continue
+			}
+			if err != nil {
+				t.Fatalf("%s: unexpected error: %v", e.name, err)
+			}
+			verses++
+		}
+		source.Close()
+
+		info := source.Info()
+		if info.Name != filepath.Join(dir, e.name) || info.Format != e.format || info.Compression != e.compression {
+			t.Errorf("Source %d: expected %s read as %s %s, got %+v", i, e.name, e.compression, e.format, info)
+		}
+		if verses != e.verses || len(errLines) != len(e.errLines) {
+			t.Errorf("%s: expected %d verses and row errors on lines %v, got %d and %v", e.name, e.verses, e.errLines, verses, errLines)
+		}
+		if info.Size == 0 || info.BytesRead != info.Size {
+			t.Errorf("%s: expected all %d bytes to be read, got %d", e.name, info.Size, info.BytesRead)
+		}
+	}
+
+	if _, err := OpenVerseSources(dir, "yaml", ""); err == nil {
+		t.Errorf("Expected an error for an unknown format")
+	}
+}
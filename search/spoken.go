@@ -0,0 +1,168 @@
+package biblescholar
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Understanding references the way Alexa transcribes them, e.g. "first corinthians thirteen four through seven"
+
+var (
+	unitWords = map[string]int{
+		"zero": 0, "one": 1, "two": 2, "three": 3, "four": 4, "five": 5, "six": 6, "seven": 7, "eight": 8, "nine": 9,
+		"ten": 10, "eleven": 11, "twelve": 12, "thirteen": 13, "fourteen": 14, "fifteen": 15, "sixteen": 16,
+		"seventeen": 17, "eighteen": 18, "nineteen": 19,
+	}
+	tensWords = map[string]int{
+		"twenty": 20, "thirty": 30, "forty": 40, "fifty": 50, "sixty": 60, "seventy": 70, "eighty": 80, "ninety": 90,
+	}
+	// Words that join the start and end of a range
+	rangeWords = map[string]bool{
+		"-": true, "through": true, "thru": true, "to": true, "until": true, "till": true,
+	}
+	// Words that only say what the next number is, e.g. "chapter three verse sixteen"
+	markerWords = map[string]bool{
+		":": true, "chapter": true, "chapters": true, "verse": true, "verses": true, "colon": true,
+	}
+	// Number words that can start a book name, e.g. "one john"
+	bookNumberWords = map[string]string{
+		"one": "1", "two": "2", "three": "3",
+	}
+	// Longest book name in words, e.g. "acts of the apostles"
+	maxBookNameWords = 4
+	spokenReplacer   = strings.NewReplacer(
+		":", " : ", "-", " - ", "–", " - ", "—", " - ", ",", " , ",
+		".", " ", "?", " ", "!", " ", "'", "",
+	)
+)
+
+// Parse a reference made of words, such as "john three sixteen", "psalm twenty three" or "1 john 1 9"
+// Returns a ReferenceError if the phrase is not entirely a reference, so callers can treat it as a search instead
+func ParseSpokenReference(s string) (ReferenceList, error) {
+	tokens := strings.Fields(strings.ToLower(spokenReplacer.Replace(s)))
+
+	for n := maxBookNameWords; n > 0; n-- {
+		if n >= len(tokens) {
+			continue
+		}
+		name := tokens[:n]
+		if digit, ok := bookNumberWords[name[0]]; ok && n > 1 {
+			name = append([]string{digit}, name[1:]...)
+		}
+		book, err := LookupBook(strings.Join(name, " "))
+		if err != nil {
+			continue
+		}
+		spec, err := spokenReferenceSpec(tokens[n:])
+		if err != nil {
+			continue
+		}
+		return ParseReference(fmt.Sprintf("%s %s", book.Name, spec))
+	}
+	return nil, &ReferenceError{s, "not a spoken reference"}
+}
+
+// Convert the chapter and verse words after a book name into written form, e.g. "three sixteen through eighteen" -> "3:16-18"
+// Two numbers in a row are a chapter and verse
+func spokenReferenceSpec(tokens []string) (string, error) {
+	var items []string
+	var sides []string
+	var nums []string
+
+	endSide := func() error {
+		switch len(nums) {
+		case 1:
+			sides = append(sides, nums[0])
+		case 2:
+			sides = append(sides, nums[0]+":"+nums[1])
+		default:
+			return fmt.Errorf("expected a chapter and verse")
+		}
+		nums = nil
+		return nil
+	}
+	endItem := func() error {
+		if err := endSide(); err != nil {
+			return err
+		}
+		if len(sides) > 2 {
+			return fmt.Errorf("too many parts in range")
+		}
+		items = append(items, strings.Join(sides, "-"))
+		sides = nil
+		return nil
+	}
+
+	for i := 0; i < len(tokens); {
+		t := tokens[i]
+		switch {
+		case markerWords[t]:
+			i++
+		case rangeWords[t]:
+			if err := endSide(); err != nil {
+				return "", err
+			}
+			i++
+		case t == "and" || t == ",":
+			if err := endItem(); err != nil {
+				return "", err
+			}
+			i++
+		default:
+			n, next, ok := parseNumberWords(tokens, i)
+			if !ok {
+				return "", fmt.Errorf("unexpected word '%s'", t)
+			}
+			nums = append(nums, strconv.Itoa(n))
+			i = next
+		}
+	}
+	if err := endItem(); err != nil {
+		return "", err
+	}
+	return strings.Join(items, ","), nil
+}
+
+// Read one number starting at tokens[i], written as digits or as words like "one hundred and nineteen"
+// Returns the number and the index of the first token after it
+// Adjacent numbers that do not combine are separate, so "three sixteen" is 3 followed by 16
+func parseNumberWords(tokens []string, i int) (int, int, bool) {
+	if n, err := strconv.Atoi(tokens[i]); err == nil {
+		return n, i + 1, true
+	}
+
+	n, start := 0, i
+	for i < len(tokens) {
+		t := tokens[i]
+		rest := n % 100
+		if v, ok := unitWords[t]; ok {
+			// "twenty" + "three", or anything after "hundred"
+			if rest != 0 && !(rest >= 20 && rest%10 == 0 && v < 10) {
+				break
+			}
+			n += v
+		} else if v, ok := tensWords[t]; ok {
+			if rest != 0 {
+				break
+			}
+			n += v
+		} else if t == "hundred" && i > start && n > 0 && n < 10 {
+			n *= 100
+		} else if t == "a" && i+1 < len(tokens) && tokens[i+1] == "hundred" && i == start {
+			n = 1
+		} else if t == "and" && n >= 100 && rest == 0 && i+1 < len(tokens) && isNumberWord(tokens[i+1]) {
+			// "one hundred and nineteen"
+		} else {
+			break
+		}
+		i++
+	}
+	return n, i, i > start
+}
+
+func isNumberWord(t string) bool {
+	_, unit := unitWords[t]
+	_, tens := tensWords[t]
+	return unit || tens
+}
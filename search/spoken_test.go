@@ -0,0 +1,55 @@
+package biblescholar
+
+import "testing"
+
+func TestParseSpokenReference(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected string
+	}{
+		{"john three sixteen", "John 3:16"},
+		{"John 3:16", "John 3:16"},
+		{"psalm twenty three", "Psalm 23"},
+		{"psalms one hundred and nineteen", "Psalm 119"},
+		{"psalm a hundred", "Psalm 100"},
+		{"1 john 1 9", "1 John 1:9"},
+		{"one john one nine", "1 John 1:9"},
+		{"first corinthians thirteen four through seven", "1 Corinthians 13:4-7"},
+		{"romans chapter eight verse twenty eight", "Romans 8:28"},
+		{"romans eight twenty eight to nine five", "Romans 8:28-9:5"},
+		{"john three sixteen and eighteen", "John 3:16; John 3:18"},
+		{"genesis one through three", "Genesis 1-3"},
+		{"song of solomon two", "Song of Songs 2"},
+		{"jude three", "Jude 1:3"},
+		{"John 3:16.", "John 3:16"},
+	}
+	for _, c := range cases {
+		refs, err := ParseSpokenReference(c.input)
+		if err != nil {
+			t.Errorf("ParseSpokenReference(%q): unexpected error: %v", c.input, err)
+			continue
+		}
+		if refs.String() != c.expected {
+			t.Errorf("ParseSpokenReference(%q): expected %s, got %s", c.input, c.expected, refs.String())
+		}
+	}
+}
+
+// Phrases that are searches rather than references
+func TestParseSpokenReferenceErrors(t *testing.T) {
+	cases := []string{
+		"",
+		"john",
+		"for god so loved the world",
+		"the lord is my shepherd",
+		"john three sixteen for god so loved",
+		"john three sixteen seventeen eighteen",
+		"john three to four to five",
+	}
+	for _, input := range cases {
+		refs, err := ParseSpokenReference(input)
+		if _, ok := err.(*ReferenceError); !ok {
+			t.Errorf("ParseSpokenReference(%q): expected a *ReferenceError, got %v and %v", input, refs, err)
+		}
+	}
+}
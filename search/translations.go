@@ -0,0 +1,66 @@
+package biblescholar
+
+import (
+	"strings"
+)
+
+// A translation of the Bible, as stored in the Version field of each verse
+type Translation struct {
+	// Abbreviation used as the version in the index, e.g. "ESV"
+	Abbreviation string
+	Name         string
+	// Other ways people say or write the name
+	Aliases []string
+}
+
+// Translations we know how to refer to by name
+// Versions in the index that are not listed here can still be referenced by abbreviation
+var Translations = []*Translation{
+	{"ESV", "English Standard Version", []string{"English Standard"}},
+	{"NIV", "New International Version", []string{"New International"}},
+	{"KJV", "King James Version", []string{"King James", "Authorized Version", "Authorised Version", "AV"}},
+	{"NLT", "New Living Translation", []string{"New Living"}},
+	{"HCSB", "Holman Christian Standard Bible", []string{"Holman Christian Standard", "Holman"}},
+	// German, searched by default for German speaking users
+	{"LUT", "Lutherbibel", []string{"Luther", "Luther Bibel", "Luther 1912", "Lutherübersetzung"}},
+	{"ELB", "Elberfelder Bibel", []string{"Elberfelder"}},
+	{"SCH", "Schlachter Bibel", []string{"Schlachter", "Schlachter 2000"}},
+}
+
+// Articles people put before a translation name
+var leadingArticles = map[string]bool{"the": true, "die": true, "der": true}
+
+// Reduce a translation name to a form that can be compared against the table
+// e.g. "the King James version" -> "kingjamesversion", "e. s. v." -> "esv", "die Lutherbibel" -> "lutherbibel"
+func translationKey(name string) string {
+	fields := strings.Fields(strings.ToLower(strings.Replace(name, ".", " ", -1)))
+	if len(fields) > 1 && leadingArticles[fields[0]] {
+		fields = fields[1:]
+	}
+	return strings.Join(fields, "")
+}
+
+// Find the version abbreviation for a written or spoken translation name
+// Unknown names are returned upper cased and without spaces, so abbreviations of unlisted versions still work
+func LookupTranslation(name string) string {
+	key := translationKey(name)
+	for _, t := range Translations {
+		for _, n := range append([]string{t.Abbreviation, t.Name}, t.Aliases...) {
+			nkey := translationKey(n)
+			if key == nkey || key == nkey+"translation" || key == nkey+"version" || key == nkey+"bible" {
+				return t.Abbreviation
+			}
+		}
+	}
+	return strings.ToUpper(key)
+}
+
+// Full name of a version, e.g. "KJV" -> "King James Version", or the abbreviation if it is not in the table
+func TranslationName(abbreviation string) string {
+	for _, t := range Translations {
+		if t.Abbreviation == abbreviation {
+			return t.Name
+		}
+	}
+	return abbreviation
+}
@@ -0,0 +1,314 @@
+package biblescholar
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// Reading books in USFM, the format most openly licensed Bibles are distributed in
+// https://ubsicap.github.io/usfm/
+
+// Book ids used in \id lines, mapped to the names in Books
+var usfmBookCodes = map[string]string{
+	"GEN": "Genesis", "EXO": "Exodus", "LEV": "Leviticus", "NUM": "Numbers", "DEU": "Deuteronomy",
+	"JOS": "Joshua", "JDG": "Judges", "RUT": "Ruth", "1SA": "1 Samuel", "2SA": "2 Samuel",
+	"1KI": "1 Kings", "2KI": "2 Kings", "1CH": "1 Chronicles", "2CH": "2 Chronicles", "EZR": "Ezra",
+	"NEH": "Nehemiah", "EST": "Esther", "JOB": "Job", "PSA": "Psalm", "PRO": "Proverbs",
+	"ECC": "Ecclesiastes", "SNG": "Song of Songs", "ISA": "Isaiah", "JER": "Jeremiah", "LAM": "Lamentations",
+	"EZK": "Ezekiel", "DAN": "Daniel", "HOS": "Hosea", "JOL": "Joel", "AMO": "Amos",
+	"OBA": "Obadiah", "JON": "Jonah", "MIC": "Micah", "NAM": "Nahum", "HAB": "Habakkuk",
+	"ZEP": "Zephaniah", "HAG": "Haggai", "ZEC": "Zechariah", "MAL": "Malachi",
+	"MAT": "Matthew", "MRK": "Mark", "LUK": "Luke", "JHN": "John", "ACT": "Acts",
+	"ROM": "Romans", "1CO": "1 Corinthians", "2CO": "2 Corinthians", "GAL": "Galatians", "EPH": "Ephesians",
+	"PHP": "Philippians", "COL": "Colossians", "1TH": "1 Thessalonians", "2TH": "2 Thessalonians", "1TI": "1 Timothy",
+	"2TI": "2 Timothy", "TIT": "Titus", "PHM": "Philemon", "HEB": "Hebrews", "JAS": "James",
+	"1PE": "1 Peter", "2PE": "2 Peter", "1JN": "1 John", "2JN": "2 John", "3JN": "3 John",
+	"JUD": "Jude", "REV": "Revelation",
+}
+
+// Markers whose content is left out of the verse text, up to their closing marker, e.g. "\f + \fr 3:16 \ft Or only\f*"
+var usfmNoteMarkers = map[string]bool{
+	// Footnotes and cross references
+	"f": true, "fe": true, "x": true, "ef": true, "ex": true,
+	// Figures, and alternate or published chapter and verse numbers
+	"fig": true, "ca": true, "va": true, "vp": true,
+}
+
+// Markers whose line is not verse text, e.g. headings, titles and introductions
+var usfmLineMarkers = map[string]bool{
+	"ide": true, "h": true, "toc": true, "toca": true, "rem": true, "sts": true, "usfm": true,
+	"mt": true, "mte": true, "ms": true, "mr": true, "s": true, "sr": true, "r": true, "d": true, "sp": true, "sd": true,
+	"cl": true, "cp": true, "cd": true, "periph": true,
+	"imt": true, "imte": true, "is": true, "ip": true, "ipi": true, "im": true, "imi": true, "ipq": true, "imq": true,
+	"ipr": true, "iq": true, "ib": true, "ili": true, "iot": true, "io": true, "iex": true, "ie": true,
+}
+
+// Line markers whose text is a section heading, kept apart from the verse text: sections, major sections, and psalm titles
+var usfmHeadingMarkers = map[string]bool{"s": true, "ms": true, "d": true}
+
+// Paragraph, poetry and table markers, which only separate words
+// Character markers such as \wj and \nd are dropped without adding a space, so "\nd Lord\nd*," stays "Lord,"
+var usfmBreakMarkers = map[string]bool{
+	"p": true, "m": true, "po": true, "pr": true, "cls": true, "pmo": true, "pm": true, "pmc": true, "pmr": true,
+	"pi": true, "mi": true, "nb": true, "pc": true, "ph": true, "b": true, "pb": true,
+	"q": true, "qr": true, "qc": true, "qa": true, "qm": true, "qd": true,
+	"lh": true, "li": true, "lf": true, "lim": true,
+	"tr": true, "th": true, "thr": true, "tc": true, "tcr": true,
+}
+
+// Builds verses from the markers and text of one book
+type usfmReader struct {
+	version string
+	verses  []*Verse
+	// Line each verse started on
+	lines []int
+
+	line    int
+	book    string
+	chapter int
+	verse   int
+	// Line the verse being read started on
+	verseLine int
+	text      []string
+	// Open footnotes, cross references and the like
+	notes int
+	// Set after a heading or title marker, until the end of the line
+	skipLine bool
+	// Text of the heading being read, and headings waiting for the next verse
+	inHeading bool
+	heading   []string
+	headings  []string
+	// Headings before the verse being read
+	verseHeading string
+	// Marker waiting for its number, e.g. "c" or "v", or the book code after "id"
+	pending string
+}
+
+// Read the verses of a USFM file, usually one book
+// USFM does not name the translation, so the version is given; verses with no text, e.g. ones a translation leaves out, are skipped
+func ReadUSFM(r io.Reader, version string) ([]*Verse, error) {
+	u, err := readUSFM(r, version)
+	if err != nil {
+		return nil, err
+	}
+	return u.verses, nil
+}
+
+func readUSFM(r io.Reader, version string) (*usfmReader, error) {
+	if version == "" {
+		return nil, fmt.Errorf("USFM files do not name their translation; a version is required")
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	u := &usfmReader{version: version, line: 1}
+	if err := u.parse(string(data)); err != nil {
+		return nil, fmt.Errorf("Line %d: %v", u.line, err)
+	}
+	return u, nil
+}
+
+// Split the file into markers, text and line breaks
+func (u *usfmReader) parse(s string) error {
+	for i := 0; i < len(s); {
+		switch s[i] {
+		case '\n':
+			u.endLine()
+			u.line++
+			i++
+		case '\\':
+			j := i + 1
+			for j < len(s) && (isUSFMMarkerChar(s[j])) {
+				j++
+			}
+			closing := j < len(s) && s[j] == '*'
+			name := s[i+1 : j]
+			if closing {
+				j++
+			} else if j < len(s) && (s[j] == ' ' || s[j] == '\t') {
+				// The space after an opening marker is part of the marker
+				j++
+			}
+			if err := u.marker(name, closing); err != nil {
+				return err
+			}
+			i = j
+		default:
+			j := i
+			for j < len(s) && s[j] != '\\' && s[j] != '\n' {
+				j++
+			}
+			if err := u.addText(s[i:j]); err != nil {
+				return err
+			}
+			i = j
+		}
+	}
+	u.endVerse()
+	return nil
+}
+
+func isUSFMMarkerChar(c byte) bool {
+	return c == '+' || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9')
+}
+
+// Numbered markers such as \q2 and \s1 act like \q and \s; nested character markers such as \+w act like \w
+func usfmBaseMarker(name string) string {
+	return strings.TrimRight(strings.TrimPrefix(name, "+"), "0123456789")
+}
+
+func (u *usfmReader) marker(name string, closing bool) error {
+	base := usfmBaseMarker(name)
+	if usfmNoteMarkers[base] {
+		if closing {
+			if u.notes > 0 {
+				u.notes--
+			}
+		} else {
+			u.notes++
+		}
+		return nil
+	}
+	if closing || u.notes > 0 {
+		return nil
+	}
+
+	switch {
+	case base == "id" || base == "c" || base == "v":
+		u.endHeading()
+		u.endVerse()
+		u.pending = base
+		u.skipLine = false
+	case usfmLineMarkers[base]:
+		u.endHeading()
+		u.skipLine = true
+		u.inHeading = usfmHeadingMarkers[base]
+	case usfmBreakMarkers[base]:
+		u.endHeading()
+		u.skipLine = false
+		u.text = append(u.text, " ")
+	}
+	return nil
+}
+
+func (u *usfmReader) addText(txt string) error {
+	if u.notes > 0 {
+		return nil
+	}
+	if u.pending != "" {
+		fields := strings.Fields(txt)
+		if len(fields) == 0 {
+			return nil
+		}
+		marker := u.pending
+		u.pending = ""
+		if err := u.setNumber(marker, fields[0]); err != nil {
+			return err
+		}
+		if marker == "id" {
+			// The rest of the line describes the file
+			u.skipLine = true
+			return nil
+		}
+		txt = strings.TrimLeft(txt, " \t")[len(fields[0]):]
+	}
+	// Word level attributes follow a "|", e.g. "\w grace|strong="H2580"\w*"
+	if i := strings.Index(txt, "|"); i != -1 {
+		txt = txt[:i]
+	}
+	switch {
+	case u.inHeading:
+		u.heading = append(u.heading, txt)
+	case !u.skipLine && u.verse != 0:
+		u.text = append(u.text, txt)
+	}
+	return nil
+}
+
+// Book, chapter or verse from the text after \id, \c or \v
+func (u *usfmReader) setNumber(marker string, value string) error {
+	if marker == "id" {
+		book, ok := usfmBookCodes[strings.ToUpper(value)]
+		if !ok {
+			return fmt.Errorf("Unknown USFM book id: '%s'", value)
+		}
+		u.book, u.chapter, u.verse = book, 0, 0
+		u.headings = nil
+		return nil
+	}
+
+	// Bridged verses, e.g. "\v 16-17", are stored under the first verse; parts, e.g. "3a" and "3b", are joined
+	number := value
+	if digits := strings.IndexFunc(value, func(r rune) bool { return r < '0' || r > '9' }); digits != -1 {
+		number = value[:digits]
+	}
+	n, err := strconv.Atoi(number)
+	if err != nil || n < 1 {
+		return fmt.Errorf("Invalid \\%s number: '%s'", marker, value)
+	}
+
+	switch marker {
+	case "c":
+		if u.book == "" {
+			return fmt.Errorf("Chapter before the \\id line")
+		}
+		u.chapter, u.verse = n, 0
+	case "v":
+		if u.chapter == 0 {
+			return fmt.Errorf("Verse before the first chapter")
+		}
+		u.verse, u.verseLine = n, u.line
+		u.verseHeading = joinHeadings(u.headings)
+		u.headings = nil
+	}
+	return nil
+}
+
+func (u *usfmReader) endLine() {
+	u.endHeading()
+	u.skipLine = false
+	u.text = append(u.text, " ")
+}
+
+// A heading ends with its line, or at the next paragraph, chapter or verse
+func (u *usfmReader) endHeading() {
+	if !u.inHeading {
+		return
+	}
+	u.inHeading = false
+	if txt := strings.Join(strings.Fields(strings.Join(u.heading, "")), " "); txt != "" {
+		u.headings = append(u.headings, txt)
+	}
+	u.heading = nil
+}
+
+// Add the verse being read, if it has any text
+func (u *usfmReader) endVerse() {
+	if u.verse != 0 {
+		txt := strings.Replace(strings.Join(u.text, ""), "//", " ", -1)
+		txt = strings.Join(strings.Fields(strings.Replace(txt, "~", " ", -1)), " ")
+		last := len(u.verses) - 1
+		switch {
+		case txt == "":
+		case last >= 0 && u.verses[last].Book == u.book && u.verses[last].Chapter == u.chapter && u.verses[last].Verse == u.verse:
+			u.verses[last].Text += " " + txt
+		default:
+			u.verses = append(u.verses, &Verse{
+				Version:     u.version,
+				Book:        u.book,
+				Chapter:     u.chapter,
+				Verse:       u.verse,
+				Text:        txt,
+				VersionBook: fmt.Sprintf("%s-%s", u.version, u.book),
+				Heading:     u.verseHeading,
+			})
+			u.lines = append(u.lines, u.verseLine)
+		}
+	}
+	u.verse = 0
+	u.text = nil
+}
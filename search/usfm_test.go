@@ -0,0 +1,93 @@
+package biblescholar
+
+import (
+	"strings"
+	"testing"
+)
+
+const usfmFixture = `\id JHN 43-JHN-web.sfm World English Bible (WEB)
+\ide UTF-8
+\h John
+\toc1 The Good News According to John
+\mt1 The Good News According to John
+\c 3
+\ms1 Book One
+\s1 Jesus teaches \nd Nicodemus\nd*
+\p
+\v 1 Now there was a man of the Pharisees named Nicodemus, a ruler of the Jews.
+\v 2 He came to Jesus\f + \fr 3:2 \ft Some manuscripts say "him"\f* by night, and said to him,
+\wj “Rabbi,\wj* we know
+\q1 that you are a teacher come from God,
+\q2 for no one can do these signs\x - \xo 3:2 \xt John 9:16\x* that you do,
+\v 3 \w Jesus|strong="G2424"\w* \w answered|lemma="ἀποκρίνομαι" strong="G0611"\w* him,
+\s The Son Lifted Up
+\p
+\v 16-17 \wj For God so loved the \+w world|strong="G2889"\+w*,\wj* that he gave his only~born Son.
+\v 18a First part.
+\v 18b Second // part.
+\c 4
+\d A heading-like title
+\p
+\v 1 Therefore when the \nd Lord\nd* knew
+\v 2
+`
+
+func TestReadUSFM(t *testing.T) {
+	u, err := readUSFM(strings.NewReader(usfmFixture), "WEB")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []struct {
+		ref     VerseRef
+		line    int
+		text    string
+		heading string
+	}{
+		{VerseRef{"John", 3, 1}, 10, "Now there was a man of the Pharisees named Nicodemus, a ruler of the Jews.", "Book One; Jesus teaches Nicodemus"},
+		{VerseRef{"John", 3, 2}, 11, "He came to Jesus by night, and said to him, “Rabbi, we know that you are a teacher come from God, for no one can do these signs that you do,", ""},
+		{VerseRef{"John", 3, 3}, 15, "Jesus answered him,", ""},
+		{VerseRef{"John", 3, 16}, 18, "For God so loved the world, that he gave his only born Son.", "The Son Lifted Up"},
+		{VerseRef{"John", 3, 18}, 19, "First part. Second part.", ""},
+		{VerseRef{"John", 4, 1}, 24, "Therefore when the Lord knew", "A heading-like title"},
+	}
+	if len(u.verses) != len(expected) {
+		for _, v := range u.verses {
+			t.Log(v.Ref(), v.Text)
+		}
+		t.Fatalf("Expected %d verses, got %d", len(expected), len(u.verses))
+	}
+	for i, e := range expected {
+		v := u.verses[i]
+		if v.Ref() != e.ref || v.Text != e.text || v.Heading != e.heading {
+			t.Errorf("Verse %d: expected %s %q with heading %q, got %s %q with heading %q", i, e.ref, e.text, e.heading, v.Ref(), v.Text, v.Heading)
+		}
+		if u.lines[i] != e.line {
+			t.Errorf("Verse %d: expected line %d, got %d", i, e.line, u.lines[i])
+		}
+		if v.Version != "WEB" || v.VersionBook != "WEB-John" {
+			t.Errorf("Verse %d: unexpected version %s and %s", i, v.Version, v.VersionBook)
+		}
+	}
+}
+
+func TestReadUSFMErrors(t *testing.T) {
+	cases := []struct {
+		name    string
+		data    string
+		version string
+		err     string
+	}{
+		{"no version", usfmFixture, "", "USFM files do not name their translation; a version is required"},
+		{"unknown book", "\\id XYZ\n\\c 1\n", "WEB", "Line 1: Unknown USFM book id: 'XYZ'"},
+		{"chapter before id", "\\c 1\n\\v 1 In the beginning\n", "WEB", "Line 1: Chapter before the \\id line"},
+		{"verse before chapter", "\\id GEN\n\\v 1 In the beginning\n", "WEB", "Line 2: Verse before the first chapter"},
+		{"bad chapter", "\\id GEN\n\\c one\n", "WEB", "Line 2: Invalid \\c number: 'one'"},
+		{"bad verse", "\\id GEN\n\\c 1\n\\v 0 In the beginning\n", "WEB", "Line 3: Invalid \\v number: '0'"},
+	}
+	for _, c := range cases {
+		_, err := ReadUSFM(strings.NewReader(c.data), c.version)
+		if err == nil || err.Error() != c.err {
+			t.Errorf("%s: expected the error %q, got %v", c.name, c.err, err)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package biblescholar
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Reading USX, the XML form of USFM
+// https://ubsicap.github.io/usx/
+// Styles are USFM markers, so the USFM tables decide what is verse text
+
+// Paragraph styles kept as headings; other styles in usfmLineMarkers are left out
+var usxHeadingStyles = map[string]bool{"s": true, "ms": true, "d": true}
+
+// What an open element means for the text inside it
+const (
+	usxPlain int = iota
+	// Notes, figures, and paragraphs such as titles and introductions
+	usxSkipped
+	usxHeading
+)
+
+// Reads verses one at a time, for both USX 2, where a verse runs until the next one, and USX 3, with verse end milestones
+type USXReader struct {
+	dec      *xml.Decoder
+	c        *verseCollector
+	elements []int
+	skipped  int
+	done     bool
+	line     int
+
+	book    string
+	chapter int
+}
+
+// USX does not name the translation, so the version is required
+func NewUSXReader(r io.Reader, version string) *USXReader {
+	return &USXReader{
+		dec: xml.NewDecoder(r),
+		c:   &verseCollector{version: version},
+	}
+}
+
+// Next verse in the file, or io.EOF after the last one
+func (u *USXReader) Next() (*Verse, error) {
+	if u.c.version == "" {
+		return nil, fmt.Errorf("USX files do not name their translation; a version is required")
+	}
+	for {
+		if v, line := u.c.next(); v != nil {
+			u.line = line
+			return v, nil
+		}
+		if u.done {
+			return nil, io.EOF
+		}
+
+		tok, err := u.dec.Token()
+		if err == io.EOF {
+			u.c.end()
+			u.done = true
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := u.handle(tok); err != nil {
+			line, _ := u.dec.InputPos()
+			return nil, fmt.Errorf("Line %d: %v", line, err)
+		}
+	}
+}
+
+// Line the last verse read started on
+func (u *USXReader) Line() int {
+	return u.line
+}
+
+func (u *USXReader) handle(tok xml.Token) error {
+	switch el := tok.(type) {
+	case xml.StartElement:
+		kind := usxPlain
+		style := usfmBaseMarker(attr(el, "style"))
+		switch name := el.Name.Local; {
+		case u.skipped > 0:
+			kind = usxSkipped
+		case name == "book":
+			book, ok := usfmBookCodes[strings.ToUpper(attr(el, "code"))]
+			if !ok {
+				return fmt.Errorf("Unknown USX book code: '%s'", attr(el, "code"))
+			}
+			u.c.end()
+			u.book, u.chapter = book, 0
+			// The content describes the file
+			kind = usxSkipped
+		case name == "chapter":
+			u.c.end()
+			if attr(el, "eid") != "" {
+				break
+			}
+			n, err := strconv.Atoi(attr(el, "number"))
+			if err != nil || n < 1 {
+				return fmt.Errorf("Invalid chapter number: '%s'", attr(el, "number"))
+			}
+			if u.book == "" {
+				return fmt.Errorf("Chapter before the book element")
+			}
+			u.chapter = n
+		case name == "verse":
+			if attr(el, "eid") != "" {
+				u.c.end()
+				break
+			}
+			if err := u.startVerse(attr(el, "number")); err != nil {
+				return err
+			}
+		case name == "note" || name == "figure" || name == "sidebar" || usfmNoteMarkers[style]:
+			kind = usxSkipped
+		case name == "para" && usxHeadingStyles[style]:
+			kind = usxHeading
+		case name == "para" && usfmLineMarkers[style]:
+			kind = usxSkipped
+		case name == "para" || name == "optbreak" || name == "table" || name == "row" || name == "cell":
+			u.c.addText(" ")
+		}
+
+		switch kind {
+		case usxSkipped:
+			u.skipped++
+		case usxHeading:
+			u.c.startHeading()
+		}
+		u.elements = append(u.elements, kind)
+
+	case xml.EndElement:
+		if len(u.elements) == 0 {
+			return nil
+		}
+		kind := u.elements[len(u.elements)-1]
+		u.elements = u.elements[:len(u.elements)-1]
+		switch kind {
+		case usxSkipped:
+			u.skipped--
+		case usxHeading:
+			u.c.endHeading()
+		default:
+			if el.Name.Local == "para" {
+				u.c.addText(" ")
+			}
+		}
+
+	case xml.CharData:
+		if u.skipped == 0 {
+			u.c.addText(string(el))
+		}
+	}
+	return nil
+}
+
+// Bridged verses, e.g. "16-17", are stored under the first verse
+func (u *USXReader) startVerse(number string) error {
+	u.c.end()
+	if i := strings.IndexFunc(number, func(r rune) bool { return r < '0' || r > '9' }); i != -1 {
+		number = number[:i]
+	}
+	n, err := strconv.Atoi(number)
+	if err != nil || n < 1 {
+		return fmt.Errorf("Invalid verse number: '%s'", number)
+	}
+	if u.chapter == 0 {
+		return fmt.Errorf("Verse before the first chapter")
+	}
+	line, _ := u.dec.InputPos()
+	u.c.start(u.book, u.chapter, n, line)
+	return nil
+}
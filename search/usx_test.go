@@ -0,0 +1,72 @@
+package biblescholar
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+// USX 3, with verse end milestones
+const usx3Fixture = `<?xml version="1.0" encoding="utf-8"?>
+<usx version="3.0">
+  <book code="JHN" style="id">World English Bible</book>
+  <para style="h">John</para>
+  <para style="mt1">The Good News According to John</para>
+  <chapter number="3" style="c" sid="JHN 3" />
+  <para style="ms">Book One</para>
+  <para style="s1">Jesus and <char style="nd">Nicodemus</char></para>
+  <para style="p"><verse number="16" style="v" sid="JHN 3:16" /><char style="wj">For God so <char style="w" strong="G25">loved</char> the world,</char><note caller="+" style="f"><char style="ft">note</char></note> that he gave his only born Son.<verse eid="JHN 3:16" />
+  <verse number="17-18" style="v" sid="JHN 3:17-18" />For God didn't send his Son<verse eid="JHN 3:17-18" /></para>
+  <chapter eid="JHN 3" />
+</usx>
+`
+
+// USX 2, where a verse runs until the next one
+const usx2Fixture = `<usx version="2.5">
+  <book code="PSA" style="id"/>
+  <chapter number="23" style="c"/>
+  <para style="d">A Psalm by David.</para>
+  <para style="q1"><verse number="1" style="v"/>Yahweh is my shepherd:</para>
+  <para style="q2">I shall lack nothing.</para>
+  <para style="s">Green pastures</para>
+  <para style="q1"><verse number="2" style="v"/>He makes me lie down in green pastures.</para>
+</usx>
+`
+
+func TestUSXReader(t *testing.T) {
+	verses, lines := readAllVerses(t, NewUSXReader(strings.NewReader(usx3Fixture), "WEB"))
+	checkVerses(t, "WEB", verses, lines, []expectedVerse{
+		{VerseRef{"John", 3, 16}, 9, "For God so loved the world, that he gave his only born Son.", "Book One; Jesus and Nicodemus"},
+		{VerseRef{"John", 3, 17}, 10, "For God didn't send his Son", ""},
+	})
+
+	verses, lines = readAllVerses(t, NewUSXReader(strings.NewReader(usx2Fixture), "WEB"))
+	checkVerses(t, "WEB", verses, lines, []expectedVerse{
+		{VerseRef{"Psalm", 23, 1}, 5, "Yahweh is my shepherd: I shall lack nothing.", "A Psalm by David."},
+		{VerseRef{"Psalm", 23, 2}, 8, "He makes me lie down in green pastures.", "Green pastures"},
+	})
+}
+
+func TestUSXReaderErrors(t *testing.T) {
+	cases := []struct {
+		name    string
+		data    string
+		version string
+	}{
+		{"no version", usx2Fixture, ""},
+		{"unknown book", `<usx><book code="XYZ" style="id"/></usx>`, "WEB"},
+		{"bad chapter", `<usx><book code="PSA" style="id"/><chapter number="0" style="c"/></usx>`, "WEB"},
+		{"bad verse", `<usx><book code="PSA" style="id"/><chapter number="1" style="c"/><para style="p"><verse number="x" style="v"/>x</para></usx>`, "WEB"},
+		{"verse before chapter", `<usx><book code="PSA" style="id"/><para style="p"><verse number="1" style="v"/>x</para></usx>`, "WEB"},
+	}
+	for _, c := range cases {
+		r := NewUSXReader(strings.NewReader(c.data), c.version)
+		var err error
+		for err == nil {
+			_, err = r.Next()
+		}
+		if err == io.EOF {
+			t.Errorf("%s: expected an error", c.name)
+		}
+	}
+}
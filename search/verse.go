@@ -3,6 +3,7 @@ package biblescholar
 import (
 	"fmt"
 	"strconv"
+	"strings"
 )
 
 type Verse struct {
@@ -14,12 +15,19 @@ type Verse struct {
 	// Create a string that includes version and book
 	// We save this field because we can't do nested aggregations, but we do want to get book per version for visualizations
 	VersionBook string
+	// Section headings just before this verse, e.g. "The Beatitudes", from formats that have them; kept out of Text
+	Heading string `json:",omitempty"`
 }
 
 func (v *Verse) Id() string {
 	return fmt.Sprintf("%s-%d-%d-%s", v.Book, v.Chapter, v.Verse, v.Version)
 }
 
+// Location of this verse, ignoring version
+func (v *Verse) Ref() VerseRef {
+	return VerseRef{v.Book, v.Chapter, v.Verse}
+}
+
 // Implementation so that items of this type are bound to the correct mapping
 // https://godoc.org/github.com/blevesearch/bleve/mapping#Classifier
 // https://github.com/blevesearch/bleve/blob/v0.5.0/index.go#L87
@@ -27,10 +35,19 @@ func (v *Verse) Type() string {
 	return "verse"
 }
 
-// From a line in a tsv/csv
-func NewVerseFromLine(line []string) *Verse {
-	chapter, _ := strconv.Atoi(line[2])
-	verse, _ := strconv.Atoi(line[3])
+// From a line in a tsv/csv: <version> <book> <chapter> <verse> <text>
+func NewVerseFromLine(line []string) (*Verse, error) {
+	if len(line) < 5 {
+		return nil, fmt.Errorf("Expected 5 columns, found %d", len(line))
+	}
+	chapter, err := strconv.Atoi(strings.TrimSpace(line[2]))
+	if err != nil || chapter < 1 {
+		return nil, fmt.Errorf("Invalid chapter number: '%s'", line[2])
+	}
+	verse, err := strconv.Atoi(strings.TrimSpace(line[3]))
+	if err != nil || verse < 1 {
+		return nil, fmt.Errorf("Invalid verse number: '%s'", line[3])
+	}
 
 	return &Verse{
 		Version:     line[0],
@@ -39,5 +56,23 @@ func NewVerseFromLine(line []string) *Verse {
 		Verse:       verse,
 		Text:        line[4],
 		VersionBook: fmt.Sprintf("%s-%s", line[0], line[1]),
+	}, nil
+}
+
+// From the stored fields of a search hit
+// Numeric fields come back from bleve as float64
+func NewVerseFromFields(fields map[string]interface{}) *Verse {
+	v := &Verse{}
+	v.Version, _ = fields["Version"].(string)
+	v.Book, _ = fields["Book"].(string)
+	v.Text, _ = fields["Text"].(string)
+	v.Heading, _ = fields["Heading"].(string)
+	if chapter, ok := fields["Chapter"].(float64); ok {
+		v.Chapter = int(chapter)
+	}
+	if verse, ok := fields["Verse"].(float64); ok {
+		v.Verse = int(verse)
 	}
+	v.VersionBook = fmt.Sprintf("%s-%s", v.Version, v.Book)
+	return v
 }
@@ -0,0 +1,118 @@
+package biblescholar
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// Shared pieces of the XML formats, OSIS, USX and Zefania
+
+// Builds verses from text read between verse starts and ends
+// Verses are handed out in the order they end; headings wait for the verse after them, and are set as its Heading
+type verseCollector struct {
+	version string
+	// Verse being read, or nil between verses
+	current *Verse
+	line    int
+	text    []string
+	ready   []*Verse
+	// Line each ready verse started on
+	readyLines []int
+
+	// Heading being read, or nil
+	heading []string
+	inHead  bool
+	pending []string
+}
+
+// The line is where the verse starts in the file, for error reports
+func (c *verseCollector) start(book string, chapter int, verse int, line int) {
+	c.end()
+	c.line = line
+	c.current = &Verse{
+		Version:     c.version,
+		Book:        book,
+		Chapter:     chapter,
+		Verse:       verse,
+		VersionBook: fmt.Sprintf("%s-%s", c.version, book),
+		Heading:     joinHeadings(c.pending),
+	}
+	c.pending = nil
+}
+
+// Text of the current verse or heading; ignored between verses
+func (c *verseCollector) addText(txt string) {
+	switch {
+	case c.inHead:
+		c.heading = append(c.heading, txt)
+	case c.current != nil:
+		c.text = append(c.text, txt)
+	}
+}
+
+// Verses with no text, e.g. ones a translation leaves out, are skipped
+func (c *verseCollector) end() {
+	if c.current == nil {
+		return
+	}
+	c.current.Text = strings.Join(strings.Fields(strings.Join(c.text, "")), " ")
+	if c.current.Text != "" {
+		c.ready = append(c.ready, c.current)
+		c.readyLines = append(c.readyLines, c.line)
+	}
+	c.current = nil
+	c.text = nil
+}
+
+func (c *verseCollector) startHeading() {
+	c.inHead = true
+	c.heading = nil
+}
+
+func (c *verseCollector) endHeading() {
+	c.inHead = false
+	if txt := strings.Join(strings.Fields(strings.Join(c.heading, "")), " "); txt != "" {
+		c.pending = append(c.pending, txt)
+	}
+	c.heading = nil
+}
+
+// Several headings before one verse, e.g. a major section and a section, are kept in order
+func joinHeadings(headings []string) string {
+	return strings.Join(headings, "; ")
+}
+
+// Next finished verse, if any, and the line it started on
+func (c *verseCollector) next() (*Verse, int) {
+	if len(c.ready) == 0 {
+		return nil, 0
+	}
+	v, line := c.ready[0], c.readyLines[0]
+	c.ready, c.readyLines = c.ready[1:], c.readyLines[1:]
+	return v, line
+}
+
+func attr(el xml.StartElement, name string) string {
+	for _, a := range el.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// Name of the first element in the start of an XML document, e.g. "osis" or "usx"
+func xmlRootElement(head []byte) (string, error) {
+	dec := xml.NewDecoder(bytes.NewReader(head))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return "", fmt.Errorf("No root element found")
+		}
+		if el, ok := tok.(xml.StartElement); ok {
+			return el.Name.Local, nil
+		}
+	}
+}
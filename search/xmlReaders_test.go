@@ -0,0 +1,75 @@
+package biblescholar
+
+import (
+	"io"
+	"testing"
+)
+
+type expectedVerse struct {
+	ref     VerseRef
+	line    int
+	text    string
+	heading string
+}
+
+// Read every verse, failing on the first error
+func readAllVerses(t *testing.T, r lineReader) ([]*Verse, []int) {
+	var verses []*Verse
+	var lines []int
+	for {
+		v, err := r.Next()
+		if err == io.EOF {
+			return verses, lines
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		verses = append(verses, v)
+		lines = append(lines, r.Line())
+	}
+}
+
+func checkVerses(t *testing.T, version string, verses []*Verse, lines []int, expected []expectedVerse) {
+	if len(verses) != len(expected) {
+		for _, v := range verses {
+			t.Log(v.Ref(), v.Text)
+		}
+		t.Fatalf("Expected %d verses, got %d", len(expected), len(verses))
+	}
+	for i, e := range expected {
+		v := verses[i]
+		if v.Ref() != e.ref || v.Text != e.text || v.Heading != e.heading {
+			t.Errorf("Verse %d: expected %s %q with heading %q, got %s %q with heading %q", i, e.ref, e.text, e.heading, v.Ref(), v.Text, v.Heading)
+		}
+		if lines[i] != e.line {
+			t.Errorf("Verse %d: expected line %d, got %d", i, e.line, lines[i])
+		}
+		if v.Version != version || v.VersionBook != version+"-"+v.Book {
+			t.Errorf("Verse %d: expected version %s, got %s and %s", i, version, v.Version, v.VersionBook)
+		}
+	}
+}
+
+func TestXMLRootElement(t *testing.T) {
+	cases := []struct {
+		head     string
+		expected string
+	}{
+		{`<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<osis xmlns="http://www.bibletechnologies.net/2003/OSIS/namespace">`, "osis"},
+		{`<?xml version="1.0"?><!-- A comment --><usx version="3.0"><book code="JHN"`, "usx"},
+		{`<XMLBIBLE biblename="KJV"><INFORMATION>`, "XMLBIBLE"},
+		{`<?xml version="1.0"?>`, ""},
+	}
+	for _, c := range cases {
+		root, err := xmlRootElement([]byte(c.head))
+		if c.expected == "" {
+			if err == nil {
+				t.Errorf("%q: expected an error, got %s", c.head, root)
+			}
+			continue
+		}
+		if err != nil || root != c.expected {
+			t.Errorf("%q: expected %s, got %s and %v", c.head, c.expected, root, err)
+		}
+	}
+}
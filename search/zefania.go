@@ -0,0 +1,211 @@
+package biblescholar
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Reading Zefania XML, used by many freely available Bible modules
+// https://sourceforge.net/projects/zefania-sharp/
+
+// Elements whose text is not verse text: notes, cross references, and descriptions of the book or chapter
+var zefaniaSkippedElements = map[string]bool{
+	"NOTE": true, "XREF": true, "DIV": true, "PROLOG": true, "REMARK": true, "MEDIA": true,
+}
+
+// What an open element means for the text inside it
+const (
+	zefaniaPlain int = iota
+	zefaniaSkipped
+	// Description of the work, skipped apart from its identifier
+	zefaniaInformation
+	zefaniaIdentifier
+	zefaniaHeading
+	zefaniaVerse
+)
+
+// Reads verses one at a time
+type ZefaniaReader struct {
+	dec        *xml.Decoder
+	c          *verseCollector
+	elements   []int
+	skipped    int
+	done       bool
+	line       int
+	identifier []string
+
+	// Empty for books outside the canon in Books, whose verses are skipped
+	book    string
+	chapter int
+}
+
+// The version is taken from the identifier in the INFORMATION element; the one given is used if the file does not name one
+// Books are numbered as in Books, 1 for Genesis to 66 for Revelation
+func NewZefaniaReader(r io.Reader, version string) *ZefaniaReader {
+	return &ZefaniaReader{
+		dec: xml.NewDecoder(r),
+		c:   &verseCollector{version: version},
+	}
+}
+
+// Next verse in the file, or io.EOF after the last one
+func (z *ZefaniaReader) Next() (*Verse, error) {
+	for {
+		if v, line := z.c.next(); v != nil {
+			z.line = line
+			return v, nil
+		}
+		if z.done {
+			return nil, io.EOF
+		}
+
+		tok, err := z.dec.Token()
+		if err == io.EOF {
+			z.c.end()
+			z.done = true
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := z.handle(tok); err != nil {
+			line, _ := z.dec.InputPos()
+			return nil, fmt.Errorf("Line %d: %v", line, err)
+		}
+	}
+}
+
+// Line the last verse read started on
+func (z *ZefaniaReader) Line() int {
+	return z.line
+}
+
+func (z *ZefaniaReader) handle(tok xml.Token) error {
+	switch el := tok.(type) {
+	case xml.StartElement:
+		kind := zefaniaPlain
+		switch name := el.Name.Local; {
+		case name == "identifier" && z.parent() == zefaniaInformation:
+			kind = zefaniaIdentifier
+		case z.skipped > 0:
+			kind = zefaniaSkipped
+		case name == "INFORMATION":
+			kind = zefaniaInformation
+		case name == "BIBLEBOOK":
+			if err := z.startBook(attr(el, "bnumber")); err != nil {
+				return err
+			}
+		case name == "CHAPTER":
+			z.c.end()
+			n, err := strconv.Atoi(attr(el, "cnumber"))
+			if err != nil || n < 1 {
+				return fmt.Errorf("Invalid chapter number: '%s'", attr(el, "cnumber"))
+			}
+			z.chapter = n
+		case name == "VERS":
+			kind = zefaniaVerse
+			if err := z.startVerse(attr(el, "vnumber")); err != nil {
+				return err
+			}
+		case name == "CAPTION":
+			kind = zefaniaHeading
+		case zefaniaSkippedElements[name]:
+			kind = zefaniaSkipped
+		case name == "BR":
+			z.c.addText(" ")
+		}
+
+		switch kind {
+		case zefaniaSkipped, zefaniaInformation:
+			z.skipped++
+		case zefaniaHeading:
+			z.c.startHeading()
+		case zefaniaIdentifier:
+			z.identifier = nil
+		}
+		z.elements = append(z.elements, kind)
+
+	case xml.EndElement:
+		if len(z.elements) == 0 {
+			return nil
+		}
+		kind := z.elements[len(z.elements)-1]
+		z.elements = z.elements[:len(z.elements)-1]
+		switch kind {
+		case zefaniaSkipped, zefaniaInformation:
+			z.skipped--
+		case zefaniaHeading:
+			z.c.endHeading()
+		case zefaniaVerse:
+			z.c.end()
+		case zefaniaIdentifier:
+			if id := strings.TrimSpace(strings.Join(z.identifier, "")); id != "" {
+				z.c.version = id
+			}
+		}
+
+	case xml.CharData:
+		switch {
+		case z.parent() == zefaniaIdentifier:
+			z.identifier = append(z.identifier, string(el))
+		case z.skipped == 0:
+			z.c.addText(string(el))
+		}
+	}
+	return nil
+}
+
+// Kind of the innermost open element
+func (z *ZefaniaReader) parent() int {
+	if len(z.elements) == 0 {
+		return zefaniaPlain
+	}
+	return z.elements[len(z.elements)-1]
+}
+
+// Books outside the canon in Books, e.g. the deuterocanonical books numbered from 67, are skipped
+func (z *ZefaniaReader) startBook(bnumber string) error {
+	z.c.end()
+	n, err := strconv.Atoi(bnumber)
+	if err != nil || n < 1 {
+		return fmt.Errorf("Invalid book number: '%s'", bnumber)
+	}
+	z.book, z.chapter = "", 0
+	if n > len(Books) {
+		log.WithFields(log.Fields{
+			"bnumber": n,
+		}).Warn("Skipping verses from unknown Zefania book.")
+		return nil
+	}
+	z.book = Books[n-1].Name
+	return nil
+}
+
+// Bridged verses, e.g. "16-17", are stored under the first verse
+func (z *ZefaniaReader) startVerse(vnumber string) error {
+	z.c.end()
+	if i := strings.IndexFunc(vnumber, func(r rune) bool { return r < '0' || r > '9' }); i != -1 {
+		vnumber = vnumber[:i]
+	}
+	n, err := strconv.Atoi(vnumber)
+	if err != nil || n < 1 {
+		return fmt.Errorf("Invalid verse number: '%s'", vnumber)
+	}
+	if z.book == "" {
+		return nil
+	}
+	if z.chapter == 0 {
+		return fmt.Errorf("Verse outside a chapter")
+	}
+	if z.c.version == "" {
+		return fmt.Errorf("No version given, and the file does not name one in its identifier")
+	}
+	line, _ := z.dec.InputPos()
+	z.c.start(z.book, z.chapter, n, line)
+	return nil
+}
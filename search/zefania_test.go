@@ -0,0 +1,58 @@
+package biblescholar
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+const zefaniaFixture = `<?xml version="1.0" encoding="utf-8"?>
+<XMLBIBLE xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" biblename="Test" type="x-bible">
+  <INFORMATION><title>Test Bible</title><identifier>ZEF</identifier><language>ENG</language></INFORMATION>
+  <BIBLEBOOK bnumber="19" bname="Psalms">
+    <CHAPTER cnumber="23">
+      <CAPTION vref="1">The Lord is my shepherd</CAPTION>
+      <VERS vnumber="1">The <STYLE css="font-weight:bold">LORD</STYLE> is my shepherd;<BR art="x-nl"/>I shall not want.<NOTE type="x-studynote">A note</NOTE></VERS>
+      <VERS vnumber="2-3">He maketh me to lie down in <gr str="1">green</gr> pastures</VERS>
+    </CHAPTER>
+  </BIBLEBOOK>
+  <BIBLEBOOK bnumber="70" bname="Tobit"><CHAPTER cnumber="1"><VERS vnumber="1">Skip me</VERS></CHAPTER></BIBLEBOOK>
+</XMLBIBLE>
+`
+
+func TestZefaniaReader(t *testing.T) {
+	verses, lines := readAllVerses(t, NewZefaniaReader(strings.NewReader(zefaniaFixture), "ignored"))
+	checkVerses(t, "ZEF", verses, lines, []expectedVerse{
+		{VerseRef{"Psalm", 23, 1}, 7, "The LORD is my shepherd; I shall not want.", "The Lord is my shepherd"},
+		{VerseRef{"Psalm", 23, 2}, 8, "He maketh me to lie down in green pastures", ""},
+	})
+
+	// The version given is used when the file has no identifier
+	data := strings.Replace(zefaniaFixture, "<identifier>ZEF</identifier>", "", 1)
+	verses, _ = readAllVerses(t, NewZefaniaReader(strings.NewReader(data), "TEST"))
+	if len(verses) == 0 || verses[0].Version != "TEST" {
+		t.Errorf("Expected verses in the version given, got %v", verses)
+	}
+}
+
+func TestZefaniaReaderErrors(t *testing.T) {
+	cases := []struct {
+		name string
+		book string
+	}{
+		{"bad book", `<BIBLEBOOK bnumber="x"></BIBLEBOOK>`},
+		{"bad chapter", `<BIBLEBOOK bnumber="1"><CHAPTER cnumber="0"></CHAPTER></BIBLEBOOK>`},
+		{"bad verse", `<BIBLEBOOK bnumber="1"><CHAPTER cnumber="1"><VERS vnumber="0">x</VERS></CHAPTER></BIBLEBOOK>`},
+		{"verse outside a chapter", `<BIBLEBOOK bnumber="1"><VERS vnumber="1">x</VERS></BIBLEBOOK>`},
+	}
+	for _, c := range cases {
+		r := NewZefaniaReader(strings.NewReader(`<XMLBIBLE>`+c.book+`</XMLBIBLE>`), "ZEF")
+		var err error
+		for err == nil {
+			_, err = r.Next()
+		}
+		if err == io.EOF || !strings.HasPrefix(err.Error(), "Line 1: ") {
+			t.Errorf("%s: expected an error on line 1, got %v", c.name, err)
+		}
+	}
+}